@@ -0,0 +1,65 @@
+package main
+
+import (
+	"OrderKeeper/internal/config"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const usage = `usage: order-keeper [flags] [command]
+
+Without a command, the server starts.
+
+commands:
+  migrate ...                    apply or roll back database migrations
+  config print [--show-secrets]  print the effective configuration`
+
+// overrideFlags collects repeated -set key=value flags.
+type overrideFlags []string
+
+func (o *overrideFlags) String() string {
+	return strings.Join(*o, ",")
+}
+
+func (o *overrideFlags) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// runConfig runs the config subcommand and returns the exit code. Secrets
+// are redacted unless --show-secrets is given, so the output can be pasted
+// into tickets. The configuration is printed even when it is invalid,
+// followed by what is wrong with it, since that is when it is most useful.
+func runConfig(cfg config.Config, args []string, out, errOut io.Writer) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(errOut, usage)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	showSecrets := flags.Bool("show-secrets", false, "print secrets instead of [REDACTED]")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 0 {
+		fmt.Fprintln(errOut, usage)
+		return 2
+	}
+
+	printed := cfg.Redacted()
+	if *showSecrets {
+		printed = cfg
+	}
+	data, err := printed.YAML()
+	if err != nil {
+		fmt.Fprintln(errOut, err)
+		return 1
+	}
+	_, _ = out.Write(data)
+
+	if err = cfg.Validate(); err != nil {
+		fmt.Fprintln(errOut, err)
+		return 1
+	}
+	return 0
+}
@@ -3,14 +3,20 @@ package main
 import (
 	"OrderKeeper/internal/config"
 	"OrderKeeper/internal/handler"
+	"OrderKeeper/internal/lifecycle"
+	"OrderKeeper/internal/migrate"
+	"OrderKeeper/internal/notifier"
 	"OrderKeeper/internal/repository/cache"
 	"OrderKeeper/internal/repository/postgres"
 	"OrderKeeper/internal/service"
+	"OrderKeeper/migrations"
 	"OrderKeeper/server"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"log"
@@ -20,146 +26,328 @@ import (
 )
 
 func main() {
-	logger := initLogger()
+	flags := flag.NewFlagSet("order-keeper", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "%s\n\nflags:\n", usage)
+		flags.PrintDefaults()
+	}
+	configFile := flags.String("config", config.DefaultFile, "path to the YAML config file")
+	var overrides overrideFlags
+	flags.Var(&overrides, "set", "override a config value as key=value, e.g. redis.db=3 (repeatable)")
+	_ = flags.Parse(os.Args[1:])
+	args := flags.Args()
+
+	// .env is loaded before the config so its variables count as environment.
+	envErr := godotenv.Load()
 
-	if err := config.InitConfig(); err != nil {
-		fmt.Println("unable to initialize config: %v", err)
-		logger.Fatal("error initializing config")
+	loadOpts := config.LoadOptions{File: *configFile, Overrides: overrides}
+	cfg, err := config.Load(loadOpts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	if err := godotenv.Load(); err != nil {
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(runConfig(cfg, args[1:], os.Stdout, os.Stderr))
+	}
+	if len(args) > 0 && args[0] != "migrate" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", args[0], usage)
+		os.Exit(2)
+	}
+
+	// migrate only connects to the database, so it does not need the
+	// server's secrets or mail settings to be valid.
+	validate := cfg.Validate
+	if len(args) > 0 {
+		validate = cfg.ValidateMigrate
+	}
+	if err = validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logger, logLevel, err := initLogger(cfg.Logging)
+	if err != nil {
+		log.Fatal("Failed to initialize logger:", err)
+	}
+	if envErr != nil {
 		logger.Warn("no .env file found, using environment variables")
 	}
+	logger.Info("Configuration loaded", zap.String("file", *configFile))
 
 	db, err := postgres.NewPostgresDB(context.Background(), postgres.Config{
-		Host:     getConfigString("db.host", "DB_HOST"),
-		Port:     getConfigString("db.port", "DB_PORT"),
-		Username: getConfigString("db.username", "DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-		DBName:   getConfigString("db.dbname", "DB_NAME"),
-		SSLMode:  getConfigString("db.sslmode", "DB_SSLMODE"),
+		Host:     cfg.DB.Host,
+		Port:     cfg.DB.Port,
+		Username: cfg.DB.Username,
+		Password: cfg.DB.Password,
+		DBName:   cfg.DB.DBName,
+		SSLMode:  cfg.DB.SSLMode,
 	})
 	if err != nil {
 		logger.Fatal("error initializing postgres db", zap.Error(err))
-		os.Exit(1)
 	}
-	defer db.Close()
 	logger.Info("Postgres DB initialized successfully")
 
-	var repo *postgres.Repository
+	// From here on the process exits through app, so whatever has been
+	// opened is closed on every path.
+	app := lifecycle.New(lifecycle.Options{
+		DrainDelay:      cfg.Server.ShutdownDelay,
+		ShutdownTimeout: cfg.Server.ShutdownTimeout,
+	}, logger)
+	app.AddCloser("postgres", func() error {
+		db.Close()
+		return nil
+	})
 
-	redisEnabled := getConfigBool("redis.enable", "REDIS_ENABLE")
-	if redisEnabled {
-		// Try environment variables first, then config file
-		redisAddr := os.Getenv("REDIS_ADDR")
-		if redisAddr == "" {
-			redisHost := getConfigString("redis.host", "REDIS_HOST")
-			redisPort := getConfigString("redis.port", "REDIS_PORT")
-			redisAddr = fmt.Sprintf("%s:%s", redisHost, redisPort)
+	if len(args) > 0 {
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		err = migrateDB(ctx, db, args[1:], logger)
+		stop()
+		code := 0
+		switch {
+		case errors.Is(err, errMigrateUsage):
+			fmt.Fprintf(os.Stderr, "%v\n\n%s\n", err, migrateUsage)
+			code = 2
+		case err != nil:
+			logger.Error("migration failed", zap.Error(err))
+			code = 1
+		}
+		if err = app.Close(); err != nil && code == 0 {
+			code = 1
+		}
+		_ = logger.Sync()
+		os.Exit(code)
+	}
+
+	if err = setupServer(app, cfg, loadOpts, db, logger, logLevel); err != nil {
+		err = errors.Join(err, app.Close())
+	} else {
+		err = app.Run(context.Background())
+	}
+	if err != nil {
+		logger.Error("Keeper exited with error", zap.Error(err))
+		_ = logger.Sync()
+		os.Exit(1)
+	}
+	logger.Info("Keeper exited")
+	_ = logger.Sync()
+}
+
+// migrateDB runs the migrate subcommand given by args.
+func migrateDB(ctx context.Context, db *pgxpool.Pool, args []string, logger *zap.Logger) error {
+	migrator, err := migrate.New(db, migrations.FS, logger)
+	if err != nil {
+		return fmt.Errorf("error loading migrations: %w", err)
+	}
+	return runMigrate(ctx, migrator, args, os.Stdout)
+}
+
+// setupServer applies pending migrations when configured, connects to Redis
+// and registers the server's components and resources with app. Resources it
+// opens are registered before it returns an error, so closing app closes
+// them.
+func setupServer(app *lifecycle.Manager, cfg config.Config, loadOpts config.LoadOptions, db *pgxpool.Pool, logger *zap.Logger, logLevel zap.AtomicLevel) error {
+	if cfg.DB.AutoMigrate {
+		migrator, err := migrate.New(db, migrations.FS, logger)
+		if err != nil {
+			return fmt.Errorf("error loading migrations: %w", err)
+		}
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			return fmt.Errorf("error applying migrations: %w", err)
 		}
+		logger.Info("Database migrations applied", zap.Int("applied", applied))
+	}
 
-		redisPassword := os.Getenv("REDIS_PASSWORD")
-		redisDB := getConfigInt("redis.db", "REDIS_DB")
+	healthChecks := []handler.HealthCheck{
+		{Name: "postgres", Critical: true, Check: db.Ping},
+	}
+
+	var repo *postgres.Repository
+	liveCacheTTLs := postgres.NewLiveCacheTTLs(cacheTTLs(cfg.Cache))
 
+	if cfg.Redis.Enable {
+		redisAddr := cfg.Redis.Address()
 		logger.Info("Attempting to connect to Redis", zap.String("address", redisAddr))
 
 		redisCache, err := cache.NewRedisCache(context.Background(), cache.RedisConfig{
 			Address:  redisAddr,
-			Password: redisPassword,
-			Database: redisDB,
+			Password: cfg.Redis.Password,
+			Database: cfg.Redis.DB,
 		}, logger)
 		if err != nil {
 			logger.Error("error initializing redis, falling back to non-cached repository", zap.Error(err))
 			repo = postgres.NewRepository(db, logger)
 		} else {
 			logger.Info("Redis initialized successfully, using cached repository")
-			repo = postgres.NewCachedRepository(db, &redisCache, logger)
+			repo = postgres.NewCachedRepository(db, &redisCache, liveCacheTTLs, logger)
+			// Without Redis the cached repositories fall back to Postgres,
+			// so an outage degrades the service instead of taking it down.
+			healthChecks = append(healthChecks, handler.HealthCheck{Name: "redis", Check: redisCache.Ping})
+			// Registered after Postgres, so it is closed before it.
+			app.AddCloser("redis", redisCache.Close)
 		}
 	} else {
 		logger.Info("Redis disabled, using non-cached repository")
 		repo = postgres.NewRepository(db, logger)
 	}
 
-	services := service.NewService(repo, logger)
-	handlers := handler.NewHandler(services, logger)
-
-	srv := new(server.Server)
-	go func() {
-		port := getConfigString("port", "PORT")
-		if port == "" {
-			port = "8080"
-		}
-		if err := srv.Run(":"+port, handlers.InitRoutes()); err != nil {
-			logger.Fatal("error running server", zap.Error(err))
-		}
-	}()
-
-	port := getConfigString("port", "PORT")
-	if port == "" {
-		port = "8080"
+	tokenKeys, err := service.LoadTokenKeys(tokenKeysConfig(cfg.Auth.JWT))
+	if err != nil {
+		return fmt.Errorf("error loading token signing keys: %w", err)
 	}
-	logger.Info("Server started on port", zap.String("port", port))
+	logger.Info("Token signing keys loaded", zap.String("algorithm", cfg.Auth.JWT.Algorithm))
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	mailer, err := notifier.New(notifier.Config{
+		Driver: cfg.Notifier.Driver,
+		File:   cfg.Notifier.File,
+		SMTP: notifier.SMTPConfig{
+			Host:     cfg.Notifier.SMTP.Host,
+			Port:     cfg.Notifier.SMTP.Port,
+			Username: cfg.Notifier.SMTP.Username,
+			Password: cfg.Notifier.SMTP.Password,
+			From:     cfg.Notifier.SMTP.From,
+		},
+	}, logger)
+	if err != nil {
+		return fmt.Errorf("error initializing notifier: %w", err)
+	}
 
-	logger.Info("Keeper shutting down")
+	// Emails that outlive their request run here. Stop waits for the queued
+	// ones, and Redis and Postgres are closed only after Run has returned.
+	background := service.NewBackground(logger)
+	app.Add(lifecycle.Component{
+		Name: "background",
+		Run:  background.Run,
+		Stop: background.Stop,
+	})
 
-	if err := srv.Shutdown(context.Background()); err != nil {
-		logger.Error("error occurred while shutting down", zap.Error(err))
+	services, err := service.NewService(repo, service.AuthOptions{
+		TokenKeys:            tokenKeys,
+		Notifier:             mailer,
+		Background:           background,
+		PasswordResetURL:     cfg.Auth.PasswordResetURL,
+		EmailVerificationURL: cfg.Auth.EmailVerificationURL,
+		MFAIssuer:            cfg.Auth.MFA.Issuer,
+		LoginThrottle:        loginThrottleOptions(cfg.Auth.LoginThrottle),
+		BcryptCost:           cfg.Auth.BcryptCost,
+	}, service.OrderOptions{
+		RequireVerifiedEmail: cfg.Orders.RequireVerifiedEmail,
+	}, logger)
+	if err != nil {
+		return fmt.Errorf("error initializing services: %w", err)
 	}
+	handlers := handler.NewHandler(services, healthChecks, logger)
 
-	logger.Info("Server exited")
+	reloader := config.NewReloader(cfg, loadOpts, logger)
+	reloader.Subscribe("logger", func(cfg config.Config) {
+		if level, err := zapcore.ParseLevel(cfg.Logging.Level); err == nil {
+			logLevel.SetLevel(level)
+		}
+	})
+	reloader.Subscribe("cache", func(cfg config.Config) {
+		liveCacheTTLs.Set(cacheTTLs(cfg.Cache))
+	})
+	reloader.Subscribe("login_throttle", func(cfg config.Config) {
+		services.SetLoginThrottle(loginThrottleOptions(cfg.Auth.LoginThrottle))
+	})
+	app.Add(lifecycle.Component{
+		Name: "config_watcher",
+		Run: func(ctx context.Context) error {
+			reloader.Watch(ctx)
+			<-ctx.Done()
+			return nil
+		},
+	})
+
+	srv := new(server.Server)
+	routes := handlers.InitRoutes()
+	app.Add(lifecycle.Component{
+		Name: "http",
+		Run: func(ctx context.Context) error {
+			logger.Info("Server starting on port", zap.String("port", cfg.Server.Port))
+			return srv.Run(":"+cfg.Server.Port, routes)
+		},
+		Stop: srv.Shutdown,
+	})
+	app.OnDrain(handlers.StartDraining)
+
+	return nil
 }
 
-// Helper functions to prioritize environment variables over config file
-func getConfigString(configKey, envKey string) string {
-	if envVal := os.Getenv(envKey); envVal != "" {
-		return envVal
+func cacheTTLs(cfg config.CacheConfig) postgres.CacheTTLs {
+	return postgres.CacheTTLs{
+		User:        cfg.UserTTL,
+		Order:       cfg.OrderTTL,
+		OrderList:   cfg.OrderListTTL,
+		Product:     cfg.ProductTTL,
+		ProductList: cfg.ProductListTTL,
+		APIKey:      cfg.APIKeyTTL,
 	}
-	return viper.GetString(configKey)
 }
 
-func getConfigBool(configKey, envKey string) bool {
-	if envVal := os.Getenv(envKey); envVal != "" {
-		return envVal == "true" || envVal == "1"
+func loginThrottleOptions(cfg config.LoginThrottleConfig) service.LoginThrottleOptions {
+	policy := func(cfg config.LoginThrottlePolicy) service.LoginThrottlePolicy {
+		return service.LoginThrottlePolicy{
+			FreeAttempts:     cfg.FreeAttempts,
+			BaseDelay:        cfg.BaseDelay,
+			MaxDelay:         cfg.MaxDelay,
+			LockoutThreshold: cfg.LockoutThreshold,
+			LockoutDuration:  cfg.LockoutDuration,
+			Window:           cfg.Window,
+		}
+	}
+	return service.LoginThrottleOptions{
+		Username: policy(cfg.Username),
+		IP:       policy(cfg.IP),
 	}
-	return viper.GetBool(configKey)
 }
 
-func getConfigInt(configKey, envKey string) int {
-	if envVal := os.Getenv(envKey); envVal != "" {
-		// You might want to add proper string to int conversion with error handling
-		if envVal == "0" {
-			return 0
-		}
-		return 1 // Default for non-zero values
+func tokenKeysConfig(cfg config.JWTConfig) service.TokenKeysConfig {
+	keys := make([]service.TokenKeyConfig, 0, len(cfg.Keys))
+	for _, key := range cfg.Keys {
+		keys = append(keys, service.TokenKeyConfig{
+			ID:             key.ID,
+			PrivateKeyFile: key.PrivateKeyFile,
+			PublicKeyFile:  key.PublicKeyFile,
+		})
+	}
+	return service.TokenKeysConfig{
+		Algorithm:    cfg.Algorithm,
+		SigningKeyID: cfg.SigningKeyID,
+		Secret:       cfg.Secret,
+		Keys:         keys,
 	}
-	return viper.GetInt(configKey)
 }
 
-func initLogger() *zap.Logger {
-	cfg := zap.NewProductionConfig()
+// initLogger builds the logger. Its level can be changed later through the
+// returned AtomicLevel.
+func initLogger(cfg config.LoggingConfig) (*zap.Logger, zap.AtomicLevel, error) {
+	level, err := zapcore.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, zap.AtomicLevel{}, err
+	}
+
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.Level = zap.NewAtomicLevelAt(level)
+	zapConfig.Encoding = cfg.Format
 
-	cfg.EncoderConfig.TimeKey = "time"
-	cfg.EncoderConfig.LevelKey = "level"
-	cfg.EncoderConfig.MessageKey = "msg"
-	cfg.EncoderConfig.CallerKey = "caller"
-	cfg.EncoderConfig.StacktraceKey = "stacktrace"
-	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-	cfg.EncoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
+	zapConfig.EncoderConfig.TimeKey = "time"
+	zapConfig.EncoderConfig.LevelKey = "level"
+	zapConfig.EncoderConfig.MessageKey = "msg"
+	zapConfig.EncoderConfig.CallerKey = "caller"
+	zapConfig.EncoderConfig.StacktraceKey = "stacktrace"
+	zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	zapConfig.EncoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
 
-	cfg.InitialFields = map[string]interface{}{
+	zapConfig.InitialFields = map[string]interface{}{
 		"service": "myapp",
 		"version": "1.0.0",
 	}
 
-	logger, err := cfg.Build()
+	logger, err := zapConfig.Build()
 	if err != nil {
-		log.Fatal("Failed to initialize logger:", err)
+		return nil, zap.AtomicLevel{}, err
 	}
-
-	return logger
+	return logger, zapConfig.Level, nil
 }
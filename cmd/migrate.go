@@ -0,0 +1,89 @@
+package main
+
+import (
+	"OrderKeeper/internal/migrate"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: order-keeper migrate <command>
+
+commands:
+  up              apply all pending migrations
+  down [N]        roll back the last N applied migrations (default 1)
+  status          list migrations and whether they are applied
+  goto VERSION    apply or roll back migrations until VERSION is the latest
+                  applied one; 0 rolls back everything`
+
+var errMigrateUsage = errors.New("invalid migrate command")
+
+// runMigrate runs the migrate subcommand given by args and writes its result
+// to out.
+func runMigrate(ctx context.Context, migrator *migrate.Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	switch command := args[0]; {
+	case command == "up" && len(args) == 1:
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migration(s)\n", applied)
+
+	case command == "down" && len(args) <= 2:
+		steps := 1
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("%w: %q is not a positive number of steps", errMigrateUsage, args[1])
+			}
+			steps = n
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rolled back %d migration(s)\n", rolledBack)
+
+	case command == "goto" && len(args) == 2:
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("%w: %q is not a migration version", errMigrateUsage, args[1])
+		}
+		if err = migrator.Goto(ctx, version); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "migrated to version %d\n", version)
+
+	case command == "status" && len(args) == 1:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			name := status.Name
+			if status.Missing {
+				name = "(no migration file)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, name, appliedAt)
+		}
+		return w.Flush()
+
+	default:
+		return errMigrateUsage
+	}
+	return nil
+}
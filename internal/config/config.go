@@ -1,9 +1,321 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"slices"
+	"strings"
+	"time"
+)
 
-func InitConfig() error {
-	viper.AddConfigPath("configs")
-	viper.SetConfigName("config")
-	return viper.ReadInConfig()
+// DefaultFile is read when no -config flag is given.
+const DefaultFile = "configs/config.yaml"
+
+// EnvPrefix prefixes the environment variable of every key, with dots
+// replaced by underscores: ORDER_KEEPER_AUTH_BCRYPT_COST sets auth.bcrypt_cost.
+const EnvPrefix = "ORDER_KEEPER_"
+
+const redacted = "[REDACTED]"
+
+// envAliases are the shorter variable names used before EnvPrefix existed.
+// The prefixed name wins when both are set.
+var envAliases = map[string][]string{
+	"server.port":                   {"PORT"},
+	"db.host":                       {"DB_HOST"},
+	"db.port":                       {"DB_PORT"},
+	"db.username":                   {"DB_USER"},
+	"db.password":                   {"DB_PASSWORD"},
+	"db.dbname":                     {"DB_NAME"},
+	"db.sslmode":                    {"DB_SSLMODE"},
+	"db.auto_migrate":               {"DB_AUTO_MIGRATE"},
+	"redis.enable":                  {"REDIS_ENABLE"},
+	"redis.addr":                    {"REDIS_ADDR"},
+	"redis.host":                    {"REDIS_HOST"},
+	"redis.port":                    {"REDIS_PORT"},
+	"redis.password":                {"REDIS_PASSWORD"},
+	"redis.db":                      {"REDIS_DB"},
+	"auth.jwt.algorithm":            {"JWT_ALGORITHM"},
+	"auth.jwt.secret":               {"SIGNING_KEY"},
+	"notifier.smtp.password":        {"SMTP_PASSWORD"},
+	"orders.require_verified_email": {"ORDERS_REQUIRE_VERIFIED_EMAIL"},
+	"logging.level":                 {"LOG_LEVEL"},
+}
+
+// Config is the application configuration. Values are layered, from lowest
+// to highest precedence: Default, the config file, environment variables,
+// then -set flags.
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	DB       DBConfig       `yaml:"db"`
+	Redis    RedisConfig    `yaml:"redis"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Orders   OrdersConfig   `yaml:"orders"`
+	Notifier NotifierConfig `yaml:"notifier"`
+	Logging  LoggingConfig  `yaml:"logging"`
+	Cache    CacheConfig    `yaml:"cache"`
+}
+
+type ServerConfig struct {
+	Port string `yaml:"port"`
+	// ShutdownDelay is how long /readyz reports shutting down before the
+	// server stops accepting requests, so load balancers drain it first.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	// ShutdownTimeout bounds waiting for in-flight requests and background
+	// components to finish after the delay. Connections are closed after it
+	// either way.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DBConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"dbname"`
+	SSLMode  string `yaml:"sslmode"`
+	// AutoMigrate applies pending migrations at startup.
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+type RedisConfig struct {
+	Enable bool `yaml:"enable"`
+	// Addr is host:port and takes precedence over Host and Port.
+	Addr     string `yaml:"addr"`
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+// Address is the host:port to connect to.
+func (r RedisConfig) Address() string {
+	if r.Addr != "" {
+		return r.Addr
+	}
+	return r.Host + ":" + r.Port
+}
+
+type AuthConfig struct {
+	PasswordResetURL     string              `yaml:"password_reset_url"`
+	EmailVerificationURL string              `yaml:"email_verification_url"`
+	BcryptCost           int                 `yaml:"bcrypt_cost"`
+	MFA                  MFAConfig           `yaml:"mfa"`
+	LoginThrottle        LoginThrottleConfig `yaml:"login_throttle"`
+	JWT                  JWTConfig           `yaml:"jwt"`
+}
+
+type MFAConfig struct {
+	Issuer string `yaml:"issuer"`
+}
+
+type LoginThrottleConfig struct {
+	Username LoginThrottlePolicy `yaml:"username"`
+	IP       LoginThrottlePolicy `yaml:"ip"`
+}
+
+type LoginThrottlePolicy struct {
+	FreeAttempts     int           `yaml:"free_attempts"`
+	BaseDelay        time.Duration `yaml:"base_delay"`
+	MaxDelay         time.Duration `yaml:"max_delay"`
+	LockoutThreshold int           `yaml:"lockout_threshold"`
+	LockoutDuration  time.Duration `yaml:"lockout_duration"`
+	Window           time.Duration `yaml:"window"`
+}
+
+type JWTConfig struct {
+	Algorithm    string `yaml:"algorithm"`
+	SigningKeyID string `yaml:"signing_key_id"`
+	// Secret is the HS256 signing key.
+	Secret string         `yaml:"secret"`
+	Keys   []JWTKeyConfig `yaml:"keys"`
+}
+
+type JWTKeyConfig struct {
+	ID             string `yaml:"id"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+}
+
+type OrdersConfig struct {
+	RequireVerifiedEmail bool `yaml:"require_verified_email"`
+}
+
+type NotifierConfig struct {
+	Driver string     `yaml:"driver"`
+	File   string     `yaml:"file"`
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+type LoggingConfig struct {
+	// Level is a zap level: debug, info, warn or error.
+	Level string `yaml:"level"`
+	// Format is json or console.
+	Format string `yaml:"format"`
+}
+
+type CacheConfig struct {
+	UserTTL        time.Duration `yaml:"user_ttl"`
+	OrderTTL       time.Duration `yaml:"order_ttl"`
+	OrderListTTL   time.Duration `yaml:"order_list_ttl"`
+	ProductTTL     time.Duration `yaml:"product_ttl"`
+	ProductListTTL time.Duration `yaml:"product_list_ttl"`
+	APIKeyTTL      time.Duration `yaml:"api_key_ttl"`
+}
+
+func Default() Config {
+	throttle := func(freeAttempts, lockoutThreshold int) LoginThrottlePolicy {
+		return LoginThrottlePolicy{
+			FreeAttempts:     freeAttempts,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: lockoutThreshold,
+			LockoutDuration:  15 * time.Minute,
+			Window:           15 * time.Minute,
+		}
+	}
+
+	return Config{
+		Server: ServerConfig{
+			Port:            "8080",
+			ShutdownDelay:   5 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		DB: DBConfig{
+			Host:     "localhost",
+			Port:     "5432",
+			Username: "postgres",
+			DBName:   "orderdb",
+			SSLMode:  "disable",
+		},
+		Redis: RedisConfig{
+			Enable: true,
+			Host:   "localhost",
+			Port:   "6379",
+		},
+		Auth: AuthConfig{
+			BcryptCost: 10,
+			MFA:        MFAConfig{Issuer: "OrderKeeper"},
+			LoginThrottle: LoginThrottleConfig{
+				Username: throttle(3, 10),
+				IP:       throttle(20, 100),
+			},
+			JWT: JWTConfig{Algorithm: "HS256", Keys: []JWTKeyConfig{}},
+		},
+		Notifier: NotifierConfig{
+			Driver: "log",
+			SMTP: SMTPConfig{
+				Port: "587",
+				From: "OrderKeeper <no-reply@example.com>",
+			},
+		},
+		Logging: LoggingConfig{Level: "info", Format: "json"},
+		Cache: CacheConfig{
+			UserTTL:        time.Hour,
+			OrderTTL:       30 * time.Minute,
+			OrderListTTL:   15 * time.Minute,
+			ProductTTL:     30 * time.Minute,
+			ProductListTTL: 15 * time.Minute,
+			APIKeyTTL:      5 * time.Minute,
+		},
+	}
+}
+
+// LoadOptions says where Load reads the configuration from.
+type LoadOptions struct {
+	// File is the YAML config file; empty reads only defaults and the
+	// environment.
+	File string
+	// Overrides are key=value pairs from -set flags, like
+	// "redis.db=3", applied over every other source.
+	Overrides []string
+}
+
+// Load builds the configuration from all sources. It fails on unreadable
+// files, unknown keys and values of the wrong type; call Validate to check
+// the values themselves.
+func Load(opts LoadOptions) (Config, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+
+	// Defaults go in as the base config layer, so every key is known to
+	// viper, and the file is merged over them.
+	defaults, err := yaml.Marshal(Default())
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to encode defaults: %w", err)
+	}
+	if err = v.ReadConfig(bytes.NewReader(defaults)); err != nil {
+		return Config{}, fmt.Errorf("failed to load defaults: %w", err)
+	}
+	if opts.File != "" {
+		v.SetConfigFile(opts.File)
+		if err = v.MergeInConfig(); err != nil {
+			return Config{}, fmt.Errorf("failed to read config file %s: %w", opts.File, err)
+		}
+	}
+
+	keys := v.AllKeys()
+	for _, key := range keys {
+		names := append([]string{key, envName(key)}, envAliases[key]...)
+		if err = v.BindEnv(names...); err != nil {
+			return Config{}, fmt.Errorf("failed to bind environment for %s: %w", key, err)
+		}
+	}
+
+	for _, override := range opts.Overrides {
+		key, value, ok := strings.Cut(override, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || !slices.Contains(keys, key) {
+			return Config{}, fmt.Errorf("invalid override %q: expected key=value with a known key", override)
+		}
+		v.Set(key, value)
+	}
+
+	var cfg Config
+	err = v.Unmarshal(&cfg, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "yaml"
+		dc.ErrorUnused = true
+	})
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// Redacted returns a copy with every secret replaced, for printing.
+func (c Config) Redacted() Config {
+	for _, secret := range []*string{&c.DB.Password, &c.Redis.Password, &c.Auth.JWT.Secret, &c.Notifier.SMTP.Password} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return c
+}
+
+// YAML encodes the configuration in the config file format.
+func (c Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
 }
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  port: "9000"
+redis:
+  db: 1
+  host: "redis.internal"
+cache:
+  order_ttl: "10m"
+`)
+	t.Setenv("REDIS_DB", "3")
+	t.Setenv("ORDER_KEEPER_REDIS_HOST", "redis.env")
+	t.Setenv("REDIS_HOST", "redis.alias")
+
+	cfg, err := Load(LoadOptions{File: path, Overrides: []string{"server.port=9100"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Server.Port != "9100" {
+		t.Errorf("server.port = %q, want the -set value 9100", cfg.Server.Port)
+	}
+	if cfg.Redis.DB != 3 {
+		t.Errorf("redis.db = %d, want 3 from REDIS_DB", cfg.Redis.DB)
+	}
+	if cfg.Redis.Host != "redis.env" {
+		t.Errorf("redis.host = %q, want the prefixed variable to win over the alias", cfg.Redis.Host)
+	}
+	if cfg.Cache.OrderTTL != 10*time.Minute {
+		t.Errorf("cache.order_ttl = %s, want 10m from the file", cfg.Cache.OrderTTL)
+	}
+	if cfg.Cache.UserTTL != Default().Cache.UserTTL {
+		t.Errorf("cache.user_ttl = %s, want the default", cfg.Cache.UserTTL)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	if _, err := Load(LoadOptions{File: writeConfigFile(t, "redis:\n  database: 3\n")}); err == nil {
+		t.Errorf("expected an error for an unknown key in the file")
+	}
+	if _, err := Load(LoadOptions{Overrides: []string{"redis.database=3"}}); err == nil {
+		t.Errorf("expected an error for an unknown -set key")
+	}
+	if _, err := Load(LoadOptions{Overrides: []string{"redis.db=three"}}); err == nil {
+		t.Errorf("expected an error for a value of the wrong type")
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.JWT.Secret = "secret"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("defaults with a secret should be valid: %v", err)
+	}
+
+	cfg.Server.Port = "http"
+	cfg.Auth.BcryptCost = 2
+	cfg.Cache.OrderTTL = 0
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, key := range []string{"server.port", "auth.bcrypt_cost", "cache.order_ttl"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error does not mention %s: %v", key, err)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.DB.Password = "db-secret"
+	cfg.Auth.JWT.Secret = "jwt-secret"
+
+	data, err := cfg.Redacted().YAML()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(data), "db-secret") || strings.Contains(string(data), "jwt-secret") {
+		t.Errorf("redacted config contains a secret:\n%s", data)
+	}
+	if cfg.DB.Password != "db-secret" {
+		t.Errorf("Redacted changed the original config")
+	}
+}
+
+func TestValidateMigrateIgnoresServerSettings(t *testing.T) {
+	cfg := Default()
+	cfg.Auth.JWT.Secret = ""
+	cfg.Notifier.Driver = "smtp"
+	cfg.Server.Port = "http"
+	if err := cfg.ValidateMigrate(); err != nil {
+		t.Fatalf("ValidateMigrate() error = %v, want nil", err)
+	}
+
+	cfg.DB.SSLMode = "sometimes"
+	if err := cfg.ValidateMigrate(); err == nil || !strings.Contains(err.Error(), "db.sslmode") {
+		t.Fatalf("ValidateMigrate() error = %v, want a db.sslmode error", err)
+	}
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	configReloadsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_reloads_total",
+			Help: "Total number of configuration reloads, by result: applied, unchanged or rejected",
+		},
+		[]string{"result"},
+	)
+
+	configLastReloadSuccessful = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "config_last_reload_successful",
+			Help: "Whether the last configuration reload was accepted (1) or rejected (0)",
+		},
+	)
+)
+
+// markConfigLoaded reports the configuration the process started with as a
+// successful load.
+func markConfigLoaded() {
+	configLastReloadSuccessful.Set(1)
+}
+
+// recordConfigReload counts a configuration reload. Only ReloadRejected
+// marks the last reload as failed.
+func recordConfigReload(result string) {
+	configReloadsTotal.WithLabelValues(result).Inc()
+	if result == ReloadRejected {
+		configLastReloadSuccessful.Set(0)
+	} else {
+		configLastReloadSuccessful.Set(1)
+	}
+}
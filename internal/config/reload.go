@@ -0,0 +1,222 @@
+package config
+
+import (
+	"context"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	ReloadApplied   = "applied"
+	ReloadUnchanged = "unchanged"
+	ReloadRejected  = "rejected"
+)
+
+// fileReloadDelay is how long the config file has to stay unchanged before
+// it is reloaded.
+const fileReloadDelay = 200 * time.Millisecond
+
+// Subscriber applies a reloaded configuration to one component. It only
+// gets configurations that passed Validate.
+type Subscriber func(cfg Config)
+
+type subscription struct {
+	name string
+	fn   Subscriber
+}
+
+// Reloader re-reads the configuration when the config file changes or the
+// process gets SIGHUP, and passes it to the subscribers. Only the settings
+// that are safe to change at runtime are taken from a reload: logging.level,
+// cache and auth.login_throttle. Changes to anything else are logged as
+// needing a restart. An invalid configuration is rejected as a whole and the
+// current one stays in effect.
+type Reloader struct {
+	mu            sync.Mutex
+	opts          LoadOptions
+	current       Config
+	subscriptions []subscription
+	logger        *zap.Logger
+}
+
+func NewReloader(cfg Config, opts LoadOptions, logger *zap.Logger) *Reloader {
+	markConfigLoaded()
+	return &Reloader{
+		opts:    opts,
+		current: cfg,
+		logger:  logger,
+	}
+}
+
+// Current returns the configuration in effect.
+func (r *Reloader) Current() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Subscribe registers fn to be called with every reloaded configuration that
+// changes a reloadable setting. Subscribers are called in the order they
+// subscribed, one reload at a time.
+func (r *Reloader) Subscribe(name string, fn Subscriber) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscriptions = append(r.subscriptions, subscription{name: name, fn: fn})
+}
+
+// Reload loads the configuration from the same sources as at startup and
+// applies its reloadable settings. trigger is only used for logging.
+func (r *Reloader) Reload(trigger string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := Load(r.opts)
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		recordConfigReload(ReloadRejected)
+		r.logger.Error("config reload rejected, keeping the current config",
+			zap.String("trigger", trigger),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	applied := r.current.withReloadable(next)
+	if restart := changedKeys(applied, next); len(restart) > 0 {
+		r.logger.Warn("config changes need a restart to take effect",
+			zap.String("trigger", trigger),
+			zap.Strings("keys", restart),
+		)
+	}
+
+	changed := changedKeys(r.current, applied)
+	if len(changed) == 0 {
+		recordConfigReload(ReloadUnchanged)
+		r.logger.Info("config reloaded, no reloadable setting changed",
+			zap.String("trigger", trigger),
+		)
+		return nil
+	}
+
+	r.current = applied
+	for _, s := range r.subscriptions {
+		s.fn(applied)
+		r.logger.Debug("config subscriber updated", zap.String("subscriber", s.name))
+	}
+
+	recordConfigReload(ReloadApplied)
+	r.logger.Info("config reloaded",
+		zap.String("trigger", trigger),
+		zap.Strings("changed", changed),
+	)
+	return nil
+}
+
+// Watch reloads on changes to the config file and on SIGHUP until ctx is
+// done. viper stops watching a file that is removed, as some editors do when
+// saving; SIGHUP keeps working either way.
+func (r *Reloader) Watch(ctx context.Context) {
+	if r.opts.File != "" {
+		// Saving a file can take several writes; reload once they settle
+		// rather than on a half-written file.
+		var (
+			debounceMu sync.Mutex
+			debounce   *time.Timer
+		)
+		v := viper.New()
+		v.SetConfigFile(r.opts.File)
+		v.OnConfigChange(func(event fsnotify.Event) {
+			debounceMu.Lock()
+			defer debounceMu.Unlock()
+			if debounce != nil {
+				debounce.Stop()
+			}
+			debounce = time.AfterFunc(fileReloadDelay, func() {
+				if ctx.Err() == nil {
+					_ = r.Reload("file " + event.Op.String())
+				}
+			})
+		})
+		v.WatchConfig()
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				_ = r.Reload("SIGHUP")
+			}
+		}
+	}()
+
+	r.logger.Info("watching config for changes", zap.String("file", r.opts.File))
+}
+
+// withReloadable returns c with the settings that can change at runtime
+// taken from next.
+func (c Config) withReloadable(next Config) Config {
+	c.Logging.Level = next.Logging.Level
+	c.Cache = next.Cache
+	c.Auth.LoginThrottle = next.Auth.LoginThrottle
+	return c
+}
+
+// changedKeys lists the keys whose values differ between a and b.
+func changedKeys(a, b Config) []string {
+	flatA, errA := flatten(a)
+	flatB, errB := flatten(b)
+	if errA != nil || errB != nil {
+		return []string{"(unknown)"}
+	}
+
+	var keys []string
+	for key, value := range flatA {
+		if !reflect.DeepEqual(value, flatB[key]) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flatten maps every dotted key of cfg to its value. Lists are kept whole.
+func flatten(cfg Config) (map[string]any, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]any
+	if err = yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+
+	flat := make(map[string]any)
+	var walk func(prefix string, node map[string]any)
+	walk = func(prefix string, node map[string]any) {
+		for key, value := range node {
+			if child, ok := value.(map[string]any); ok {
+				walk(prefix+key+".", child)
+				continue
+			}
+			flat[prefix+key] = value
+		}
+	}
+	walk("", tree)
+	return flat, nil
+}
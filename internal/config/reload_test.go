@@ -0,0 +1,62 @@
+package config
+
+import (
+	"go.uber.org/zap"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	t.Setenv("SIGNING_KEY", "secret")
+	path := writeConfigFile(t, "logging:\n  level: info\n")
+	opts := LoadOptions{File: path}
+
+	cfg, err := Load(opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reloader := NewReloader(cfg, opts, zap.NewNop())
+	var received []Config
+	reloader.Subscribe("test", func(cfg Config) {
+		received = append(received, cfg)
+	})
+
+	rewrite := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write config file: %v", err)
+		}
+	}
+
+	rewrite("logging:\n  level: debug\ncache:\n  order_ttl: 1m\ndb:\n  host: db.internal\n")
+	if err = reloader.Reload("test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("subscriber called %d times, want 1", len(received))
+	}
+	current := reloader.Current()
+	if current.Logging.Level != "debug" || current.Cache.OrderTTL != time.Minute {
+		t.Errorf("reloadable settings not applied: %+v %+v", current.Logging, current.Cache)
+	}
+	if current.DB.Host != cfg.DB.Host {
+		t.Errorf("db.host = %q, want %q until a restart", current.DB.Host, cfg.DB.Host)
+	}
+
+	rewrite("logging:\n  level: loud\n")
+	if err = reloader.Reload("test"); err == nil {
+		t.Fatalf("expected an invalid config to be rejected")
+	}
+	if len(received) != 1 || reloader.Current().Logging.Level != "debug" {
+		t.Errorf("rejected config replaced the current one")
+	}
+
+	rewrite("logging:\n  level: debug\ncache:\n  order_ttl: 1m\n")
+	if err = reloader.Reload("test"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(received) != 1 {
+		t.Errorf("subscriber called for a reload that changed nothing reloadable")
+	}
+}
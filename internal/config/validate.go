@@ -0,0 +1,197 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap/zapcore"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// bcrypt's cost limits, repeated here so config does not depend on bcrypt.
+const (
+	minBcryptCost = 4
+	maxBcryptCost = 31
+)
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// validator collects every problem, so a broken deployment is fixed in one
+// round instead of one error per restart.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) fail(key, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) port(key, port string) {
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		v.fail(key, "%q is not a port number", port)
+	}
+}
+
+func (v *validator) required(key, value string) {
+	if value == "" {
+		v.fail(key, "is required")
+	}
+}
+
+func (v *validator) positive(key string, value int64) {
+	if value <= 0 {
+		v.fail(key, "must be positive")
+	}
+}
+
+func (v *validator) url(key, value string) {
+	if value == "" {
+		return
+	}
+	if u, err := url.Parse(value); err != nil || !u.IsAbs() {
+		v.fail(key, "%q is not an absolute URL", value)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(v.errs...))
+	}
+	return nil
+}
+
+// Validate checks every value the server uses and reports all problems at
+// once.
+func (c Config) Validate() error {
+	var v validator
+	c.validateServer(&v)
+	c.validateDB(&v)
+	c.validateRedis(&v)
+	c.validateAuth(&v)
+	c.validateNotifier(&v)
+	c.validateLogging(&v)
+	c.validateCache(&v)
+	return v.err()
+}
+
+// ValidateMigrate checks only what the migrate command uses, the database
+// and logging, so migrations run without the server's secrets.
+func (c Config) ValidateMigrate() error {
+	var v validator
+	c.validateDB(&v)
+	c.validateLogging(&v)
+	return v.err()
+}
+
+func (c Config) validateServer(v *validator) {
+	v.port("server.port", c.Server.Port)
+	if c.Server.ShutdownDelay < 0 {
+		v.fail("server.shutdown_delay", "must not be negative")
+	}
+	v.positive("server.shutdown_timeout", int64(c.Server.ShutdownTimeout))
+}
+
+func (c Config) validateDB(v *validator) {
+	v.required("db.host", c.DB.Host)
+	v.port("db.port", c.DB.Port)
+	v.required("db.username", c.DB.Username)
+	v.required("db.dbname", c.DB.DBName)
+	if !slices.Contains(sslModes, c.DB.SSLMode) {
+		v.fail("db.sslmode", "%q is not one of %v", c.DB.SSLMode, sslModes)
+	}
+}
+
+func (c Config) validateRedis(v *validator) {
+	if !c.Redis.Enable {
+		return
+	}
+	if c.Redis.Addr == "" {
+		v.required("redis.host", c.Redis.Host)
+		v.port("redis.port", c.Redis.Port)
+	}
+	if c.Redis.DB < 0 {
+		v.fail("redis.db", "must not be negative")
+	}
+}
+
+func (c Config) validateAuth(v *validator) {
+	v.url("auth.password_reset_url", c.Auth.PasswordResetURL)
+	v.url("auth.email_verification_url", c.Auth.EmailVerificationURL)
+	if c.Auth.BcryptCost != 0 && (c.Auth.BcryptCost < minBcryptCost || c.Auth.BcryptCost > maxBcryptCost) {
+		v.fail("auth.bcrypt_cost", "must be between %d and %d", minBcryptCost, maxBcryptCost)
+	}
+	v.required("auth.mfa.issuer", c.Auth.MFA.Issuer)
+	for _, scope := range []struct {
+		name   string
+		policy LoginThrottlePolicy
+	}{
+		{"auth.login_throttle.username", c.Auth.LoginThrottle.Username},
+		{"auth.login_throttle.ip", c.Auth.LoginThrottle.IP},
+	} {
+		name, policy := scope.name, scope.policy
+		if policy.FreeAttempts < 0 || policy.LockoutThreshold < 0 {
+			v.fail(name, "attempt counts must not be negative")
+		}
+		if policy.BaseDelay < 0 || policy.MaxDelay < policy.BaseDelay {
+			v.fail(name, "max_delay must not be shorter than base_delay")
+		}
+		if policy.LockoutThreshold > 0 && policy.LockoutDuration <= 0 {
+			v.fail(name, "lockout_duration must be positive when lockout_threshold is set")
+		}
+		v.positive(name+".window", int64(policy.Window))
+	}
+
+	jwt := c.Auth.JWT
+	switch jwt.Algorithm {
+	case "HS256":
+		if jwt.Secret == "" {
+			v.fail("auth.jwt.secret", "is required for HS256; set SIGNING_KEY")
+		}
+	case "RS256", "EdDSA":
+		v.required("auth.jwt.signing_key_id", jwt.SigningKeyID)
+		if len(jwt.Keys) == 0 {
+			v.fail("auth.jwt.keys", "at least one key is required for %s", jwt.Algorithm)
+		}
+		for i, key := range jwt.Keys {
+			if key.ID == "" {
+				v.fail(fmt.Sprintf("auth.jwt.keys[%d].id", i), "is required")
+			}
+			if key.PrivateKeyFile == "" && key.PublicKeyFile == "" {
+				v.fail(fmt.Sprintf("auth.jwt.keys[%d]", i), "needs private_key_file or public_key_file")
+			}
+		}
+	default:
+		v.fail("auth.jwt.algorithm", "%q is not one of HS256, RS256, EdDSA", jwt.Algorithm)
+	}
+}
+
+func (c Config) validateNotifier(v *validator) {
+	switch c.Notifier.Driver {
+	case "log":
+	case "smtp":
+		v.required("notifier.smtp.host", c.Notifier.SMTP.Host)
+		v.port("notifier.smtp.port", c.Notifier.SMTP.Port)
+		v.required("notifier.smtp.from", c.Notifier.SMTP.From)
+	default:
+		v.fail("notifier.driver", "%q is not one of log, smtp", c.Notifier.Driver)
+	}
+}
+
+func (c Config) validateLogging(v *validator) {
+	if _, err := zapcore.ParseLevel(c.Logging.Level); err != nil {
+		v.fail("logging.level", "%q is not a log level", c.Logging.Level)
+	}
+	if c.Logging.Format != "json" && c.Logging.Format != "console" {
+		v.fail("logging.format", "%q is not one of json, console", c.Logging.Format)
+	}
+}
+
+func (c Config) validateCache(v *validator) {
+	v.positive("cache.user_ttl", int64(c.Cache.UserTTL))
+	v.positive("cache.order_ttl", int64(c.Cache.OrderTTL))
+	v.positive("cache.order_list_ttl", int64(c.Cache.OrderListTTL))
+	v.positive("cache.product_ttl", int64(c.Cache.ProductTTL))
+	v.positive("cache.product_list_ttl", int64(c.Cache.ProductListTTL))
+	v.positive("cache.api_key_ttl", int64(c.Cache.APIKeyTTL))
+}
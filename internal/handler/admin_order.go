@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"OrderKeeper/internal/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AdminOrdersQuery extends the customer order filters with the search
+// parameters available to staff.
+type AdminOrdersQuery struct {
+	GetOrdersQuery
+	UserID *int   `form:"user_id" binding:"omitempty,min=1"`
+	SKU    string `form:"sku" binding:"omitempty,max=64"`
+}
+
+func (q AdminOrdersQuery) Filter() models.OrderFilter {
+	filter := q.GetOrdersQuery.Filter()
+	filter.UserID = q.UserID
+	filter.SKU = q.SKU
+	return filter
+}
+
+func (h *Handler) adminGetOrders(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	adminId, err := getUserId(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal server error",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var query AdminOrdersQuery
+	if err = c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid query parameters",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	page, err := h.services.AdminOrder.SearchOrders(c.Request.Context(), query.Filter())
+	if err != nil {
+		h.logger.Error("failed to search orders",
+			zap.Int("admin_id", adminId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		if errors.Is(err, models.ErrInvalidOrderFilter) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid query parameters",
+				Code:    ErrCodeValidation,
+				Details: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get orders",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	h.logger.Info("admin orders retrieved successfully",
+		zap.Int("admin_id", adminId),
+		zap.Int("orders_count", len(page.Orders)),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusOK, GetOrdersResponse{
+		Orders:     page.Orders,
+		NextCursor: page.NextCursor,
+		Message:    "Orders retrieved successfully",
+	})
+}
+
+func (h *Handler) adminGetOrderById(c *gin.Context) {
+	orderId, ok := h.adminOrderIdParam(c)
+	if !ok {
+		return
+	}
+
+	order, err := h.services.AdminOrder.GetOrderByID(c.Request.Context(), orderId)
+	if err != nil {
+		h.logger.Error("failed to get order for admin",
+			zap.Int("order_id", orderId),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		if errors.Is(err, models.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Order not found",
+				Code:    ErrCodeNotFound,
+				Details: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get order",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	etag := orderETag(order.Version)
+	c.Header(etagHeader, etag)
+	if etagMatches(c.GetHeader(ifNoneMatchHeader), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
+func (h *Handler) adminUpdateOrder(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	adminId, err := getUserId(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal server error",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+	orderId, ok := h.adminOrderIdParam(c)
+	if !ok {
+		return
+	}
+
+	var input models.OrderUpdateInput
+	if err = c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+	if input.ExpectedVersion, err = parseIfMatch(c.GetHeader(ifMatchHeader)); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid If-Match header",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err = h.services.AdminOrder.UpdateOrder(c.Request.Context(), adminId, orderId, input); err != nil {
+		h.logger.Error("failed to update order as admin",
+			zap.Int("admin_id", adminId),
+			zap.Int("order_id", orderId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondOrderUpdateError(c, err)
+		return
+	}
+
+	h.logger.Info("order updated by admin",
+		zap.Int("admin_id", adminId),
+		zap.Int("order_id", orderId),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusOK, UpdateOrderResponse{
+		Message: "Order updated successfully",
+	})
+}
+
+func (h *Handler) adminGetOrderHistory(c *gin.Context) {
+	orderId, ok := h.adminOrderIdParam(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.services.AdminOrder.GetOrderHistory(c.Request.Context(), orderId)
+	if err != nil {
+		h.logger.Error("failed to get order history for admin",
+			zap.Int("order_id", orderId),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		if errors.Is(err, models.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Order not found",
+				Code:    ErrCodeNotFound,
+				Details: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get order history",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, GetOrderHistoryResponse{
+		OrderID: orderId,
+		History: history,
+	})
+}
+
+func (h *Handler) adminOrderIdParam(c *gin.Context) (int, bool) {
+	orderId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid order ID",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return 0, false
+	}
+	return orderId, true
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"OrderKeeper/internal/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string         `json:"name" binding:"required,max=100"`
+	Scopes    []models.Scope `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time     `json:"expires_at"`
+}
+
+type APIKeyResponse struct {
+	ID         int            `json:"id"`
+	Name       string         `json:"name"`
+	Prefix     string         `json:"prefix"`
+	Scopes     []models.Scope `json:"scopes"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time     `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+type GetAPIKeysResponse struct {
+	APIKeys []APIKeyResponse `json:"api_keys"`
+	Message string           `json:"message"`
+}
+
+type RevokeAPIKeyResponse struct {
+	Message string `json:"message"`
+}
+
+func newAPIKeyResponse(key models.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+func (h *Handler) createAPIKey(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	userId, ok := h.userIdParam(c)
+	if !ok {
+		return
+	}
+
+	var input CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Warn("api key validation failed",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", clientIP),
+			zap.String("error", err.Error()),
+		)
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	key, secret, err := h.services.APIKey.CreateAPIKey(c.Request.Context(), userId, models.APIKeyInput{
+		Name:      input.Name,
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+	})
+	if err != nil {
+		h.logger.Warn("api key creation failed",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondAPIKeyError(c, err, "Failed to create api key")
+		return
+	}
+
+	h.logger.Info("api key created successfully",
+		zap.Int("user_id", userId),
+		zap.Int("api_key_id", key.ID),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusCreated),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		APIKeyResponse: newAPIKeyResponse(key),
+		Key:            secret,
+		Message:        "Store the key safely; it is shown only once",
+	})
+}
+
+func (h *Handler) getAPIKeys(c *gin.Context) {
+	userId, ok := h.userIdParam(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.services.APIKey.GetAPIKeys(c.Request.Context(), userId)
+	if err != nil {
+		h.logger.Error("failed to get api keys",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		h.respondAPIKeyError(c, err, "Failed to get api keys")
+		return
+	}
+
+	response := make([]APIKeyResponse, len(keys))
+	for i, key := range keys {
+		response[i] = newAPIKeyResponse(key)
+	}
+
+	c.JSON(http.StatusOK, GetAPIKeysResponse{
+		APIKeys: response,
+		Message: "API keys retrieved successfully",
+	})
+}
+
+func (h *Handler) revokeAPIKey(c *gin.Context) {
+	userId, ok := h.userIdParam(c)
+	if !ok {
+		return
+	}
+
+	keyId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid api key ID",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err = h.services.APIKey.RevokeAPIKey(c.Request.Context(), userId, keyId); err != nil {
+		h.logger.Warn("api key revocation failed",
+			zap.Int("user_id", userId),
+			zap.Int("api_key_id", keyId),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		h.respondAPIKeyError(c, err, "Failed to revoke api key")
+		return
+	}
+
+	h.logger.Info("api key revoked successfully",
+		zap.Int("user_id", userId),
+		zap.Int("api_key_id", keyId),
+		zap.String("client_ip", c.ClientIP()),
+	)
+
+	c.JSON(http.StatusOK, RevokeAPIKeyResponse{
+		Message: "API key revoked",
+	})
+}
+
+func (h *Handler) respondAPIKeyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidAPIKeyInput):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrAPIKeyNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "API key not found",
+			Code:    ErrCodeNotFound,
+			Details: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: message,
+			Code:  ErrCodeInternal,
+		})
+	}
+}
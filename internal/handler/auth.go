@@ -2,6 +2,7 @@ package handler
 
 import (
 	"OrderKeeper/internal/models"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"net/http"
@@ -24,14 +25,57 @@ type SignInRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
-type SignInResponse struct {
-	Token   string `json:"token"`
+type TokenResponse struct {
+	Token        string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+	Message      string    `json:"message"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutResponse struct {
+	Message string `json:"message"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type PasswordResponse struct {
+	Message string `json:"message"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type VerifyEmailResponse struct {
 	Message string `json:"message"`
 }
 
 const (
-	ErrCodeValidation = "VALIDATION_ERROR"
-	ErrCodeInternal   = "INTERNAL_ERROR"
+	ErrCodeValidation          = "VALIDATION_ERROR"
+	ErrCodeInternal            = "INTERNAL_ERROR"
+	ErrCodeInvalidRefreshToken = "INVALID_REFRESH_TOKEN"
+	ErrCodeInvalidResetToken   = "INVALID_RESET_TOKEN"
+	ErrCodeInvalidVerification = "INVALID_VERIFICATION_TOKEN"
+	ErrCodeAlreadyVerified     = "EMAIL_ALREADY_VERIFIED"
+	ErrCodeTooManyRequests     = "TOO_MANY_REQUESTS"
+	ErrCodeInvalidCredentials  = "INVALID_CREDENTIALS"
+	ErrCodeUsernameTaken       = "USERNAME_TAKEN"
+	ErrCodeEmailTaken          = "EMAIL_TAKEN"
 )
 
 func (h *Handler) signUp(c *gin.Context) {
@@ -87,14 +131,25 @@ func (h *Handler) signUp(c *gin.Context) {
 			zap.Duration("duration", time.Since(start)),
 		)
 
-		statusCode := http.StatusInternalServerError
-		errorCode := ErrCodeInternal
-		errorMsg := "Failed to create user"
-
-		c.JSON(statusCode, ErrorResponse{
-			Error: errorMsg,
-			Code:  errorCode,
-		})
+		switch {
+		case errors.Is(err, models.ErrUsernameTaken):
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "Username is already taken",
+				Code:    ErrCodeUsernameTaken,
+				Details: err.Error(),
+			})
+		case errors.Is(err, models.ErrEmailTaken):
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "Email is already registered",
+				Code:    ErrCodeEmailTaken,
+				Details: err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error: "Failed to create user",
+				Code:  ErrCodeInternal,
+			})
+		}
 		return
 	}
 
@@ -145,8 +200,25 @@ func (h *Handler) signIn(c *gin.Context) {
 		zap.String("username", input.Username),
 	)
 
-	token, err := h.services.Authorization.GenerateToken(c.Request.Context(), input.Username, input.Password)
+	result, err := h.services.Authorization.GenerateToken(c.Request.Context(), input.Username, input.Password, clientIP)
 	if err != nil {
+		var retryErr *models.RetryAfterError
+		if errors.As(err, &retryErr) {
+			respondTooManyRequests(c, retryErr, "Too many failed sign-in attempts")
+			return
+		}
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			h.logger.Warn("sign-in rejected",
+				zap.String("client_ip", clientIP),
+				zap.String("username", input.Username),
+				zap.Duration("duration", time.Since(start)),
+			)
+			c.JSON(http.StatusUnauthorized, ErrorResponse{
+				Error: "Invalid username or password",
+				Code:  ErrCodeInvalidCredentials,
+			})
+			return
+		}
 		h.logger.Error("generate token failed",
 			zap.String("client_ip", clientIP),
 			zap.String("username", input.Username),
@@ -163,14 +235,324 @@ func (h *Handler) signIn(c *gin.Context) {
 		return
 	}
 
+	if result.MFAChallenge != nil {
+		h.logger.Info("mfa challenge issued",
+			zap.String("client_ip", clientIP),
+			zap.String("username", input.Username),
+			zap.Int("status_code", http.StatusOK),
+			zap.Duration("duration", time.Since(start)),
+		)
+		c.JSON(http.StatusOK, MFAChallengeResponse{
+			MFARequired: true,
+			MFAToken:    result.MFAChallenge.Token,
+			ExpiresAt:   result.MFAChallenge.ExpiresAt,
+			Message:     "Enter a code from your authenticator app to finish signing in",
+		})
+		return
+	}
+
 	h.logger.Info("generate token passed",
 		zap.String("client_ip", clientIP),
 		zap.String("username", input.Username),
 		zap.Int("status_code", http.StatusOK),
 		zap.Duration("duration", time.Since(start)),
 	)
-	c.JSON(http.StatusOK, SignInResponse{
-		Token:   token,
-		Message: "Token generated successfully",
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:        result.Tokens.AccessToken,
+		ExpiresAt:    result.Tokens.AccessTokenExpiresAt,
+		RefreshToken: result.Tokens.RefreshToken,
+		Message:      "Token generated successfully",
 	})
 }
+
+func (h *Handler) refreshToken(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	var input RefreshTokenRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	tokens, err := h.services.Authorization.RefreshToken(c.Request.Context(), input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidRefreshToken) || errors.Is(err, models.ErrRefreshTokenReused) {
+			h.logger.Warn("refresh token rejected",
+				zap.String("client_ip", clientIP),
+				zap.Error(err),
+				zap.Duration("duration", time.Since(start)),
+			)
+			c.JSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Invalid refresh token",
+				Code:    ErrCodeInvalidRefreshToken,
+				Details: err.Error(),
+			})
+			return
+		}
+		h.logger.Error("refresh token failed",
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to refresh token",
+			Code:  ErrCodeInternal,
+		})
+		return
+	}
+
+	h.logger.Info("token refreshed",
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("duration", time.Since(start)),
+	)
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:        tokens.AccessToken,
+		ExpiresAt:    tokens.AccessTokenExpiresAt,
+		RefreshToken: tokens.RefreshToken,
+		Message:      "Token refreshed successfully",
+	})
+}
+
+func (h *Handler) logout(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	claims, err := getTokenClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Unauthorized",
+			Code:    InvalidToken,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	var input LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err = c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid input data",
+				Code:    ErrCodeValidation,
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+
+	if err = h.services.Authorization.Logout(c.Request.Context(), claims, input.RefreshToken); err != nil {
+		if errors.Is(err, models.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Invalid refresh token",
+				Code:    ErrCodeInvalidRefreshToken,
+				Details: err.Error(),
+			})
+			return
+		}
+		h.logger.Error("logout failed",
+			zap.Int("user_id", claims.UserID),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to log out",
+			Code:  ErrCodeInternal,
+		})
+		return
+	}
+
+	h.logger.Info("user logged out",
+		zap.Int("user_id", claims.UserID),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("duration", time.Since(start)),
+	)
+	c.JSON(http.StatusOK, LogoutResponse{
+		Message: "Logged out successfully",
+	})
+}
+
+func (h *Handler) forgotPassword(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	var input ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.Authorization.ForgotPassword(c.Request.Context(), input.Email, clientIP); err != nil {
+		var retryErr *models.RetryAfterError
+		if errors.As(err, &retryErr) {
+			respondTooManyRequests(c, retryErr, "Too many password reset requests")
+			return
+		}
+		h.logger.Error("failed to request password reset",
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to request password reset",
+			Code:  ErrCodeInternal,
+		})
+		return
+	}
+
+	h.logger.Info("password reset requested",
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusAccepted),
+		zap.Duration("duration", time.Since(start)),
+	)
+	c.JSON(http.StatusAccepted, PasswordResponse{
+		Message: "If an account with this email exists, a password reset link has been sent",
+	})
+}
+
+func (h *Handler) resetPassword(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	var input ResetPasswordRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.Authorization.ResetPassword(c.Request.Context(), input.Token, input.Password); err != nil {
+		if errors.Is(err, models.ErrInvalidResetToken) {
+			h.logger.Warn("password reset rejected",
+				zap.String("client_ip", clientIP),
+				zap.Duration("duration", time.Since(start)),
+			)
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid or expired reset token",
+				Code:    ErrCodeInvalidResetToken,
+				Details: err.Error(),
+			})
+			return
+		}
+		h.logger.Error("password reset failed",
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to reset password",
+			Code:  ErrCodeInternal,
+		})
+		return
+	}
+
+	h.logger.Info("password reset",
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("duration", time.Since(start)),
+	)
+	c.JSON(http.StatusOK, PasswordResponse{
+		Message: "Password has been reset, please sign in again",
+	})
+}
+
+func (h *Handler) verifyEmail(c *gin.Context) {
+	clientIP := c.ClientIP()
+
+	var input VerifyEmailRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err := h.services.Authorization.VerifyEmail(c.Request.Context(), input.Token); err != nil {
+		if errors.Is(err, models.ErrInvalidVerificationToken) {
+			h.logger.Warn("email verification rejected",
+				zap.String("client_ip", clientIP),
+			)
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid or expired verification token",
+				Code:    ErrCodeInvalidVerification,
+				Details: err.Error(),
+			})
+			return
+		}
+		h.logger.Error("email verification failed",
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to verify email",
+			Code:  ErrCodeInternal,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, VerifyEmailResponse{
+		Message: "Email verified successfully",
+	})
+}
+
+func (h *Handler) resendVerificationEmail(c *gin.Context) {
+	clientIP := c.ClientIP()
+
+	userId, err := getUserId(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal server error",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err = h.services.Authorization.ResendVerificationEmail(c.Request.Context(), userId); err != nil {
+		var retryErr *models.RetryAfterError
+		switch {
+		case errors.Is(err, models.ErrEmailAlreadyVerified):
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "Email is already verified",
+				Code:    ErrCodeAlreadyVerified,
+				Details: err.Error(),
+			})
+		case errors.As(err, &retryErr):
+			respondTooManyRequests(c, retryErr, "Too many verification emails requested")
+		default:
+			h.logger.Error("failed to resend verification email",
+				zap.Int("user_id", userId),
+				zap.String("client_ip", clientIP),
+				zap.Error(err),
+			)
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error: "Failed to send verification email",
+				Code:  ErrCodeInternal,
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, VerifyEmailResponse{
+		Message: "Verification email sent",
+	})
+}
+
+func (h *Handler) getJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.services.Authorization.JWKS())
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	etagHeader        = "ETag"
+	ifMatchHeader     = "If-Match"
+	ifNoneMatchHeader = "If-None-Match"
+)
+
+const (
+	ErrCodePreconditionFailed = "PRECONDITION_FAILED"
+)
+
+// orderETag is the strong entity tag of an order version.
+func orderETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// parseIfMatch returns the order version required by an If-Match header, or
+// nil when the header is absent or "*".
+func parseIfMatch(header string) (*int, error) {
+	header = strings.TrimSpace(header)
+	if header == IsEmptyString || header == "*" {
+		return nil, nil
+	}
+
+	tag, err := strconv.Unquote(header)
+	if err != nil {
+		return nil, fmt.Errorf("If-Match must be a single strong entity tag")
+	}
+	version, err := strconv.Atoi(tag)
+	if err != nil || version <= 0 {
+		return nil, fmt.Errorf("If-Match does not reference an order version")
+	}
+	return &version, nil
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using the
+// weak comparison that RFC 9110 prescribes for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -2,20 +2,29 @@ package handler
 
 import (
 	"OrderKeeper/internal/handler/metrics"
+	"OrderKeeper/internal/models"
 	"OrderKeeper/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
+	"sync/atomic"
 )
 
 type Handler struct {
 	services *service.Service
-	logger   *zap.Logger
+	checks   []HealthCheck
+	draining atomic.Bool
+	// readiness is the status /readyz last reported.
+	readiness atomic.Value
+	logger    *zap.Logger
 }
 
-func NewHandler(services *service.Service, logger *zap.Logger) *Handler {
+// NewHandler builds the HTTP handlers. checks are the dependencies that
+// /readyz reports on.
+func NewHandler(services *service.Service, checks []HealthCheck, logger *zap.Logger) *Handler {
 	return &Handler{
 		services: services,
+		checks:   checks,
 		logger:   logger,
 	}
 }
@@ -29,33 +38,81 @@ func (h *Handler) InitRoutes() *gin.Engine {
 	r.Use(gin.Logger())
 	r.Use(metrics.MetricsMiddleware())
 
-	r.GET("/health", h.healthCheck)
+	r.GET("/livez", h.livez)
+	r.GET("/readyz", h.readyz)
+	// Kept for clients of the old endpoint; it reports liveness.
+	r.GET("/health", h.livez)
 
 	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	r.GET("/.well-known/jwks.json", h.getJWKS)
+
 	auth := r.Group("/auth")
 	{
 		auth.POST("/sign-up", h.signUp)
 		auth.POST("/sign-in", h.signIn)
+		auth.POST("/sign-in/mfa", h.signInMFA)
+		auth.POST("/refresh", h.refreshToken)
+		auth.POST("/logout", h.userIdentity, h.requireSession, h.logout)
+		auth.POST("/password/forgot", h.forgotPassword)
+		auth.POST("/password/reset", h.resetPassword)
+		auth.POST("/verify-email", h.verifyEmail)
+		auth.POST("/verify-email/resend", h.userIdentity, h.requireSession, h.resendVerificationEmail)
+
+		mfa := auth.Group("/mfa", h.userIdentity, h.requireSession)
+		{
+			mfa.POST("/enroll", h.enrollMFA)
+			mfa.POST("/confirm", h.confirmMFA)
+			mfa.POST("/disable", h.disableMFA)
+		}
+	}
+
+	me := r.Group("/me", h.userIdentity, h.requireSession)
+	{
+		me.GET("", h.getProfile)
+		me.PATCH("", h.updateProfile)
+		me.POST("/password", h.changePassword)
+		me.DELETE("", h.deleteAccount)
+		me.GET("/api-keys", h.getAPIKeys)
+		me.POST("/api-keys", h.createAPIKey)
+		me.DELETE("/api-keys/:id", h.revokeAPIKey)
 	}
 
+	products := r.Group("/products")
+	{
+		products.GET("/", h.getProducts)
+		products.GET("/:id", h.getProductById)
+	}
+
+	catalog := r.Group("/products", h.userIdentity, h.requireRole(models.RoleAdmin), h.requireScope(models.ScopeProductsWrite))
+	{
+		catalog.POST("/", h.createProduct)
+		catalog.PUT("/:id", h.updateProduct)
+		catalog.POST("/:id/archive", h.archiveProduct)
+		catalog.GET("/:id/stock", h.getProductStock)
+		catalog.PUT("/:id/stock", h.setProductStock)
+	}
+
+	readOrders := h.requireScope(models.ScopeOrdersRead)
+	writeOrders := h.requireScope(models.ScopeOrdersWrite)
+
 	order := r.Group("/order", h.userIdentity)
 	{
-		order.POST("/", h.createOrder)
-		order.GET("/", h.getOrders)
-		order.GET("/:id", h.getOrderById)
-		order.PUT("/:id", h.updateOrder)
-		order.DELETE("/:id", h.deleteOrder)
+		order.POST("/", writeOrders, h.idempotent, h.createOrder)
+		order.GET("/", readOrders, h.getOrders)
+		order.GET("/:id", readOrders, h.getOrderById)
+		order.GET("/:id/history", readOrders, h.getOrderHistory)
+		order.PUT("/:id", writeOrders, h.idempotent, h.updateOrder)
+		order.DELETE("/:id", writeOrders, h.idempotent, h.deleteOrder)
 	}
 
-	return r
-}
+	admin := r.Group("/admin", h.userIdentity, h.requireRole(models.RoleSupport, models.RoleAdmin))
+	{
+		admin.GET("/orders", readOrders, h.adminGetOrders)
+		admin.GET("/orders/:id", readOrders, h.adminGetOrderById)
+		admin.GET("/orders/:id/history", readOrders, h.adminGetOrderHistory)
+		admin.PUT("/orders/:id", writeOrders, h.idempotent, h.adminUpdateOrder)
+	}
 
-func (h *Handler) healthCheck(c *gin.Context) {
-	h.logger.Debug("Health check requested")
-	c.JSON(200, gin.H{
-		"status":    "ok",
-		"service":   "myapp",
-		"timestamp": gin.H{},
-	})
+	return r
 }
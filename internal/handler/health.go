@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const serviceName = "order-keeper"
+
+// readinessCheckTimeout bounds each dependency check, so a hung dependency
+// answers the probe with "down" instead of timing it out.
+const readinessCheckTimeout = 2 * time.Second
+
+const (
+	HealthStatusOK           = "ok"
+	HealthStatusReady        = "ready"
+	HealthStatusDegraded     = "degraded"
+	HealthStatusNotReady     = "not_ready"
+	HealthStatusShuttingDown = "shutting_down"
+	HealthStatusUp           = "up"
+	HealthStatusDown         = "down"
+)
+
+// HealthCheck is a dependency that /readyz checks. When a Critical
+// dependency is down the service reports not ready; others only degrade it.
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+type LivenessResponse struct {
+	Status    string    `json:"status"`
+	Service   string    `json:"service"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type ReadinessResponse struct {
+	Status    string                           `json:"status"`
+	Timestamp time.Time                        `json:"timestamp"`
+	Checks    map[string]DependencyCheckResult `json:"checks"`
+}
+
+type DependencyCheckResult struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// StartDraining makes /readyz fail from now on, so load balancers stop
+// sending new requests before the server shuts down.
+func (h *Handler) StartDraining() {
+	if !h.draining.Swap(true) {
+		h.logger.Info("readiness switched to shutting down")
+	}
+}
+
+// livez reports that the process is running. It checks no dependencies, so
+// an outage of Postgres or Redis does not get the process restarted.
+func (h *Handler) livez(c *gin.Context) {
+	c.JSON(http.StatusOK, LivenessResponse{
+		Status:    HealthStatusOK,
+		Service:   serviceName,
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// readyz checks every dependency concurrently and answers 503 while a
+// critical one is down or the server is shutting down.
+func (h *Handler) readyz(c *gin.Context) {
+	response := ReadinessResponse{
+		Status:    HealthStatusReady,
+		Timestamp: time.Now().UTC(),
+		Checks:    h.runHealthChecks(c.Request.Context()),
+	}
+
+	for _, check := range h.checks {
+		if response.Checks[check.Name].Status == HealthStatusUp {
+			continue
+		}
+		if check.Critical {
+			response.Status = HealthStatusNotReady
+			break
+		}
+		response.Status = HealthStatusDegraded
+	}
+	if h.draining.Load() {
+		response.Status = HealthStatusShuttingDown
+	}
+
+	status := http.StatusOK
+	if response.Status == HealthStatusNotReady || response.Status == HealthStatusShuttingDown {
+		status = http.StatusServiceUnavailable
+	}
+	h.logReadinessChange(response, status)
+	c.JSON(status, response)
+}
+
+// logReadinessChange logs when the readiness status differs from the one of
+// the previous probe. Probes run every few seconds, so logging each failed
+// one would flood the logs during an outage.
+func (h *Handler) logReadinessChange(response ReadinessResponse, status int) {
+	if previous, _ := h.readiness.Swap(response.Status).(string); previous == response.Status {
+		h.logger.Debug("readiness checked",
+			zap.String("status", response.Status),
+			zap.Any("checks", response.Checks),
+		)
+		return
+	}
+
+	fields := []zap.Field{
+		zap.String("status", response.Status),
+		zap.Any("checks", response.Checks),
+	}
+	if status != http.StatusOK {
+		h.logger.Warn("readiness changed, not accepting traffic", fields...)
+		return
+	}
+	h.logger.Info("readiness changed", fields...)
+}
+
+func (h *Handler) runHealthChecks(ctx context.Context) map[string]DependencyCheckResult {
+	results := make(map[string]DependencyCheckResult, len(h.checks))
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check.Check(checkCtx)
+			result := DependencyCheckResult{
+				Status:    HealthStatusUp,
+				Critical:  check.Critical,
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Status = HealthStatusDown
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[check.Name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
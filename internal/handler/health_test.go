@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func healthCheck(name string, critical bool, err error) HealthCheck {
+	return HealthCheck{
+		Name:     name,
+		Critical: critical,
+		Check:    func(ctx context.Context) error { return err },
+	}
+}
+
+func probeReadiness(t *testing.T, h *Handler) (int, ReadinessResponse) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/readyz", h.readyz)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var response ReadinessResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode readiness response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, response
+}
+
+func TestReadyzAggregatesChecks(t *testing.T) {
+	errDown := errors.New("connection refused")
+
+	tests := []struct {
+		name       string
+		checks     []HealthCheck
+		draining   bool
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name: "all up",
+			checks: []HealthCheck{
+				healthCheck("postgres", true, nil),
+				healthCheck("redis", false, nil),
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: HealthStatusReady,
+		},
+		{
+			name: "optional dependency down",
+			checks: []HealthCheck{
+				healthCheck("postgres", true, nil),
+				healthCheck("redis", false, errDown),
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: HealthStatusDegraded,
+		},
+		{
+			name: "critical dependency down",
+			checks: []HealthCheck{
+				healthCheck("redis", false, errDown),
+				healthCheck("postgres", true, errDown),
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: HealthStatusNotReady,
+		},
+		{
+			name: "draining",
+			checks: []HealthCheck{
+				healthCheck("postgres", true, nil),
+			},
+			draining:   true,
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: HealthStatusShuttingDown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(nil, tt.checks, zap.NewNop())
+			if tt.draining {
+				h.StartDraining()
+			}
+
+			code, response := probeReadiness(t, h)
+			if code != tt.wantCode || response.Status != tt.wantStatus {
+				t.Fatalf("readyz = %d %q, want %d %q", code, response.Status, tt.wantCode, tt.wantStatus)
+			}
+			for _, check := range tt.checks {
+				result, ok := response.Checks[check.Name]
+				if !ok {
+					t.Fatalf("check %q missing from the response", check.Name)
+				}
+				if result.Critical != check.Critical {
+					t.Fatalf("check %q critical = %v, want %v", check.Name, result.Critical, check.Critical)
+				}
+			}
+		})
+	}
+}
+
+func TestReadyzLogsOnlyStatusChanges(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	var postgresErr error
+	h := NewHandler(nil, []HealthCheck{{
+		Name:     "postgres",
+		Critical: true,
+		Check:    func(ctx context.Context) error { return postgresErr },
+	}}, zap.New(core))
+
+	postgresErr = errors.New("connection refused")
+	for range 3 {
+		probeReadiness(t, h)
+	}
+	if n := logs.FilterMessage("readiness changed, not accepting traffic").Len(); n != 1 {
+		t.Fatalf("logged %d failures over three failed probes, want 1", n)
+	}
+
+	postgresErr = nil
+	for range 2 {
+		probeReadiness(t, h)
+	}
+	if n := logs.FilterMessage("readiness changed").Len(); n != 1 {
+		t.Fatalf("logged %d recoveries over two ready probes, want 1", n)
+	}
+	if n := logs.Len(); n != 2 {
+		t.Fatalf("logged %d entries, want 2", n)
+	}
+}
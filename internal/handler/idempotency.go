@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"OrderKeeper/internal/models"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	ErrCodeIdempotencyReuse   = "IDEMPOTENCY_KEY_MISMATCH"
+	ErrCodeIdempotencyPending = "IDEMPOTENCY_KEY_IN_PROGRESS"
+)
+
+// responseRecorder keeps a copy of the response body so it can be stored
+// for replays.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(data string) (int, error) {
+	r.body.WriteString(data)
+	return r.ResponseWriter.WriteString(data)
+}
+
+// idempotent honours the Idempotency-Key header on mutating routes. The first
+// request with a key is processed and its response stored; retries with the
+// same key and body get the stored response, while reusing the key with a
+// different request is rejected with 422. Server errors free the key so the
+// client can retry. Must run after userIdentity.
+func (h *Handler) idempotent(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == IsEmptyString {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+			Error: "Idempotency key is too long",
+			Code:  ErrCodeValidation,
+		})
+		return
+	}
+
+	userId, err := getUserId(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal server error",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to read request body",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, c.GetHeader(ifMatchHeader), body)
+	record, replay, err := h.services.Idempotency.Begin(c.Request.Context(), userId, key, fingerprint)
+	if err != nil {
+		h.logger.Warn("idempotency check failed",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		switch {
+		case errors.Is(err, models.ErrIdempotencyKeyMismatch):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ErrorResponse{
+				Error:   "Idempotency key was already used for a different request",
+				Code:    ErrCodeIdempotencyReuse,
+				Details: err.Error(),
+			})
+		case errors.Is(err, models.ErrIdempotencyKeyInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, ErrorResponse{
+				Error:   "A request with this idempotency key is still in progress",
+				Code:    ErrCodeIdempotencyPending,
+				Details: err.Error(),
+			})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to check idempotency key",
+				Code:    ErrCodeInternal,
+				Details: err.Error(),
+			})
+		}
+		return
+	}
+	if replay {
+		c.Header(idempotentReplayedHeader, "true")
+		c.Data(record.StatusCode, "application/json; charset=utf-8", record.ResponseBody)
+		c.Abort()
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	// The client may be gone by now; the outcome must be stored regardless.
+	ctx := context.WithoutCancel(c.Request.Context())
+	if recorder.Status() >= http.StatusInternalServerError {
+		if err = h.services.Idempotency.Abandon(ctx, userId, key); err != nil {
+			h.logger.Error("failed to release idempotency key", zap.Int("user_id", userId), zap.Error(err))
+		}
+		return
+	}
+
+	record.StatusCode = recorder.Status()
+	record.ResponseBody = recorder.body.Bytes()
+	if err = h.services.Idempotency.Complete(ctx, record); err != nil {
+		h.logger.Error("failed to store idempotent response", zap.Int("user_id", userId), zap.Error(err))
+	}
+}
+
+func requestFingerprint(method, path, ifMatch string, body []byte) string {
+	hash := sha256.New()
+	for _, part := range []string{method, path, ifMatch} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{'\n'})
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
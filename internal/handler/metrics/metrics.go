@@ -75,6 +75,22 @@ var (
 			Help: "Total number of user registrations",
 		},
 	)
+
+	loginLockoutsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_lockouts_total",
+			Help: "Total number of sign-in lockouts, by throttling scope",
+		},
+		[]string{"scope"},
+	)
+
+	loginThrottledTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_throttled_total",
+			Help: "Total number of sign-in attempts rejected while backing off or locked out",
+		},
+		[]string{"scope"},
+	)
 )
 
 func MetricsMiddleware() gin.HandlerFunc {
@@ -123,6 +139,14 @@ func RecordUserRegistration() {
 	userRegistrationsTotal.Inc()
 }
 
+func RecordLoginLockout(scope string) {
+	loginLockoutsTotal.WithLabelValues(scope).Inc()
+}
+
+func RecordLoginThrottled(scope string) {
+	loginThrottledTotal.WithLabelValues(scope).Inc()
+}
+
 func UpdateDatabaseConnections(active, idle int) {
 	databaseConnectionsActive.Set(float64(active))
 	databaseConnectionsIdle.Set(float64(idle))
@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"OrderKeeper/internal/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+type MFAChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Message     string    `json:"message"`
+}
+
+type MFASignInRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFAEnrolmentResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	Message    string `json:"message"`
+}
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	Message       string   `json:"message"`
+}
+
+type MFAResponse struct {
+	Message string `json:"message"`
+}
+
+const (
+	ErrCodeInvalidMFACode      = "INVALID_MFA_CODE"
+	ErrCodeInvalidMFAChallenge = "INVALID_MFA_CHALLENGE"
+	ErrCodeMFAAlreadyEnabled   = "MFA_ALREADY_ENABLED"
+	ErrCodeMFANotEnabled       = "MFA_NOT_ENABLED"
+	ErrCodeMFANotEnrolled      = "MFA_NOT_ENROLLED"
+)
+
+func (h *Handler) signInMFA(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	var input MFASignInRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	tokens, err := h.services.Authorization.CompleteMFASignIn(c.Request.Context(), input.MFAToken, input.Code, clientIP)
+	if err != nil {
+		h.logger.Warn("mfa sign-in failed",
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondMFAError(c, err, "Failed to complete sign-in")
+		return
+	}
+
+	h.logger.Info("mfa sign-in passed",
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("duration", time.Since(start)),
+	)
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:        tokens.AccessToken,
+		ExpiresAt:    tokens.AccessTokenExpiresAt,
+		RefreshToken: tokens.RefreshToken,
+		Message:      "Token generated successfully",
+	})
+}
+
+func (h *Handler) enrollMFA(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal server error",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	enrolment, err := h.services.Authorization.EnrollMFA(c.Request.Context(), userId)
+	if err != nil {
+		h.logger.Error("mfa enrolment failed",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		h.respondMFAError(c, err, "Failed to start mfa enrolment")
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAEnrolmentResponse{
+		Secret:     enrolment.Secret,
+		OTPAuthURI: enrolment.URI,
+		Message:    "Add the secret to your authenticator app and confirm it with a code",
+	})
+}
+
+func (h *Handler) confirmMFA(c *gin.Context) {
+	userId, input, ok := h.mfaCodeInput(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.services.Authorization.ConfirmMFA(c.Request.Context(), userId, input.Code, c.ClientIP())
+	if err != nil {
+		h.logger.Warn("mfa confirmation failed",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		h.respondMFAError(c, err, "Failed to enable mfa")
+		return
+	}
+
+	h.logger.Info("mfa enabled",
+		zap.Int("user_id", userId),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	c.JSON(http.StatusOK, MFARecoveryCodesResponse{
+		RecoveryCodes: codes,
+		Message:       "MFA enabled. Store the recovery codes safely; they are shown only once",
+	})
+}
+
+func (h *Handler) disableMFA(c *gin.Context) {
+	userId, input, ok := h.mfaCodeInput(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Authorization.DisableMFA(c.Request.Context(), userId, input.Code, c.ClientIP()); err != nil {
+		h.logger.Warn("mfa disable failed",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		h.respondMFAError(c, err, "Failed to disable mfa")
+		return
+	}
+
+	h.logger.Info("mfa disabled",
+		zap.Int("user_id", userId),
+		zap.String("client_ip", c.ClientIP()),
+	)
+	c.JSON(http.StatusOK, MFAResponse{
+		Message: "MFA disabled",
+	})
+}
+
+func (h *Handler) mfaCodeInput(c *gin.Context) (int, MFACodeRequest, bool) {
+	var input MFACodeRequest
+	userId, err := getUserId(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal server error",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return 0, input, false
+	}
+
+	if err = c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return 0, input, false
+	}
+	return userId, input, true
+}
+
+func (h *Handler) respondMFAError(c *gin.Context, err error, message string) {
+	var retryErr *models.RetryAfterError
+	switch {
+	case errors.As(err, &retryErr):
+		respondTooManyRequests(c, retryErr, "Too many failed sign-in attempts")
+	case errors.Is(err, models.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Invalid mfa code",
+			Code:    ErrCodeInvalidMFACode,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrInvalidMFAChallenge):
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Invalid or expired mfa token, sign in again",
+			Code:    ErrCodeInvalidMFAChallenge,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "MFA is already enabled",
+			Code:    ErrCodeMFAAlreadyEnabled,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrMFANotEnabled):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "MFA is not enabled",
+			Code:    ErrCodeMFANotEnabled,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrMFANotEnrolled):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Start mfa enrolment first",
+			Code:    ErrCodeMFANotEnrolled,
+			Details: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: message,
+			Code:  ErrCodeInternal,
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"OrderKeeper/internal/models"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"net/http"
 	"strings"
 )
@@ -12,18 +15,30 @@ const (
 )
 const (
 	authorizationHeader = "Authorization"
+	apiKeyScheme        = "ApiKey"
 	userCtx             = "userId"
+	tokenClaimsCtx      = "tokenClaims"
 )
 const (
 	InvalidHeader = "INVALID_HEADER"
 	InvalidToken  = "INVALID_TOKEN"
 	EmptyToken    = "EMPTY_TOKEN"
+	RevokedToken  = "TOKEN_REVOKED"
+	Forbidden     = "FORBIDDEN"
+
+	InvalidAPIKey     = "INVALID_API_KEY"
+	InsufficientScope = "INSUFFICIENT_SCOPE"
+	APIKeyNotAllowed  = "API_KEY_NOT_ALLOWED"
 )
 
+// userIdentity authenticates the request with either "Bearer <jwt>" or
+// "ApiKey <key>". API keys only carry their own scopes, so routes open to
+// them check scopes with requireScope; all other authenticated routes use
+// requireSession.
 func (h *Handler) userIdentity(c *gin.Context) {
 	header := c.GetHeader(authorizationHeader)
 	if header == IsEmptyString {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "token is missing",
 			Code:    EmptyToken,
 			Details: "token is missing",
@@ -33,7 +48,7 @@ func (h *Handler) userIdentity(c *gin.Context) {
 
 	headerParts := strings.Split(header, " ")
 	if len(headerParts) != 2 {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "Invalid authorization header",
 			Code:    InvalidToken,
 			Details: "Invalid authorization header",
@@ -41,17 +56,170 @@ func (h *Handler) userIdentity(c *gin.Context) {
 		return
 	}
 
-	userId, err := h.services.Authorization.ParseToken(c.Request.Context(), headerParts[1])
+	var (
+		claims models.AccessTokenClaims
+		ok     bool
+	)
+	if strings.EqualFold(headerParts[0], apiKeyScheme) {
+		claims, ok = h.apiKeyIdentity(c, headerParts[1])
+	} else {
+		claims, ok = h.tokenIdentity(c, headerParts[1])
+	}
+	if !ok {
+		return
+	}
+
+	c.Set(userCtx, claims.UserID)
+	c.Set(tokenClaimsCtx, claims)
+}
+
+func (h *Handler) tokenIdentity(c *gin.Context, token string) (models.AccessTokenClaims, bool) {
+	claims, err := h.services.Authorization.ParseToken(c.Request.Context(), token)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
 			Error:   "Invalid authorization header",
 			Code:    InvalidHeader,
 			Details: err.Error(),
 		})
+		return models.AccessTokenClaims{}, false
+	}
+
+	revoked, err := h.services.Authorization.IsTokenRevoked(c.Request.Context(), claims)
+	if err != nil {
+		h.logger.Error("failed to check token revocation",
+			zap.Int("user_id", claims.UserID),
+			zap.Error(err),
+		)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to verify token",
+			Code:  ErrCodeInternal,
+		})
+		return models.AccessTokenClaims{}, false
+	}
+	if revoked {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Token has been revoked",
+			Code:    RevokedToken,
+			Details: models.ErrTokenRevoked.Error(),
+		})
+		return models.AccessTokenClaims{}, false
+	}
+
+	return claims, true
+}
+
+func (h *Handler) apiKeyIdentity(c *gin.Context, key string) (models.AccessTokenClaims, bool) {
+	claims, err := h.services.APIKey.AuthenticateAPIKey(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidAPIKey) {
+			h.logger.Warn("api key rejected",
+				zap.String("client_ip", c.ClientIP()),
+				zap.String("path", c.Request.URL.Path),
+			)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Invalid, revoked or expired api key",
+				Code:    InvalidAPIKey,
+				Details: err.Error(),
+			})
+			return models.AccessTokenClaims{}, false
+		}
+		h.logger.Error("failed to authenticate api key",
+			zap.Error(err),
+		)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+			Error: "Failed to verify api key",
+			Code:  ErrCodeInternal,
+		})
+		return models.AccessTokenClaims{}, false
+	}
+
+	return claims, true
+}
+
+// requireScope lets the request through only when its credentials hold
+// scope. Sessions of signed-in users hold every scope. It must run after
+// userIdentity.
+func (h *Handler) requireScope(scope models.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := getTokenClaims(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Unauthorized",
+				Code:    InvalidToken,
+				Details: err.Error(),
+			})
+			return
+		}
+
+		if !claims.HasScope(scope) {
+			h.logger.Warn("insufficient scope",
+				zap.Int("user_id", claims.UserID),
+				zap.Int("api_key_id", claims.APIKeyID),
+				zap.String("scope", string(scope)),
+				zap.String("path", c.Request.URL.Path),
+			)
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+				Error:   "Insufficient scope",
+				Code:    InsufficientScope,
+				Details: fmt.Sprintf("this endpoint requires the %s scope", scope),
+			})
+		}
+	}
+}
+
+// requireSession rejects requests made with an API key, for endpoints that
+// manage the account itself. It must run after userIdentity.
+func (h *Handler) requireSession(c *gin.Context) {
+	claims, err := getTokenClaims(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Unauthorized",
+			Code:    InvalidToken,
+			Details: err.Error(),
+		})
 		return
 	}
 
-	c.Set(userCtx, userId)
+	if claims.APIKeyID != 0 {
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+			Error:   "API keys cannot be used here",
+			Code:    APIKeyNotAllowed,
+			Details: "sign in to use this endpoint",
+		})
+	}
+}
+
+// requireRole lets the request through only when the authenticated user has
+// one of roles. It must run after userIdentity.
+func (h *Handler) requireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := getTokenClaims(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse{
+				Error:   "Unauthorized",
+				Code:    InvalidToken,
+				Details: err.Error(),
+			})
+			return
+		}
+
+		for _, role := range roles {
+			if claims.Role == role {
+				return
+			}
+		}
+
+		h.logger.Warn("access denied",
+			zap.Int("user_id", claims.UserID),
+			zap.String("role", string(claims.Role)),
+			zap.String("path", c.Request.URL.Path),
+		)
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Access denied",
+			Code:    Forbidden,
+			Details: "this endpoint requires a different role",
+		})
+	}
 }
 
 func getUserId(c *gin.Context) (int, error) {
@@ -67,3 +235,17 @@ func getUserId(c *gin.Context) (int, error) {
 
 	return idInt, nil
 }
+
+func getTokenClaims(c *gin.Context) (models.AccessTokenClaims, error) {
+	claims, ok := c.Get(tokenClaimsCtx)
+	if !ok {
+		return models.AccessTokenClaims{}, fmt.Errorf("token claims are not found")
+	}
+
+	accessClaims, ok := claims.(models.AccessTokenClaims)
+	if !ok {
+		return models.AccessTokenClaims{}, fmt.Errorf("token claims are of invalid type")
+	}
+
+	return accessClaims, nil
+}
@@ -2,24 +2,71 @@ package handler
 
 import (
 	"OrderKeeper/internal/models"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type CreateOrderRequest struct {
-	Status models.OrderStatus `json:"status"`
+	Status models.OrderStatus       `json:"status"`
+	Items  []CreateOrderItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+type CreateOrderItemRequest struct {
+	SKU      string `json:"sku" binding:"required,max=64"`
+	Quantity int    `json:"quantity" binding:"required,min=1,max=10000"`
 }
 
 type CreateOrderResponse struct {
-	ID      int    `json:"id"`
-	Message string `json:"message"`
+	ID       int    `json:"id"`
+	Currency string `json:"currency"`
+	Subtotal int64  `json:"subtotal"`
+	Total    int64  `json:"total"`
+	Message  string `json:"message"`
+}
+type GetOrdersQuery struct {
+	Status      []string   `form:"status"`
+	CreatedFrom *time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedTo   *time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedFrom *time.Time `form:"updated_from" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedTo   *time.Time `form:"updated_to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Sort        string     `form:"sort" binding:"omitempty,oneof=created_at_desc created_at_asc"`
+	Limit       int        `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor      string     `form:"cursor"`
+}
+
+// Filter converts the query into a models.OrderFilter. Statuses may be given
+// as repeated parameters or as a comma-separated list.
+func (q GetOrdersQuery) Filter() models.OrderFilter {
+	var statuses []models.OrderStatus
+	for _, value := range q.Status {
+		for _, status := range strings.Split(value, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				statuses = append(statuses, models.OrderStatus(status))
+			}
+		}
+	}
+
+	return models.OrderFilter{
+		Statuses:    statuses,
+		CreatedFrom: q.CreatedFrom,
+		CreatedTo:   q.CreatedTo,
+		UpdatedFrom: q.UpdatedFrom,
+		UpdatedTo:   q.UpdatedTo,
+		Sort:        models.OrderSort(q.Sort),
+		Limit:       q.Limit,
+		Cursor:      q.Cursor,
+	}
 }
+
 type GetOrdersResponse struct {
-	Orders  []models.Order `json:"orders"`
-	Message string         `json:"message"`
+	Orders     []models.Order `json:"orders"`
+	NextCursor string         `json:"next_cursor"`
+	Message    string         `json:"message"`
 }
 
 type DeleteOrderResponse struct {
@@ -30,6 +77,14 @@ type UpdateOrderResponse struct {
 	Message string `json:"message"`
 }
 
+const (
+	ErrCodeNotFound           = "NOT_FOUND"
+	ErrCodeInvalidTransition  = "INVALID_STATUS_TRANSITION"
+	ErrCodeProductUnavailable = "PRODUCT_UNAVAILABLE"
+	ErrCodeOutOfStock         = "OUT_OF_STOCK"
+	ErrCodeEmailNotVerified   = "EMAIL_NOT_VERIFIED"
+)
+
 func (h *Handler) createOrder(c *gin.Context) {
 	start := time.Now()
 	clientIP := c.ClientIP()
@@ -73,9 +128,17 @@ func (h *Handler) createOrder(c *gin.Context) {
 		})
 		return
 	}
+	items := make([]models.OrderItem, 0, len(input.Items))
+	for _, item := range input.Items {
+		items = append(items, models.OrderItem{
+			SKU:      strings.TrimSpace(item.SKU),
+			Quantity: item.Quantity,
+		})
+	}
 	order := &models.Order{
 		UserID:    userId,
 		Status:    input.Status,
+		Items:     items,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -83,6 +146,7 @@ func (h *Handler) createOrder(c *gin.Context) {
 	h.logger.Info("order validation passed",
 		zap.Int("user_id", userId),
 		zap.String("status", string(input.Status)),
+		zap.Int("items", len(items)),
 		zap.String("client_ip", clientIP),
 	)
 
@@ -94,6 +158,51 @@ func (h *Handler) createOrder(c *gin.Context) {
 			zap.Error(err),
 			zap.Duration("duration", time.Since(start)),
 		)
+		switch {
+		case errors.Is(err, models.ErrInvalidOrderStatus):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid order status",
+				Code:    ErrCodeValidation,
+				Details: err.Error(),
+			})
+			return
+		case errors.Is(err, models.ErrInvalidOrderItems):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid order items",
+				Code:    ErrCodeValidation,
+				Details: err.Error(),
+			})
+			return
+		case errors.Is(err, models.ErrInsufficientStock):
+			var stockErr *models.InsufficientStockError
+			errors.As(err, &stockErr)
+			response := OutOfStockResponse{
+				ErrorResponse: ErrorResponse{
+					Error:   "Not enough stock for some items",
+					Code:    ErrCodeOutOfStock,
+					Details: err.Error(),
+				},
+			}
+			if stockErr != nil {
+				response.Shortages = stockErr.Shortages
+			}
+			c.JSON(http.StatusConflict, response)
+			return
+		case errors.Is(err, models.ErrProductUnavailable):
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Some products are unknown or no longer available",
+				Code:    ErrCodeProductUnavailable,
+				Details: err.Error(),
+			})
+			return
+		case errors.Is(err, models.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, ErrorResponse{
+				Error:   "Please verify your email address before placing orders",
+				Code:    ErrCodeEmailNotVerified,
+				Details: err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to create order",
 			Code:    ErrCodeInternal,
@@ -110,8 +219,11 @@ func (h *Handler) createOrder(c *gin.Context) {
 	)
 
 	c.JSON(http.StatusCreated, CreateOrderResponse{
-		ID:      order.ID,
-		Message: "Order created successfully",
+		ID:       order.ID,
+		Currency: order.Currency,
+		Subtotal: order.Subtotal,
+		Total:    order.Total,
+		Message:  "Order created successfully",
 	})
 }
 
@@ -142,7 +254,22 @@ func (h *Handler) getOrders(c *gin.Context) {
 		return
 	}
 
-	orders, err := h.services.Order.GetOrders(c.Request.Context(), userId)
+	var query GetOrdersQuery
+	if err = c.ShouldBindQuery(&query); err != nil {
+		h.logger.Warn("invalid orders query",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", clientIP),
+			zap.String("error", err.Error()),
+		)
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid query parameters",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	page, err := h.services.Order.GetOrders(c.Request.Context(), userId, query.Filter())
 	if err != nil {
 		h.logger.Error("failed to get orders",
 			zap.Int("user_id", userId),
@@ -150,6 +277,14 @@ func (h *Handler) getOrders(c *gin.Context) {
 			zap.Error(err),
 			zap.Duration("duration", time.Since(start)),
 		)
+		if errors.Is(err, models.ErrInvalidOrderFilter) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid query parameters",
+				Code:    ErrCodeValidation,
+				Details: err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to get orders",
 			Code:    ErrCodeInternal,
@@ -160,15 +295,16 @@ func (h *Handler) getOrders(c *gin.Context) {
 
 	h.logger.Info("orders retrieved successfully",
 		zap.Int("user_id", userId),
-		zap.Int("orders_count", len(orders)),
+		zap.Int("orders_count", len(page.Orders)),
 		zap.String("client_ip", clientIP),
 		zap.Int("status_code", http.StatusOK),
 		zap.Duration("total_duration", time.Since(start)),
 	)
 
 	c.JSON(http.StatusOK, GetOrdersResponse{
-		Orders:  orders,
-		Message: "Orders retrieved successfully",
+		Orders:     page.Orders,
+		NextCursor: page.NextCursor,
+		Message:    "Orders retrieved successfully",
 	})
 }
 func (h *Handler) getOrderById(c *gin.Context) {
@@ -222,6 +358,14 @@ func (h *Handler) getOrderById(c *gin.Context) {
 			zap.Error(err),
 			zap.Duration("duration", time.Since(start)),
 		)
+		if errors.Is(err, models.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Order not found",
+				Code:    ErrCodeNotFound,
+				Details: err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to get order",
 			Code:    ErrCodeInternal,
@@ -230,6 +374,20 @@ func (h *Handler) getOrderById(c *gin.Context) {
 		return
 	}
 
+	etag := orderETag(order.Version)
+	c.Header(etagHeader, etag)
+	if etagMatches(c.GetHeader(ifNoneMatchHeader), etag) {
+		h.logger.Info("order not modified",
+			zap.Int("user_id", userId),
+			zap.Int("order_id", order.ID),
+			zap.String("client_ip", clientIP),
+			zap.Int("status_code", http.StatusNotModified),
+			zap.Duration("total_duration", time.Since(start)),
+		)
+		c.Status(http.StatusNotModified)
+		return
+	}
+
 	h.logger.Info("order retrieved successfully",
 		zap.Int("user_id", userId),
 		zap.Int("order_id", order.ID),
@@ -288,6 +446,15 @@ func (h *Handler) updateOrder(c *gin.Context) {
 			Code:    ErrCodeValidation,
 			Details: err.Error(),
 		})
+		return
+	}
+	if input.ExpectedVersion, err = parseIfMatch(c.GetHeader(ifMatchHeader)); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid If-Match header",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
 	}
 	if err = h.services.Order.UpdateOrder(c.Request.Context(), userId, orderId, input); err != nil {
 		h.logger.Error("failed to update order",
@@ -297,11 +464,7 @@ func (h *Handler) updateOrder(c *gin.Context) {
 			zap.Error(err),
 			zap.Duration("duration", time.Since(start)),
 		)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Failed to update order",
-			Code:    ErrCodeInternal,
-			Details: err.Error(),
-		})
+		h.respondOrderUpdateError(c, err)
 		return
 	}
 	h.logger.Info("order updated successfully",
@@ -358,7 +521,17 @@ func (h *Handler) deleteOrder(c *gin.Context) {
 		return
 	}
 
-	if err = h.services.Order.DeleteOrder(c.Request.Context(), userId, orderId); err != nil {
+	expectedVersion, err := parseIfMatch(c.GetHeader(ifMatchHeader))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid If-Match header",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if err = h.services.Order.DeleteOrder(c.Request.Context(), userId, orderId, expectedVersion); err != nil {
 		h.logger.Error("failed to delete order",
 			zap.Int("user_id", userId),
 			zap.Int("order_id", orderId),
@@ -366,11 +539,26 @@ func (h *Handler) deleteOrder(c *gin.Context) {
 			zap.Error(err),
 			zap.Duration("duration", time.Since(start)),
 		)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Failed to delete order",
-			Code:    ErrCodeInternal,
-			Details: err.Error(),
-		})
+		switch {
+		case errors.Is(err, models.ErrOrderNotFound):
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Order not found",
+				Code:    ErrCodeNotFound,
+				Details: err.Error(),
+			})
+		case errors.Is(err, models.ErrOrderVersionMismatch):
+			c.JSON(http.StatusPreconditionFailed, ErrorResponse{
+				Error:   "Order was modified by someone else",
+				Code:    ErrCodePreconditionFailed,
+				Details: err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to delete order",
+				Code:    ErrCodeInternal,
+				Details: err.Error(),
+			})
+		}
 		return
 	}
 
@@ -386,3 +574,38 @@ func (h *Handler) deleteOrder(c *gin.Context) {
 		Message: "Order deleted successfully",
 	})
 }
+
+func (h *Handler) respondOrderUpdateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, models.ErrInvalidOrderStatus):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid order status",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Order not found",
+			Code:    ErrCodeNotFound,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrOrderVersionMismatch):
+		c.JSON(http.StatusPreconditionFailed, ErrorResponse{
+			Error:   "Order was modified by someone else",
+			Code:    ErrCodePreconditionFailed,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrInvalidStatusTransition):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Order status transition is not allowed",
+			Code:    ErrCodeInvalidTransition,
+			Details: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update order",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"OrderKeeper/internal/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type GetOrderHistoryResponse struct {
+	OrderID int                        `json:"order_id"`
+	History []models.OrderStatusChange `json:"history"`
+}
+
+func (h *Handler) getOrderHistory(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+
+	h.logger.Info("get order history request started",
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+		zap.String("client_ip", clientIP),
+		zap.String("user_agent", userAgent),
+	)
+
+	userId, err := getUserId(c)
+	if err != nil {
+		h.logger.Error("failed to get user id",
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal server error",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	orderId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("invalid order id",
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid order ID",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	history, err := h.services.Order.GetOrderHistory(c.Request.Context(), userId, orderId)
+	if err != nil {
+		h.logger.Error("failed to get order history",
+			zap.Int("user_id", userId),
+			zap.Int("order_id", orderId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		if errors.Is(err, models.ErrOrderNotFound) {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Order not found",
+				Code:    ErrCodeNotFound,
+				Details: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get order history",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	h.logger.Info("order history retrieved successfully",
+		zap.Int("user_id", userId),
+		zap.Int("order_id", orderId),
+		zap.Int("entries", len(history)),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusOK, GetOrderHistoryResponse{
+		OrderID: orderId,
+		History: history,
+	})
+}
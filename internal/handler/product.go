@@ -0,0 +1,365 @@
+package handler
+
+import (
+	"OrderKeeper/internal/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type CreateProductRequest struct {
+	SKU         string `json:"sku" binding:"required,max=64"`
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description"`
+	Price       int64  `json:"price" binding:"min=0"`
+	Currency    string `json:"currency" binding:"required,len=3"`
+}
+
+type UpdateProductRequest struct {
+	Name        *string `json:"name" binding:"omitempty,max=255"`
+	Description *string `json:"description"`
+	Price       *int64  `json:"price" binding:"omitempty,min=0"`
+	Currency    *string `json:"currency" binding:"omitempty,len=3"`
+}
+
+type SetStockRequest struct {
+	OnHand *int `json:"on_hand" binding:"required,min=0"`
+}
+
+type GetProductsResponse struct {
+	Products []models.Product `json:"products"`
+	Message  string           `json:"message"`
+}
+
+type ArchiveProductResponse struct {
+	Message string `json:"message"`
+}
+
+const (
+	ErrCodeProductExists = "PRODUCT_SKU_EXISTS"
+)
+
+func (h *Handler) createProduct(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	h.logger.Info("create product request started",
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+		zap.String("client_ip", clientIP),
+	)
+
+	var input CreateProductRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Warn("product validation failed",
+			zap.String("client_ip", clientIP),
+			zap.String("error", err.Error()),
+			zap.Duration("duration", time.Since(start)),
+		)
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	product := &models.Product{
+		SKU:         input.SKU,
+		Name:        input.Name,
+		Description: input.Description,
+		Price:       input.Price,
+		Currency:    input.Currency,
+	}
+	if err := h.services.Product.CreateProduct(c.Request.Context(), product); err != nil {
+		h.logger.Error("product creation failed",
+			zap.String("sku", input.SKU),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondProductError(c, err, "Failed to create product")
+		return
+	}
+
+	h.logger.Info("product created successfully",
+		zap.Int("product_id", product.ID),
+		zap.String("sku", product.SKU),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusCreated),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusCreated, product)
+}
+
+func (h *Handler) getProducts(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	includeArchived, err := strconv.ParseBool(c.DefaultQuery("include_archived", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid include_archived parameter",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	products, err := h.services.Product.GetProducts(c.Request.Context(), includeArchived)
+	if err != nil {
+		h.logger.Error("failed to get products",
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get products",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	h.logger.Info("products retrieved successfully",
+		zap.Int("products_count", len(products)),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusOK, GetProductsResponse{
+		Products: products,
+		Message:  "Products retrieved successfully",
+	})
+}
+
+func (h *Handler) getProductById(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	productId, ok := h.productIdParam(c)
+	if !ok {
+		return
+	}
+
+	product, err := h.services.Product.GetProductByID(c.Request.Context(), productId)
+	if err != nil {
+		h.logger.Error("failed to get product by id",
+			zap.Int("product_id", productId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondProductError(c, err, "Failed to get product")
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+func (h *Handler) updateProduct(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	h.logger.Info("update product request started",
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+		zap.String("client_ip", clientIP),
+	)
+
+	productId, ok := h.productIdParam(c)
+	if !ok {
+		return
+	}
+
+	var input UpdateProductRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Warn("product update validation failed",
+			zap.String("client_ip", clientIP),
+			zap.String("error", err.Error()),
+		)
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	product, err := h.services.Product.UpdateProduct(c.Request.Context(), productId, models.ProductUpdateInput{
+		Name:        input.Name,
+		Description: input.Description,
+		Price:       input.Price,
+		Currency:    input.Currency,
+	})
+	if err != nil {
+		h.logger.Error("failed to update product",
+			zap.Int("product_id", productId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondProductError(c, err, "Failed to update product")
+		return
+	}
+
+	h.logger.Info("product updated successfully",
+		zap.Int("product_id", productId),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusOK, product)
+}
+
+func (h *Handler) archiveProduct(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	productId, ok := h.productIdParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.services.Product.ArchiveProduct(c.Request.Context(), productId); err != nil {
+		h.logger.Error("failed to archive product",
+			zap.Int("product_id", productId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondProductError(c, err, "Failed to archive product")
+		return
+	}
+
+	h.logger.Info("product archived successfully",
+		zap.Int("product_id", productId),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusOK, ArchiveProductResponse{
+		Message: "Product archived successfully",
+	})
+}
+
+func (h *Handler) getProductStock(c *gin.Context) {
+	productId, ok := h.productIdParam(c)
+	if !ok {
+		return
+	}
+
+	stock, err := h.services.Product.GetStock(c.Request.Context(), productId)
+	if err != nil {
+		h.logger.Error("failed to get product stock",
+			zap.Int("product_id", productId),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		h.respondProductError(c, err, "Failed to get product stock")
+		return
+	}
+
+	c.JSON(http.StatusOK, stock)
+}
+
+func (h *Handler) setProductStock(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	productId, ok := h.productIdParam(c)
+	if !ok {
+		return
+	}
+
+	var input SetStockRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	stock, err := h.services.Product.SetStock(c.Request.Context(), productId, *input.OnHand)
+	if err != nil {
+		h.logger.Error("failed to set product stock",
+			zap.Int("product_id", productId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondProductError(c, err, "Failed to set product stock")
+		return
+	}
+
+	h.logger.Info("product stock set successfully",
+		zap.Int("product_id", productId),
+		zap.Int("on_hand", stock.OnHand),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusOK, stock)
+}
+
+func (h *Handler) productIdParam(c *gin.Context) (int, bool) {
+	productId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Warn("invalid product id",
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid product ID",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return 0, false
+	}
+	return productId, true
+}
+
+func (h *Handler) respondProductError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, models.ErrInvalidProduct):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid product",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrInvalidStock):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid stock level",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Product not found",
+			Code:    ErrCodeNotFound,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrProductSKUExists):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Product with this SKU already exists",
+			Code:    ErrCodeProductExists,
+			Details: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   message,
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+	}
+}
@@ -1,7 +1,41 @@
 package handler
 
+import (
+	"OrderKeeper/internal/models"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
 type ErrorResponse struct {
 	Error   string `json:"error"`
 	Code    string `json:"code,omitempty"`
 	Details string `json:"details,omitempty"`
 }
+
+type OutOfStockResponse struct {
+	ErrorResponse
+	Shortages []models.StockShortage `json:"shortages"`
+}
+
+// respondTooManyRequests answers a throttled request with 429 and a
+// Retry-After header.
+func respondTooManyRequests(c *gin.Context, err *models.RetryAfterError, message string) {
+	setRetryAfter(c, err.RetryAfter)
+	c.JSON(http.StatusTooManyRequests, ErrorResponse{
+		Error:   message,
+		Code:    ErrCodeTooManyRequests,
+		Details: err.Error(),
+	})
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounding up.
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+}
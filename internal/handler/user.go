@@ -0,0 +1,300 @@
+package handler
+
+import (
+	"OrderKeeper/internal/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+// UpdateProfileRequest changes the given fields. CurrentPassword is required
+// when the email changes.
+type UpdateProfileRequest struct {
+	Username        *string `json:"username" binding:"omitempty,min=1,max=30"`
+	Email           *string `json:"email" binding:"omitempty,email,max=50"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// DeleteAccountRequest is sent twice: first with the password (and the MFA
+// code when MFA is enabled), then with the confirmation token returned by
+// the first request.
+type DeleteAccountRequest struct {
+	Password          string `json:"password"`
+	MFACode           string `json:"mfa_code"`
+	ConfirmationToken string `json:"confirmation_token"`
+}
+
+type AccountDeletionResponse struct {
+	ConfirmationToken string    `json:"confirmation_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	Message           string    `json:"message"`
+}
+
+type DeleteAccountResponse struct {
+	Message string `json:"message"`
+}
+
+const (
+	ErrCodeInvalidDeletionConfirmation = "INVALID_DELETION_CONFIRMATION"
+)
+
+func (h *Handler) getProfile(c *gin.Context) {
+	userId, ok := h.userIdParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.services.User.GetProfile(c.Request.Context(), userId)
+	if err != nil {
+		h.logger.Error("failed to get profile",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Error(err),
+		)
+		h.respondUserError(c, err, "Failed to get profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *Handler) updateProfile(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	userId, ok := h.userIdParam(c)
+	if !ok {
+		return
+	}
+
+	var input UpdateProfileRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		h.logger.Warn("profile update validation failed",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", clientIP),
+			zap.String("error", err.Error()),
+		)
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.services.User.UpdateProfile(c.Request.Context(), userId, models.UserProfileUpdate{
+		Username: input.Username,
+		Email:    input.Email,
+	}, input.CurrentPassword, clientIP)
+	if err != nil {
+		h.logger.Warn("profile update failed",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondUserError(c, err, "Failed to update profile")
+		return
+	}
+
+	h.logger.Info("profile updated successfully",
+		zap.Int("user_id", userId),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *Handler) changePassword(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	userId, ok := h.userIdParam(c)
+	if !ok {
+		return
+	}
+
+	var input ChangePasswordRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	tokens, err := h.services.User.ChangePassword(c.Request.Context(), userId, input.CurrentPassword, input.NewPassword, clientIP)
+	if err != nil {
+		h.logger.Warn("password change failed",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondUserError(c, err, "Failed to change password")
+		return
+	}
+
+	h.logger.Info("password changed successfully",
+		zap.Int("user_id", userId),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:        tokens.AccessToken,
+		ExpiresAt:    tokens.AccessTokenExpiresAt,
+		RefreshToken: tokens.RefreshToken,
+		Message:      "Password changed. Other sessions have been signed out",
+	})
+}
+
+func (h *Handler) deleteAccount(c *gin.Context) {
+	start := time.Now()
+	clientIP := c.ClientIP()
+
+	userId, ok := h.userIdParam(c)
+	if !ok {
+		return
+	}
+
+	var input DeleteAccountRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if input.ConfirmationToken == "" {
+		if input.Password == "" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid input data",
+				Code:    ErrCodeValidation,
+				Details: "password or confirmation_token is required",
+			})
+			return
+		}
+
+		challenge, err := h.services.User.RequestAccountDeletion(c.Request.Context(), userId, input.Password, input.MFACode, clientIP)
+		if err != nil {
+			h.logger.Warn("account deletion request failed",
+				zap.Int("user_id", userId),
+				zap.String("client_ip", clientIP),
+				zap.Error(err),
+				zap.Duration("duration", time.Since(start)),
+			)
+			h.respondUserError(c, err, "Failed to request account deletion")
+			return
+		}
+
+		c.JSON(http.StatusAccepted, AccountDeletionResponse{
+			ConfirmationToken: challenge.Token,
+			ExpiresAt:         challenge.ExpiresAt,
+			Message:           "Send the confirmation token to delete the account. This cannot be undone",
+		})
+		return
+	}
+
+	if err := h.services.User.DeleteAccount(c.Request.Context(), userId, input.ConfirmationToken); err != nil {
+		h.logger.Warn("account deletion failed",
+			zap.Int("user_id", userId),
+			zap.String("client_ip", clientIP),
+			zap.Error(err),
+			zap.Duration("duration", time.Since(start)),
+		)
+		h.respondUserError(c, err, "Failed to delete account")
+		return
+	}
+
+	h.logger.Info("account deleted successfully",
+		zap.Int("user_id", userId),
+		zap.String("client_ip", clientIP),
+		zap.Int("status_code", http.StatusOK),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	c.JSON(http.StatusOK, DeleteAccountResponse{
+		Message: "Account deleted",
+	})
+}
+
+func (h *Handler) userIdParam(c *gin.Context) (int, bool) {
+	userId, err := getUserId(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Internal server error",
+			Code:    ErrCodeInternal,
+			Details: err.Error(),
+		})
+		return 0, false
+	}
+	return userId, true
+}
+
+func (h *Handler) respondUserError(c *gin.Context, err error, message string) {
+	var retryErr *models.RetryAfterError
+	switch {
+	case errors.As(err, &retryErr):
+		respondTooManyRequests(c, retryErr, "Too many failed password attempts")
+	case errors.Is(err, models.ErrInvalidCredentials):
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error: "Password is incorrect",
+			Code:  ErrCodeInvalidCredentials,
+		})
+	case errors.Is(err, models.ErrInvalidMFACode):
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Invalid mfa code",
+			Code:    ErrCodeInvalidMFACode,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrInvalidProfile), errors.Is(err, models.ErrPasswordUnchanged):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid input data",
+			Code:    ErrCodeValidation,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Username is already taken",
+			Code:    ErrCodeUsernameTaken,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrEmailTaken):
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   "Email is already registered",
+			Code:    ErrCodeEmailTaken,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrInvalidDeletionConfirmation):
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid or expired confirmation token, request the deletion again",
+			Code:    ErrCodeInvalidDeletionConfirmation,
+			Details: err.Error(),
+		})
+	case errors.Is(err, models.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "User not found",
+			Code:    ErrCodeNotFound,
+			Details: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error: message,
+			Code:  ErrCodeInternal,
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Component is a long-running part of the process, such as the HTTP server.
+type Component struct {
+	Name string
+	// Run blocks until the component has stopped. ctx is the root context,
+	// cancelled once shutdown reaches the stop phase. A component that
+	// returns before shutdown, with or without an error, shuts the process
+	// down.
+	Run func(ctx context.Context) error
+	// Stop, if set, asks the component to finish before ctx's deadline.
+	// Components that only watch the root context leave it nil.
+	Stop func(ctx context.Context) error
+}
+
+type closer struct {
+	name string
+	fn   func() error
+}
+
+type Options struct {
+	// DrainDelay is how long the drain hooks take effect before components
+	// are stopped, so load balancers stop routing to the process first.
+	DrainDelay time.Duration
+	// ShutdownTimeout bounds stopping the components. Resources are closed
+	// after it either way.
+	ShutdownTimeout time.Duration
+	// Signals start shutdown; SIGINT and SIGTERM when empty.
+	Signals []os.Signal
+}
+
+// Manager starts the components, waits for a signal or a component to fail,
+// and then shuts down in order: drain hooks, the drain delay, stopping the
+// components within the shutdown timeout, and closing resources.
+type Manager struct {
+	opts       Options
+	components []Component
+	drainHooks []func()
+	closers    []closer
+	logger     *zap.Logger
+}
+
+func New(opts Options, logger *zap.Logger) *Manager {
+	if len(opts.Signals) == 0 {
+		opts.Signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	return &Manager{opts: opts, logger: logger}
+}
+
+// Add registers a component to start in Run.
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+// OnDrain registers fn to be called when shutdown starts, before the drain
+// delay, such as failing readiness probes.
+func (m *Manager) OnDrain(fn func()) {
+	m.drainHooks = append(m.drainHooks, fn)
+}
+
+// AddCloser registers a resource to close once the components have stopped.
+// Resources are closed in reverse order of registration, so one opened
+// first, like the database, is closed last.
+func (m *Manager) AddCloser(name string, fn func() error) {
+	m.closers = append(m.closers, closer{name: name, fn: fn})
+}
+
+type result struct {
+	name string
+	err  error
+}
+
+// Run starts every component and blocks until the process has shut down,
+// which starts on a signal, on ctx being done, or on a component returning.
+// It returns the error of a component that failed and any errors from
+// stopping components or closing resources. A second signal during
+// shutdown is no longer caught and terminates the process.
+func (m *Manager) Run(ctx context.Context) error {
+	signalCtx, stopSignals := signal.NotifyContext(ctx, m.opts.Signals...)
+	defer stopSignals()
+
+	// Components keep running through the drain delay, so the root
+	// context is not cancelled by ctx or a signal directly.
+	root, cancelRoot := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRoot()
+
+	results := make(chan result, len(m.components))
+	for _, c := range m.components {
+		go func() {
+			results <- result{name: c.Name, err: c.Run(root)}
+		}()
+		m.logger.Info("component started", zap.String("component", c.Name))
+	}
+	running := len(m.components)
+
+	var errs []error
+	select {
+	case <-signalCtx.Done():
+		stopSignals()
+		m.logger.Info("shutdown started")
+		m.drain()
+	case res := <-results:
+		stopSignals()
+		running--
+		err := res.err
+		if err == nil {
+			err = errors.New("stopped unexpectedly")
+		}
+		err = fmt.Errorf("%s: %w", res.name, err)
+		errs = append(errs, err)
+		// The process is already failing, so there is nothing to wait
+		// for load balancers to drain.
+		m.logger.Error("component failed, shutting down", zap.String("component", res.name), zap.Error(err))
+		m.runDrainHooks()
+	}
+
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), m.opts.ShutdownTimeout)
+	defer cancelStop()
+
+	errs = append(errs, m.stop(stopCtx)...)
+	cancelRoot()
+
+	for running > 0 {
+		select {
+		case res := <-results:
+			running--
+			if res.err != nil {
+				m.logger.Error("component stopped with error", zap.String("component", res.name), zap.Error(res.err))
+				errs = append(errs, fmt.Errorf("%s: %w", res.name, res.err))
+				continue
+			}
+			m.logger.Info("component stopped", zap.String("component", res.name))
+		case <-stopCtx.Done():
+			m.logger.Error("shutdown timed out, closing resources anyway",
+				zap.Duration("timeout", m.opts.ShutdownTimeout),
+				zap.Int("still_running", running),
+			)
+			errs = append(errs, fmt.Errorf("shutdown timed out after %s with %d components still running", m.opts.ShutdownTimeout, running))
+			running = 0
+		}
+	}
+
+	errs = append(errs, m.close()...)
+	m.logger.Info("shutdown complete")
+	return errors.Join(errs...)
+}
+
+func (m *Manager) drain() {
+	m.runDrainHooks()
+	if m.opts.DrainDelay <= 0 {
+		return
+	}
+	m.logger.Info("waiting for load balancers to drain", zap.Duration("delay", m.opts.DrainDelay))
+	time.Sleep(m.opts.DrainDelay)
+}
+
+func (m *Manager) runDrainHooks() {
+	for _, fn := range m.drainHooks {
+		fn()
+	}
+}
+
+// stop calls every component's Stop concurrently, so one slow component
+// does not eat into the others' share of the shutdown timeout.
+func (m *Manager) stop(ctx context.Context) []error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	for _, c := range m.components {
+		if c.Stop == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.logger.Info("stopping component", zap.String("component", c.Name))
+			if err := c.Stop(ctx); err != nil {
+				m.logger.Error("error stopping component", zap.String("component", c.Name), zap.Error(err))
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("stop %s: %w", c.Name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// Close closes the registered resources, in the same order as Run does. It
+// is for setup that fails after resources were opened but before Run.
+func (m *Manager) Close() error {
+	return errors.Join(m.close()...)
+}
+
+func (m *Manager) close() []error {
+	var errs []error
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		c := m.closers[i]
+		if err := c.fn(); err != nil {
+			m.logger.Error("error closing resource", zap.String("resource", c.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("close %s: %w", c.name, err))
+			continue
+		}
+		m.logger.Info("resource closed", zap.String("resource", c.name))
+	}
+	return errs
+}
@@ -0,0 +1,106 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRunReturnsComponentErrorAndClosesInReverseOrder(t *testing.T) {
+	var steps []string
+	errListen := errors.New("address already in use")
+
+	m := New(Options{DrainDelay: time.Hour, ShutdownTimeout: time.Second}, zap.NewNop())
+	m.AddCloser("postgres", func() error { steps = append(steps, "close postgres"); return nil })
+	m.AddCloser("redis", func() error { steps = append(steps, "close redis"); return nil })
+	m.OnDrain(func() { steps = append(steps, "drain") })
+	m.Add(Component{
+		Name: "http",
+		Run:  func(ctx context.Context) error { return errListen },
+	})
+	m.Add(Component{
+		Name: "watcher",
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		},
+	})
+
+	// A failing component skips the hour-long drain delay.
+	err := m.Run(context.Background())
+	if !errors.Is(err, errListen) {
+		t.Fatalf("Run() error = %v, want %v", err, errListen)
+	}
+	want := []string{"drain", "close redis", "close postgres"}
+	if !slices.Equal(steps, want) {
+		t.Fatalf("steps = %v, want %v", steps, want)
+	}
+}
+
+func TestRunStopsComponentsWhenContextIsDone(t *testing.T) {
+	stopped := make(chan struct{})
+	closed := false
+
+	m := New(Options{ShutdownTimeout: time.Second}, zap.NewNop())
+	m.AddCloser("postgres", func() error { closed = true; return nil })
+	m.Add(Component{
+		Name: "http",
+		Run: func(ctx context.Context) error {
+			<-stopped
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			close(stopped)
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !closed {
+		t.Fatal("postgres was not closed")
+	}
+}
+
+func TestRunTimesOutOnStuckComponent(t *testing.T) {
+	closed := false
+
+	m := New(Options{ShutdownTimeout: 50 * time.Millisecond}, zap.NewNop())
+	m.AddCloser("postgres", func() error { closed = true; return nil })
+	m.Add(Component{
+		Name: "stuck",
+		Run:  func(ctx context.Context) error { select {} },
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Run(ctx); err == nil {
+		t.Fatal("Run() error = nil, want a shutdown timeout")
+	}
+	if !closed {
+		t.Fatal("postgres was not closed after the timeout")
+	}
+}
+
+func TestCloseWithoutRunClosesInReverseOrder(t *testing.T) {
+	var steps []string
+	errClose := errors.New("pool already closed")
+
+	m := New(Options{}, zap.NewNop())
+	m.AddCloser("postgres", func() error { steps = append(steps, "close postgres"); return errClose })
+	m.AddCloser("redis", func() error { steps = append(steps, "close redis"); return nil })
+
+	if err := m.Close(); !errors.Is(err, errClose) {
+		t.Fatalf("Close() error = %v, want %v", err, errClose)
+	}
+	want := []string{"close redis", "close postgres"}
+	if !slices.Equal(steps, want) {
+		t.Fatalf("steps = %v, want %v", steps, want)
+	}
+}
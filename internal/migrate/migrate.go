@@ -0,0 +1,472 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// advisoryLockID is the key of the session advisory lock held while
+// migrating, so replicas that start at the same time apply migrations one
+// after another instead of racing each other.
+const advisoryLockID int64 = 0x4f524445524b4550 // "ORDERKEP"
+
+const (
+	queryLock   = `SELECT pg_advisory_lock($1)`
+	queryUnlock = `SELECT pg_advisory_unlock($1)`
+
+	queryCreateVersionTable = `
+		CREATE TABLE IF NOT EXISTS schema_versions (
+			version    BIGINT PRIMARY KEY,
+			name       VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`
+	queryVersionTableExists = `SELECT to_regclass('schema_versions') IS NOT NULL`
+	querySelectVersions     = `SELECT version, applied_at FROM schema_versions ORDER BY version`
+	queryAnyVersion         = `SELECT EXISTS (SELECT 1 FROM schema_versions)`
+	queryInsertVersion      = `INSERT INTO schema_versions (version, name) VALUES ($1, $2)`
+	queryDeleteVersion      = `DELETE FROM schema_versions WHERE version = $1`
+
+	// golang-migrate keeps only the latest version in schema_migrations.
+	querySelectLegacyTable   = `SELECT to_regclass('schema_migrations') IS NOT NULL`
+	querySelectLegacyVersion = `SELECT version, dirty FROM schema_migrations LIMIT 1`
+)
+
+var (
+	ErrUnknownVersion  = errors.New("unknown migration version")
+	ErrNoDownMigration = errors.New("migration has no down file")
+)
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one version with its up and down SQL.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	hasUp   bool
+	hasDown bool
+}
+
+// Status describes one migration, or an applied version with no file.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Missing is set for versions recorded as applied that have no file.
+	Missing bool
+}
+
+// Migrator applies migrations and records each applied version in
+// schema_versions. Every migration runs in its own transaction together with
+// its version record, so a failed migration leaves nothing half-applied.
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+	logger     *zap.Logger
+}
+
+func New(db *pgxpool.Pool, fsys fs.FS, logger *zap.Logger) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		logger:     logger,
+	}, nil
+}
+
+// Load reads <version>_<name>.up.sql and <version>_<name>.down.sql files from
+// the root of fsys and returns the migrations ordered by version. Every
+// version needs an up file; the down file is optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %q and %q", version, migration.Name, match[2])
+		}
+
+		switch match[3] {
+		case "up":
+			migration.Up, migration.hasUp = string(data), true
+		case "down":
+			migration.Down, migration.hasDown = string(data), true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if !migration.hasUp {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn, versions map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, newest first, and
+// returns how many were rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	rolledBack := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn, versions map[int64]time.Time) error {
+		for _, version := range appliedDescending(versions) {
+			if rolledBack == steps {
+				break
+			}
+			if err := m.rollBack(ctx, conn, version); err != nil {
+				return err
+			}
+			rolledBack++
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Goto migrates to version: pending migrations up to and including it are
+// applied, and applied migrations after it are rolled back. Version 0 rolls
+// back everything.
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if version != 0 {
+		if _, ok := m.find(version); !ok {
+			return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+		}
+	}
+
+	return m.withLock(ctx, func(conn *pgxpool.Conn, versions map[int64]time.Time) error {
+		for _, applied := range appliedDescending(versions) {
+			if applied <= version {
+				break
+			}
+			if err := m.rollBack(ctx, conn, applied); err != nil {
+				return err
+			}
+		}
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Status lists every migration and whether it is applied, including applied
+// versions that have no migration file, ordered by version. It only reads:
+// it does not wait for the migration lock, and a database without
+// schema_versions has no applied versions, even one that golang-migrate
+// migrated and that the next migrate run will adopt.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var exists bool
+	if err = conn.QueryRow(ctx, queryVersionTableExists).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look up schema_versions: %w", err)
+	}
+	versions := map[int64]time.Time{}
+	if exists {
+		if versions, err = appliedVersions(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+
+	var statuses []Status
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for version, appliedAt := range versions {
+		if _, ok := m.find(version); !ok {
+			statuses = append(statuses, Status{Version: version, Applied: true, AppliedAt: &appliedAt, Missing: true})
+		}
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// withLock runs fn on a single connection that holds the migration advisory
+// lock, after making sure schema_versions exists. fn gets the applied
+// versions as they were once the lock was taken.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn, versions map[int64]time.Time) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	m.logger.Debug("waiting for migration lock")
+	if _, err = conn.Exec(ctx, queryLock, advisoryLockID); err != nil {
+		conn.Release()
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	err = m.prepare(ctx, conn)
+	var versions map[int64]time.Time
+	if err == nil {
+		versions, err = appliedVersions(ctx, conn)
+	}
+	if err == nil {
+		err = fn(conn, versions)
+	}
+
+	// The lock belongs to the session, so it must be released even when ctx
+	// is already cancelled. If that fails, closing the connection ends the
+	// session and releases it instead.
+	if _, unlockErr := conn.Exec(context.Background(), queryUnlock, advisoryLockID); unlockErr != nil {
+		m.logger.Warn("failed to release migration lock, closing connection",
+			zap.Error(unlockErr),
+		)
+		if closeErr := conn.Hijack().Close(context.Background()); closeErr != nil {
+			m.logger.Warn("failed to close migration connection", zap.Error(closeErr))
+		}
+		return err
+	}
+	conn.Release()
+	return err
+}
+
+// prepare creates schema_versions. A database migrated with golang-migrate
+// only records its latest version in schema_migrations; the first time
+// schema_versions is created there, every migration up to that version is
+// recorded as applied so it is not run again.
+func (m *Migrator) prepare(ctx context.Context, conn *pgxpool.Conn) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, queryCreateVersionTable); err != nil {
+		return fmt.Errorf("failed to create schema_versions: %w", err)
+	}
+
+	var recorded bool
+	if err = tx.QueryRow(ctx, queryAnyVersion).Scan(&recorded); err != nil {
+		return fmt.Errorf("failed to read schema_versions: %w", err)
+	}
+	var legacy bool
+	if err = tx.QueryRow(ctx, querySelectLegacyTable).Scan(&legacy); err != nil {
+		return fmt.Errorf("failed to look up schema_migrations: %w", err)
+	}
+	if recorded || !legacy {
+		return tx.Commit(ctx)
+	}
+
+	var legacyVersion int64
+	var dirty bool
+	err = tx.QueryRow(ctx, querySelectLegacyVersion).Scan(&legacyVersion, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return tx.Commit(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("schema_migrations marks version %d as dirty; repair it before migrating", legacyVersion)
+	}
+
+	adopted := 0
+	for _, migration := range m.migrations {
+		if migration.Version > legacyVersion {
+			break
+		}
+		if _, err = tx.Exec(ctx, queryInsertVersion, migration.Version, migration.Name); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		}
+		adopted++
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	m.logger.Info("adopted versions applied by golang-migrate",
+		zap.Int64("version", legacyVersion),
+		zap.Int("migrations", adopted),
+	)
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	start := time.Now()
+	err := m.run(ctx, conn, migration.Up, queryInsertVersion, migration.Version, migration.Name)
+	if err != nil {
+		m.logger.Error("migration failed",
+			zap.Int64("version", migration.Version),
+			zap.String("name", migration.Name),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	m.logger.Info("migration applied",
+		zap.Int64("version", migration.Version),
+		zap.String("name", migration.Name),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return nil
+}
+
+func (m *Migrator) rollBack(ctx context.Context, conn *pgxpool.Conn, version int64) error {
+	migration, ok := m.find(version)
+	if !ok {
+		return fmt.Errorf("%w: %d is applied but has no migration file", ErrUnknownVersion, version)
+	}
+	if !migration.hasDown {
+		return fmt.Errorf("%w: %d_%s", ErrNoDownMigration, migration.Version, migration.Name)
+	}
+
+	start := time.Now()
+	if err := m.run(ctx, conn, migration.Down, queryDeleteVersion, migration.Version); err != nil {
+		m.logger.Error("migration rollback failed",
+			zap.Int64("version", migration.Version),
+			zap.String("name", migration.Name),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to roll back migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	m.logger.Info("migration rolled back",
+		zap.Int64("version", migration.Version),
+		zap.String("name", migration.Name),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return nil
+}
+
+// run executes a migration script and the version bookkeeping query in one
+// transaction. Scripts are sent without arguments, which lets them hold
+// several statements.
+func (m *Migrator) run(ctx context.Context, conn *pgxpool.Conn, script, record string, args ...any) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if strings.TrimSpace(script) != "" {
+		if _, err = tx.Exec(ctx, script); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.Exec(ctx, record, args...); err != nil {
+		return fmt.Errorf("failed to update schema_versions: %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	i := sort.Search(len(m.migrations), func(i int) bool {
+		return m.migrations[i].Version >= version
+	})
+	if i < len(m.migrations) && m.migrations[i].Version == version {
+		return m.migrations[i], true
+	}
+	return Migration{}, false
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.Query(ctx, querySelectVersions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_versions: %w", err)
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+func appliedDescending(versions map[int64]time.Time) []int64 {
+	sorted := make([]int64, 0, len(versions))
+	for version := range versions {
+		sorted = append(sorted, version)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] > sorted[j]
+	})
+	return sorted
+}
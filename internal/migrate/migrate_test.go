@@ -0,0 +1,51 @@
+package migrate
+
+import (
+	"OrderKeeper/migrations"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	loaded, err := Load(fstest.MapFS{
+		"20250702101500_create_b.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"20250618153334_create_a.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+		"20250618153334_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"migrations.go":                    {Data: []byte("package migrations")},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Version != 20250618153334 || loaded[1].Version != 20250702101500 {
+		t.Fatalf("unexpected migrations %+v", loaded)
+	}
+	if loaded[0].Name != "create_a" || loaded[0].Down != "DROP TABLE a;" || !loaded[0].hasDown {
+		t.Errorf("first migration loaded as %+v", loaded[0])
+	}
+	if loaded[1].hasDown {
+		t.Errorf("second migration has no down file but was loaded with one")
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"bad name":       {"create_a.up.sql": {}},
+		"down only":      {"1_create_a.down.sql": {}},
+		"version reused": {"1_create_a.up.sql": {}, "1_create_b.up.sql": {}},
+		"unknown suffix": {"1_create_a.sideways.sql": {}},
+	} {
+		if _, err = Load(fsys); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("embedded migrations: %v", err)
+	}
+	for _, migration := range loaded {
+		if !migration.hasDown {
+			t.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+}
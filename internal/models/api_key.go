@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// Scope is a permission an API key can be granted. Sessions of signed-in
+// users hold every scope.
+type Scope string
+
+const (
+	ScopeOrdersRead    Scope = "orders:read"
+	ScopeOrdersWrite   Scope = "orders:write"
+	ScopeProductsWrite Scope = "products:write"
+)
+
+const (
+	// APIKeyPrefixLength is the number of leading characters of a key that
+	// are stored in clear text to identify it in listings.
+	APIKeyPrefixLength = 12
+	// APIKeyTouchInterval is how often at most the last use of a key is
+	// written to the database.
+	APIKeyTouchInterval = time.Minute
+)
+
+// AllScopes returns every scope there is.
+func AllScopes() []Scope {
+	return []Scope{ScopeOrdersRead, ScopeOrdersWrite, ScopeProductsWrite}
+}
+
+func (s Scope) IsValid() bool {
+	switch s {
+	case ScopeOrdersRead, ScopeOrdersWrite, ScopeProductsWrite:
+		return true
+	}
+	return false
+}
+
+// APIKey is a stored personal API key. OwnerRole is the current role of the
+// user the key belongs to, which is what the key acts as.
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []Scope    `json:"scopes"`
+	OwnerRole  Role       `json:"owner_role"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IsActive reports whether the key can still be used at the given time.
+func (k APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// APIKeyInput describes a key to create. A nil ExpiresAt never expires.
+type APIKeyInput struct {
+	Name      string
+	Scopes    []Scope
+	ExpiresAt *time.Time
+}
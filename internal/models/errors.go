@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrOrderNotFound           = errors.New("order not found")
+	ErrInvalidOrderStatus      = errors.New("invalid order status")
+	ErrInvalidStatusTransition = errors.New("invalid order status transition")
+	ErrInvalidOrderItems       = errors.New("invalid order items")
+	ErrInvalidOrderFilter      = errors.New("invalid order filter")
+	ErrOrderVersionMismatch    = errors.New("order version mismatch")
+
+	ErrInvalidProduct     = errors.New("invalid product")
+	ErrProductNotFound    = errors.New("product not found")
+	ErrProductSKUExists   = errors.New("product sku already exists")
+	ErrProductUnavailable = errors.New("product unavailable")
+	ErrInvalidStock       = errors.New("invalid stock level")
+	ErrInsufficientStock  = errors.New("insufficient stock")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrTokenRevoked        = errors.New("token revoked")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUsernameTaken       = errors.New("username already taken")
+	ErrEmailTaken          = errors.New("email already taken")
+	ErrInvalidCredentials  = errors.New("invalid username or password")
+	ErrInvalidResetToken   = errors.New("invalid or expired password reset token")
+	ErrInvalidProfile      = errors.New("invalid profile")
+	ErrPasswordUnchanged   = errors.New("new password matches the current one")
+
+	ErrInvalidDeletionConfirmation = errors.New("invalid or expired account deletion confirmation")
+
+	ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+	ErrEmailAlreadyVerified     = errors.New("email already verified")
+	ErrEmailNotVerified         = errors.New("email not verified")
+	ErrTooManyVerificationMails = errors.New("too many verification emails requested")
+
+	ErrTooManyLoginAttempts = errors.New("too many failed sign-in attempts")
+
+	ErrInvalidAPIKey      = errors.New("invalid api key")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	ErrInvalidAPIKeyInput = errors.New("invalid api key input")
+
+	ErrInvalidMFACode      = errors.New("invalid mfa code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired mfa challenge")
+	ErrMFAAlreadyEnabled   = errors.New("mfa already enabled")
+	ErrMFANotEnabled       = errors.New("mfa not enabled")
+	ErrMFANotEnrolled      = errors.New("mfa enrolment not started")
+
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key reused with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("request with this idempotency key is still in progress")
+)
+
+// InsufficientStockError lists every SKU of an order that could not be
+// reserved. It matches ErrInsufficientStock with errors.Is.
+type InsufficientStockError struct {
+	Shortages []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	skus := make([]string, len(e.Shortages))
+	for i, shortage := range e.Shortages {
+		skus[i] = fmt.Sprintf("%s (requested %d, available %d)", shortage.SKU, shortage.Requested, shortage.Available)
+	}
+	return fmt.Sprintf("%s: %s", ErrInsufficientStock, strings.Join(skus, ", "))
+}
+
+func (e *InsufficientStockError) Unwrap() error {
+	return ErrInsufficientStock
+}
+
+// RetryAfterError marks a throttled request and says when it may be retried.
+// It matches Err with errors.Is.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", e.Err, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const (
+	// IdempotencyKeyTTL is how long a key and its stored response are kept.
+	IdempotencyKeyTTL = 24 * time.Hour
+	// IdempotencyLease is how long a request holds its key before a retry
+	// may take it over, e.g. after the process crashed mid-request. It is
+	// well above the server's write timeout.
+	IdempotencyLease = time.Minute
+)
+
+// IdempotencyRecord remembers the outcome of a mutating request sent with an
+// Idempotency-Key header. Completed is false while the first request with
+// the key is still being processed.
+type IdempotencyRecord struct {
+	UserID       int       `json:"user_id"`
+	Key          string    `json:"key"`
+	Fingerprint  string    `json:"fingerprint"`
+	Completed    bool      `json:"completed"`
+	StatusCode   int       `json:"status_code"`
+	ResponseBody []byte    `json:"response_body"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// LoginAttempts counts the failed sign-ins of one throttling key, a username
+// or a client IP, since the counter was last reset.
+type LoginAttempts struct {
+	Failures      int
+	LastFailureAt time.Time
+}
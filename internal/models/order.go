@@ -13,14 +13,131 @@ const (
 	StatusCancelled OrderStatus = "cancelled"
 )
 
+// orderStatusTransitions is the single source of truth for the order
+// lifecycle: pending -> confirmed -> paid -> shipped -> delivered, with
+// cancellation allowed only while the order has not been shipped yet.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	StatusPending:   {StatusConfirmed, StatusCancelled},
+	StatusConfirmed: {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered},
+	StatusDelivered: {},
+	StatusCancelled: {},
+}
+
+// IsValid reports whether s is one of the known order statuses.
+func (s OrderStatus) IsValid() bool {
+	_, ok := orderStatusTransitions[s]
+	return ok
+}
+
+// NextStatuses returns the statuses an order in status s may move to.
+func (s OrderStatus) NextStatuses() []OrderStatus {
+	next := orderStatusTransitions[s]
+	statuses := make([]OrderStatus, len(next))
+	copy(statuses, next)
+	return statuses
+}
+
+// CanTransitionTo reports whether an order may move from s to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// PreviousStatuses returns the statuses from which an order may move to s.
+func (s OrderStatus) PreviousStatuses() []OrderStatus {
+	var statuses []OrderStatus
+	for from, next := range orderStatusTransitions {
+		for _, to := range next {
+			if to == s {
+				statuses = append(statuses, from)
+			}
+		}
+	}
+	return statuses
+}
+
+// Order amounts are integers in the currency's minor units (e.g. cents).
 type Order struct {
-	ID        int         `json:"id"`
-	UserID    int         `json:"user_id"`
-	Status    OrderStatus `json:"status"`
-	CreatedAt time.Time   `json:"created_at"`
-	UpdatedAt time.Time   `json:"updated_at"`
+	ID           int           `json:"id"`
+	UserID       int           `json:"user_id"`
+	Status       OrderStatus   `json:"status"`
+	NextStatuses []OrderStatus `json:"next_statuses"`
+	Items        []OrderItem   `json:"items"`
+	Currency     string        `json:"currency"`
+	Subtotal     int64         `json:"subtotal"`
+	Total        int64         `json:"total"`
+	Version      int           `json:"version"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
 }
 
+type OrderItem struct {
+	ID        int    `json:"id"`
+	OrderID   int    `json:"order_id"`
+	SKU       string `json:"sku"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int64  `json:"unit_price"`
+	Currency  string `json:"currency"`
+	LineTotal int64  `json:"line_total"`
+}
+
+type OrderSort string
+
+const (
+	SortCreatedAtDesc OrderSort = "created_at_desc"
+	SortCreatedAtAsc  OrderSort = "created_at_asc"
+)
+
+const (
+	DefaultOrdersPageSize = 20
+	MaxOrdersPageSize     = 100
+)
+
+// OrderFilter selects a page of orders. Time ranges are half-open: From is
+// inclusive and To is exclusive. Cursor is the opaque NextCursor of the
+// previous page and must be used with the same filter and sort. UserID and
+// SKU narrow the admin search to one customer and to orders containing a
+// product.
+type OrderFilter struct {
+	UserID      *int
+	SKU         string
+	Statuses    []OrderStatus
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	Sort        OrderSort
+	Limit       int
+	Cursor      string
+}
+
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor"`
+}
+
+// OrderUpdateInput changes an order. When ExpectedVersion is set, the update
+// only applies if the order still has that version.
 type OrderUpdateInput struct {
-	Status *OrderStatus `json:"status"`
+	Status          *OrderStatus `json:"status"`
+	Reason          *string      `json:"reason"`
+	ExpectedVersion *int         `json:"-"`
+}
+
+// OrderStatusChange is one entry of an order's status timeline. FromStatus is
+// nil for the entry recorded when the order is created.
+type OrderStatusChange struct {
+	ID         int          `json:"id"`
+	OrderID    int          `json:"order_id"`
+	FromStatus *OrderStatus `json:"from_status"`
+	ToStatus   OrderStatus  `json:"to_status"`
+	ActorID    *int         `json:"actor_id"`
+	Reason     *string      `json:"reason,omitempty"`
+	ChangedAt  time.Time    `json:"changed_at"`
 }
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// Product is an entry of the catalog. Price is in the currency's minor units.
+// Archived products stay readable but can no longer be ordered.
+type Product struct {
+	ID          int        `json:"id"`
+	SKU         string     `json:"sku"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Price       int64      `json:"price"`
+	Currency    string     `json:"currency"`
+	Active      bool       `json:"active"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type ProductUpdateInput struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Price       *int64  `json:"price"`
+	Currency    *string `json:"currency"`
+}
+
+// ProductStock is the inventory level of a product. Reserved units belong to
+// orders that have been placed but not shipped yet.
+type ProductStock struct {
+	ProductID int    `json:"product_id"`
+	SKU       string `json:"sku"`
+	OnHand    int    `json:"on_hand"`
+	Reserved  int    `json:"reserved"`
+	Available int    `json:"available"`
+}
+
+type StockShortage struct {
+	SKU       string `json:"sku"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
@@ -0,0 +1,135 @@
+package models
+
+import "time"
+
+const (
+	// AccessTokenTTL is the lifetime of a JWT access token.
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is the lifetime of a refresh token. Every refresh
+	// replaces the token, so an active session never hits this limit.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// PasswordResetTokenTTL is how long a password reset link stays valid.
+	PasswordResetTokenTTL = time.Hour
+	// EmailVerificationTokenTTL is how long an email verification link
+	// stays valid.
+	EmailVerificationTokenTTL = 24 * time.Hour
+	// EmailVerificationResendInterval is the minimum time between two
+	// verification emails to the same user.
+	EmailVerificationResendInterval = time.Minute
+	// MaxEmailVerificationsPerHour caps the verification emails sent to one
+	// user per hour.
+	MaxEmailVerificationsPerHour = 5
+	// MFAChallengeTTL is how long a user has to enter a code after the
+	// password step of an MFA sign-in.
+	MFAChallengeTTL = 5 * time.Minute
+	// MFARecoveryCodeCount is the number of recovery codes issued when MFA
+	// is enabled.
+	MFARecoveryCodeCount = 10
+	// AccountDeletionTTL is how long a user has to confirm the deletion of
+	// their account.
+	AccountDeletionTTL = 5 * time.Minute
+)
+
+// TokenPair is issued on sign-in and on every refresh.
+type TokenPair struct {
+	AccessToken          string
+	AccessTokenExpiresAt time.Time
+	RefreshToken         string
+}
+
+// SignInResult is the outcome of a password sign-in. Users with MFA enabled
+// get an MFAChallenge to complete instead of Tokens.
+type SignInResult struct {
+	Tokens       TokenPair
+	MFAChallenge *MFAChallenge
+}
+
+// MFAChallenge is a short-lived token proving that the password step of a
+// sign-in succeeded. It is exchanged, together with a code, for a TokenPair.
+type MFAChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// AccountDeletionChallenge is issued once the user has proven who they are,
+// and has to be sent back to actually delete the account.
+type AccountDeletionChallenge struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+// AccessTokenClaims are the claims of a verified access token. ID is the
+// token's jti and is what gets denylisted on logout. Requests authenticated
+// with an API key get claims too, with APIKeyID set and only the key's
+// scopes.
+type AccessTokenClaims struct {
+	ID        string
+	UserID    int
+	Role      Role
+	Scopes    []Scope
+	APIKeyID  int
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func (c AccessTokenClaims) HasScope(scope Scope) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshToken is a stored refresh token. Only the SHA-256 hash of the token
+// is kept. Tokens issued by rotating one another share a FamilyID, so a
+// stolen token can be revoked together with everything derived from it.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// PasswordResetToken is a stored, single-use password reset token. Only the
+// SHA-256 hash of the token is kept.
+type PasswordResetToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// EmailVerificationToken is a stored, single-use email verification token.
+// Only the SHA-256 hash of the token is kept.
+type EmailVerificationToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// JSONWebKey is a public verification key in RFC 7517 format. RSA keys set
+// Modulus and Exponent; Ed25519 keys set Curve and X.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Modulus   string `json:"n,omitempty"`
+	Exponent  string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
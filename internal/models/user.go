@@ -1,8 +1,52 @@
 package models
 
+// Role decides which parts of the API a user may access.
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleSupport  Role = "support"
+	RoleAdmin    Role = "admin"
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleCustomer, RoleSupport, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 type User struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
 	Email    string `json:"email"`
-	Password string `json:"password"`
+	// Password carries the password hash into CreateUser only. Users read
+	// back from storage never have it, and it is never serialized.
+	Password      string `json:"-"`
+	Role          Role   `json:"role"`
+	EmailVerified bool   `json:"email_verified"`
+	MFAEnabled    bool   `json:"mfa_enabled"`
+}
+
+// UserProfileUpdate holds the profile fields a user changes about
+// themselves. Nil fields stay as they are.
+type UserProfileUpdate struct {
+	Username *string
+	Email    *string
+}
+
+// UserMFA is a user's TOTP state. Secret is set from enrolment onwards and
+// LastStep is the last TOTP time step that was accepted.
+type UserMFA struct {
+	Secret   string
+	Enabled  bool
+	LastStep *int64
+}
+
+// MFAEnrolment is handed to the user to add the account to an
+// authenticator app.
+type MFAEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
 }
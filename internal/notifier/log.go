@@ -0,0 +1,55 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogNotifier is meant for local development. It logs only the recipient
+// and subject: bodies carry live reset and verification tokens, which must
+// not end up in shared logs. When a file is configured, whole messages are
+// appended to it, where links and tokens can be copied from.
+type LogNotifier struct {
+	file   string
+	mu     sync.Mutex
+	logger *zap.Logger
+}
+
+func NewLogNotifier(file string, logger *zap.Logger) *LogNotifier {
+	return &LogNotifier{
+		file:   file,
+		logger: logger,
+	}
+}
+
+func (l *LogNotifier) Send(ctx context.Context, msg Message) error {
+	l.logger.Info("notification",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.Bool("written_to_file", l.file != ""),
+	)
+
+	if l.file == "" {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	if err != nil {
+		return fmt.Errorf("failed to write notification file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+const (
+	DriverLog  = "log"
+	DriverSMTP = "smtp"
+)
+
+// Message is a plain-text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type Config struct {
+	Driver string `mapstructure:"driver"`
+	// File is where the log notifier appends whole messages, tokens
+	// included; empty disables it.
+	File string     `mapstructure:"file"`
+	SMTP SMTPConfig `mapstructure:"smtp"`
+}
+
+// New returns the notifier selected by cfg.Driver.
+func New(cfg Config, logger *zap.Logger) (Notifier, error) {
+	switch cfg.Driver {
+	case "", DriverLog:
+		return NewLogNotifier(cfg.File, logger), nil
+	case DriverSMTP:
+		return NewSMTPNotifier(cfg.SMTP, logger)
+	}
+	return nil, fmt.Errorf("unknown notifier driver %q", cfg.Driver)
+}
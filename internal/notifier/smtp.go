@@ -0,0 +1,102 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"-"`
+	From     string `mapstructure:"from"`
+}
+
+// SMTPNotifier sends messages through an SMTP relay. net/smtp upgrades to
+// TLS with STARTTLS when the server offers it, and only sends credentials
+// over TLS or to localhost.
+type SMTPNotifier struct {
+	cfg  SMTPConfig
+	auth smtp.Auth
+	// envelopeFrom is the bare address of cfg.From, used for MAIL FROM.
+	envelopeFrom string
+	logger       *zap.Logger
+}
+
+func NewSMTPNotifier(cfg SMTPConfig, logger *zap.Logger) (*SMTPNotifier, error) {
+	if cfg.Host == "" || cfg.Port == "" || cfg.From == "" {
+		return nil, fmt.Errorf("smtp notifier requires host, port and from")
+	}
+
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp from address: %w", err)
+	}
+
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+
+	return &SMTPNotifier{
+		cfg:          cfg,
+		auth:         auth,
+		envelopeFrom: from.Address,
+		logger:       logger,
+	}, nil
+}
+
+func (s *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	start := time.Now()
+
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return fmt.Errorf("invalid recipient address")
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	// net/smtp has no context support; run it in the background so the
+	// caller is not held past its deadline.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(s.cfg.Host, s.cfg.Port), s.auth, s.envelopeFrom,
+			[]string{msg.To}, []byte(body.String()))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			s.logger.Error("failed to send email",
+				zap.String("to", msg.To),
+				zap.String("subject", msg.Subject),
+				zap.Error(err),
+				zap.Duration("duration", time.Since(start)),
+			)
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+	case <-ctx.Done():
+		return fmt.Errorf("failed to send email: %w", ctx.Err())
+	}
+
+	s.logger.Info("email sent",
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return nil
+}
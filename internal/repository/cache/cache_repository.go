@@ -24,6 +24,24 @@ func (r *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl
 	r.logger.Info("value set in cache", zap.String("key", key), zap.Duration("ttl", ttl))
 	return nil
 }
+
+// SetIfAbsent stores value only when key does not exist yet, and reports
+// whether it did. It fills the cache from the database without overwriting
+// a newer value written in the meantime.
+func (r *RedisCache) SetIfAbsent(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		r.logger.Error("failed to marshal value", zap.Error(err))
+		return false, fmt.Errorf("failed to marshal: %w", err)
+	}
+	stored, err := r.Client.SetNX(ctx, key, data, ttl).Result()
+	if err != nil {
+		r.logger.Error("failed to set value in cache", zap.String("key", key), zap.Error(err))
+		return false, fmt.Errorf("failed to set value in cache: %w", err)
+	}
+	r.logger.Info("value set in cache if absent", zap.String("key", key), zap.Bool("stored", stored), zap.Duration("ttl", ttl))
+	return stored, nil
+}
 func (r *RedisCache) Get(ctx context.Context, key string, dest interface{}) error {
 	val, err := r.Client.Get(ctx, key).Result()
 	if err != nil {
@@ -64,6 +82,39 @@ func (r *RedisCache) DeletePattern(ctx context.Context, pattern string) error {
 	return nil
 }
 
+// Increment atomically adds one to the counter stored at key and resets the
+// key's TTL. A missing key counts from zero.
+func (r *RedisCache) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := r.Client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		r.logger.Error("failed to increment counter in cache", zap.String("key", key), zap.Error(err))
+		return 0, fmt.Errorf("failed to increment counter in cache: %w", err)
+	}
+	return incr.Val(), nil
+}
+
+// decrementIfPositive leaves missing keys alone, where DECR would create
+// them at -1.
+var decrementIfPositive = redis.NewScript(`
+	local value = tonumber(redis.call("GET", KEYS[1]) or "0")
+	if value > 0 then
+		return redis.call("DECR", KEYS[1])
+	end
+	return value
+`)
+
+// DecrementIfPositive atomically takes one from the counter stored at key,
+// keeping its TTL. Missing keys and counters at zero are left as they are.
+func (r *RedisCache) DecrementIfPositive(ctx context.Context, key string) error {
+	if err := decrementIfPositive.Run(ctx, r.Client, []string{key}).Err(); err != nil {
+		r.logger.Error("failed to decrement counter in cache", zap.String("key", key), zap.Error(err))
+		return fmt.Errorf("failed to decrement counter in cache: %w", err)
+	}
+	return nil
+}
+
 func (r *RedisCache) Ping(ctx context.Context) error {
 	return r.Client.Ping(ctx).Err()
 }
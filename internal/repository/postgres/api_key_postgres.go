@@ -0,0 +1,185 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"time"
+)
+
+type APIKeyRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewAPIKeyRepository(db *pgxpool.Pool, logger *zap.Logger) *APIKeyRepository {
+	return &APIKeyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (a *APIKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var expiresAt *time.Time
+	if key.ExpiresAt != nil {
+		utc := key.ExpiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	err := a.db.QueryRow(ctx, queryInsertAPIKey, key.UserID, key.Name, key.Prefix, key.KeyHash,
+		scopeStrings(key.Scopes), expiresAt).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		a.logger.Error("failed to insert api key",
+			zap.Int("user_id", key.UserID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	a.logger.Info("api key created",
+		zap.Int("user_id", key.UserID),
+		zap.Int("api_key_id", key.ID),
+	)
+	return nil
+}
+
+// GetAPIKeys returns all keys of the user, newest first, including revoked
+// and expired ones.
+func (a *APIKeyRepository) GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	rows, err := a.db.Query(ctx, querySelectAPIKeys, userID)
+	if err != nil {
+		a.logger.Error("failed to select api keys",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (a *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	key, err := scanAPIKey(a.db.QueryRow(ctx, querySelectAPIKeyByHash, keyHash))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.APIKey{}, models.ErrInvalidAPIKey
+		}
+		a.logger.Error("failed to select api key",
+			zap.Error(err),
+		)
+		return models.APIKey{}, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+// getKeyState returns the current role of the key's owner and the key's
+// revocation time. A deleted key or owner fails with models.ErrInvalidAPIKey.
+func (a *APIKeyRepository) getKeyState(ctx context.Context, keyID int) (models.Role, *time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var (
+		role      models.Role
+		revokedAt *time.Time
+	)
+	if err := a.db.QueryRow(ctx, querySelectAPIKeyState, keyID).Scan(&role, &revokedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil, models.ErrInvalidAPIKey
+		}
+		a.logger.Error("failed to select api key state",
+			zap.Int("api_key_id", keyID),
+			zap.Error(err),
+		)
+		return "", nil, fmt.Errorf("failed to get api key state: %w", err)
+	}
+	return role, revokedAt, nil
+}
+
+// RevokeAPIKey revokes one of the user's keys and returns it. Revoking a key
+// twice keeps the first revocation time.
+func (a *APIKeyRepository) RevokeAPIKey(ctx context.Context, userID, keyID int) (models.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	key, err := scanAPIKey(a.db.QueryRow(ctx, queryRevokeAPIKey, userID, keyID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.APIKey{}, fmt.Errorf("%w: %d", models.ErrAPIKeyNotFound, keyID)
+		}
+		a.logger.Error("failed to revoke api key",
+			zap.Int("user_id", userID),
+			zap.Int("api_key_id", keyID),
+			zap.Error(err),
+		)
+		return models.APIKey{}, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	a.logger.Info("api key revoked",
+		zap.Int("user_id", userID),
+		zap.Int("api_key_id", keyID),
+	)
+	return key, nil
+}
+
+// TouchAPIKey records that the key was just used. It refreshes LastUsedAt
+// and RevokedAt of key, so a key revoked in the meantime is not passed on as
+// active.
+func (a *APIKeyRepository) TouchAPIKey(ctx context.Context, key *models.APIKey) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	if err := a.db.QueryRow(ctx, queryTouchAPIKey, key.ID).Scan(&key.LastUsedAt, &key.RevokedAt); err != nil {
+		return fmt.Errorf("failed to record api key use: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (models.APIKey, error) {
+	var (
+		key    models.APIKey
+		scopes []string
+	)
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &key.OwnerRole,
+		&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	key.Scopes = make([]models.Scope, len(scopes))
+	for i, scope := range scopes {
+		key.Scopes[i] = models.Scope(scope)
+	}
+	return key, nil
+}
+
+func scopeStrings(scopes []models.Scope) []string {
+	values := make([]string, len(scopes))
+	for i, scope := range scopes {
+		values[i] = string(scope)
+	}
+	return values
+}
@@ -43,6 +43,20 @@ func (a *AuthorizationRepository) CreateUser(ctx context.Context, user models.Us
 	err := a.db.QueryRow(ctx, queryInsertUser, user.Username, user.Email, user.Password).Scan(&id)
 	duration := time.Since(start)
 	if err != nil {
+		if constraint, ok := uniqueViolationConstraint(err); ok {
+			a.logger.Warn("user identity already taken",
+				zap.String("email", user.Email),
+				zap.String("username", user.Username),
+				zap.String("constraint", constraint),
+				zap.Duration("db_duration", duration),
+			)
+			switch constraint {
+			case usersUsernameKey:
+				return 0, fmt.Errorf("%w: %s", models.ErrUsernameTaken, user.Username)
+			case usersEmailKey:
+				return 0, fmt.Errorf("%w: %s", models.ErrEmailTaken, user.Email)
+			}
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			a.logger.Error("database query timeout",
 				zap.String("email", user.Email),
@@ -83,7 +97,9 @@ func (a *AuthorizationRepository) CreateUser(ctx context.Context, user models.Us
 	return id, nil
 }
 
-func (a *AuthorizationRepository) GetUser(ctx context.Context, username, password string) (models.User, error) {
+// GetUserByUsername returns the user's profile. It never includes the
+// password hash; see GetPasswordHash.
+func (a *AuthorizationRepository) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
 	start := time.Now()
 	a.logger.Debug("database get operation started",
 		zap.String("username", username),
@@ -93,11 +109,10 @@ func (a *AuthorizationRepository) GetUser(ctx context.Context, username, passwor
 	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
 	defer cancel()
 
-	row := a.db.QueryRow(ctx, querySelectUser, username)
+	row := a.db.QueryRow(ctx, querySelectUserByUsername, username)
 	duration := time.Since(start)
 
-	var user models.User
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Password)
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			a.logger.Error("database query timeout",
@@ -116,7 +131,7 @@ func (a *AuthorizationRepository) GetUser(ctx context.Context, username, passwor
 				zap.Duration("duration", duration),
 				zap.Error(err),
 			)
-			return models.User{}, fmt.Errorf("user not found: %w", err)
+			return models.User{}, fmt.Errorf("%w: %w", models.ErrUserNotFound, err)
 		}
 		a.logger.Error("database select failed",
 			zap.String("email", user.Email),
@@ -148,3 +163,495 @@ func (a *AuthorizationRepository) GetUser(ctx context.Context, username, passwor
 
 	return user, nil
 }
+
+// GetPasswordHash returns the bcrypt hash of the user's password. It is read
+// separately from the profile so that the hash never travels further than
+// the password check.
+func (a *AuthorizationRepository) GetPasswordHash(ctx context.Context, userID int) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var hash string
+	if err := a.db.QueryRow(ctx, querySelectUserPasswordHash, userID).Scan(&hash); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("%w: %w", models.ErrUserNotFound, err)
+		}
+		a.logger.Error("database select failed",
+			zap.Int("user_id", userID),
+			zap.String("operation", "select_password_hash"),
+			zap.Error(err),
+		)
+		return "", fmt.Errorf("could not get password hash: %w", err)
+	}
+	return hash, nil
+}
+
+// UpdatePasswordHash replaces oldHash with newHash and reports whether it did.
+// It does nothing when the password was changed since oldHash was read.
+func (a *AuthorizationRepository) UpdatePasswordHash(ctx context.Context, userID int, oldHash, newHash string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	tag, err := a.db.Exec(ctx, queryReplacePasswordHash, userID, oldHash, newHash)
+	if err != nil {
+		a.logger.Error("failed to update password hash",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return false, fmt.Errorf("failed to update password hash: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (a *AuthorizationRepository) GetUserByID(ctx context.Context, userID int) (models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	user, err := scanUser(a.db.QueryRow(ctx, querySelectUserByID, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%w: %w", models.ErrUserNotFound, err)
+		}
+		a.logger.Error("database select failed",
+			zap.Int("user_id", userID),
+			zap.String("operation", "select_user_by_id"),
+			zap.Error(err),
+		)
+		return models.User{}, fmt.Errorf("could not get user: %w", err)
+	}
+	return user, nil
+}
+
+func (a *AuthorizationRepository) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	user, err := scanUser(a.db.QueryRow(ctx, querySelectUserByEmail, email))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%w: %w", models.ErrUserNotFound, err)
+		}
+		a.logger.Error("database select failed",
+			zap.String("operation", "select_user_by_email"),
+			zap.Error(err),
+		)
+		return models.User{}, fmt.Errorf("could not get user: %w", err)
+	}
+	return user, nil
+}
+
+// UpdateUserProfile changes the given profile fields. A taken username or
+// email fails with models.ErrUsernameTaken or models.ErrEmailTaken.
+func (a *AuthorizationRepository) UpdateUserProfile(ctx context.Context, userID int, input models.UserProfileUpdate) (models.User, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	user, err := scanUser(a.db.QueryRow(ctx, queryUpdateUserProfile, userID, input.Username, input.Email))
+	if err != nil {
+		switch constraint, _ := uniqueViolationConstraint(err); {
+		case errors.Is(err, pgx.ErrNoRows):
+			return models.User{}, fmt.Errorf("%w: %w", models.ErrUserNotFound, err)
+		case constraint == usersUsernameKey:
+			return models.User{}, fmt.Errorf("%w: %s", models.ErrUsernameTaken, *input.Username)
+		case constraint == usersEmailKey:
+			return models.User{}, fmt.Errorf("%w: %s", models.ErrEmailTaken, *input.Email)
+		}
+		a.logger.Error("database update failed",
+			zap.Int("user_id", userID),
+			zap.String("operation", "update_user_profile"),
+			zap.Error(err),
+			zap.Duration("db_duration", time.Since(start)),
+		)
+		return models.User{}, fmt.Errorf("could not update user profile: %w", err)
+	}
+
+	a.logger.Info("user profile updated",
+		zap.Int("user_id", userID),
+		zap.Duration("db_duration", time.Since(start)),
+	)
+	return user, nil
+}
+
+// DeleteUser deletes the user together with everything that references it
+// through ON DELETE CASCADE, and returns the deleted user.
+func (a *AuthorizationRepository) DeleteUser(ctx context.Context, userID int) (models.User, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	user, err := scanUser(a.db.QueryRow(ctx, queryDeleteUser, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%w: %w", models.ErrUserNotFound, err)
+		}
+		a.logger.Error("database delete failed",
+			zap.Int("user_id", userID),
+			zap.String("operation", "delete_user"),
+			zap.Error(err),
+			zap.Duration("db_duration", time.Since(start)),
+		)
+		return models.User{}, fmt.Errorf("could not delete user: %w", err)
+	}
+
+	a.logger.Info("user deleted",
+		zap.Int("user_id", userID),
+		zap.Duration("db_duration", time.Since(start)),
+	)
+	return user, nil
+}
+
+func (a *AuthorizationRepository) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	err := a.db.QueryRow(ctx, queryInsertPasswordResetToken, token.UserID, token.TokenHash, token.ExpiresAt.UTC()).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		a.logger.Error("failed to insert password reset token",
+			zap.Int("user_id", token.UserID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to create password reset token: %w", err)
+	}
+	return nil
+}
+
+// ResetPassword consumes the reset token and sets the new password hash of
+// its user. All other outstanding reset tokens of the user are voided too,
+// and in the same transaction the user is signed out everywhere, so the
+// password never changes while old sessions stay valid. It returns the
+// moment the sessions were revoked, as TokenRepository.RevokeUserSessions
+// does.
+func (a *AuthorizationRepository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (models.User, time.Time, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var (
+		user      models.User
+		revokedAt time.Time
+	)
+	err := withTx(ctx, a.db, func(tx pgx.Tx) error {
+		var userID int
+		if err := tx.QueryRow(ctx, queryUsePasswordResetToken, tokenHash).Scan(&userID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return models.ErrInvalidResetToken
+			}
+			return fmt.Errorf("failed to use password reset token: %w", err)
+		}
+
+		var err error
+		if user, err = scanUser(tx.QueryRow(ctx, queryUpdateUserPassword, userID, passwordHash)); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		if _, err = tx.Exec(ctx, queryExpireUserPasswordResetTokens, userID); err != nil {
+			return fmt.Errorf("failed to expire password reset tokens: %w", err)
+		}
+
+		if err = tx.QueryRow(ctx, queryRevokeUserSessions, userID).Scan(&revokedAt); err != nil {
+			return fmt.Errorf("failed to revoke user sessions: %w", err)
+		}
+		if _, err = tx.Exec(ctx, queryRevokeUserRefreshTokens, userID); err != nil {
+			return fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, models.ErrInvalidResetToken) {
+			a.logger.Error("failed to reset password",
+				zap.Error(err),
+				zap.Duration("db_duration", time.Since(start)),
+			)
+		}
+		return models.User{}, time.Time{}, err
+	}
+
+	a.logger.Info("password reset successfully, sessions revoked",
+		zap.Int("user_id", user.ID),
+		zap.Duration("db_duration", time.Since(start)),
+	)
+	return user, revokedAt, nil
+}
+
+func (a *AuthorizationRepository) CreateEmailVerificationToken(ctx context.Context, token *models.EmailVerificationToken) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	err := a.db.QueryRow(ctx, queryInsertEmailVerificationToken, token.UserID, token.TokenHash, token.ExpiresAt.UTC()).
+		Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		a.logger.Error("failed to insert email verification token",
+			zap.Int("user_id", token.UserID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to create email verification token: %w", err)
+	}
+	return nil
+}
+
+// ResendEmailVerificationToken stores a new verification token unless the
+// user was sent one less than models.EmailVerificationResendInterval ago, or
+// models.MaxEmailVerificationsPerHour within the last hour, in which case it
+// fails with a *models.RetryAfterError. The user's row stays locked from the
+// check to the insert, so concurrent resends cannot both pass the limits.
+func (a *AuthorizationRepository) ResendEmailVerificationToken(ctx context.Context, token *models.EmailVerificationToken) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	err := withTx(ctx, a.db, func(tx pgx.Tx) error {
+		var userID int
+		if err := tx.QueryRow(ctx, queryLockUserForVerification, token.UserID).Scan(&userID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: %w", models.ErrUserNotFound, err)
+			}
+			return fmt.Errorf("failed to lock user: %w", err)
+		}
+
+		var (
+			count               int
+			now, oldest, latest time.Time
+		)
+		err := tx.QueryRow(ctx, querySelectEmailVerificationStats, token.UserID).Scan(&count, &now, &oldest, &latest)
+		if err != nil {
+			return fmt.Errorf("failed to count email verification tokens: %w", err)
+		}
+		if wait := models.EmailVerificationResendInterval - now.Sub(latest); count > 0 && wait > 0 {
+			return &models.RetryAfterError{Err: models.ErrTooManyVerificationMails, RetryAfter: wait}
+		}
+		if count >= models.MaxEmailVerificationsPerHour {
+			return &models.RetryAfterError{Err: models.ErrTooManyVerificationMails, RetryAfter: time.Hour - now.Sub(oldest)}
+		}
+
+		if err = tx.QueryRow(ctx, queryInsertEmailVerificationToken, token.UserID, token.TokenHash, token.ExpiresAt.UTC()).
+			Scan(&token.ID, &token.CreatedAt); err != nil {
+			return fmt.Errorf("failed to create email verification token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, models.ErrTooManyVerificationMails) {
+			a.logger.Error("failed to resend email verification token",
+				zap.Int("user_id", token.UserID),
+				zap.Error(err),
+			)
+		}
+		return err
+	}
+	return nil
+}
+
+// VerifyEmail consumes the verification token and marks its user's email as
+// verified. The user's other outstanding verification tokens are voided.
+func (a *AuthorizationRepository) VerifyEmail(ctx context.Context, tokenHash string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var user models.User
+	err := withTx(ctx, a.db, func(tx pgx.Tx) error {
+		var userID int
+		if err := tx.QueryRow(ctx, queryUseEmailVerificationToken, tokenHash).Scan(&userID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return models.ErrInvalidVerificationToken
+			}
+			return fmt.Errorf("failed to use email verification token: %w", err)
+		}
+
+		var err error
+		if user, err = scanUser(tx.QueryRow(ctx, queryMarkEmailVerified, userID)); err != nil {
+			return fmt.Errorf("failed to mark email verified: %w", err)
+		}
+
+		if _, err = tx.Exec(ctx, queryExpireUserEmailVerificationTokens, userID); err != nil {
+			return fmt.Errorf("failed to expire email verification tokens: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, models.ErrInvalidVerificationToken) {
+			a.logger.Error("failed to verify email", zap.Error(err))
+		}
+		return models.User{}, err
+	}
+
+	a.logger.Info("email verified",
+		zap.Int("user_id", user.ID),
+	)
+	return user, nil
+}
+
+// SetMFASecret stores the TOTP secret of a new enrolment, replacing any
+// unconfirmed one. It fails with models.ErrMFAAlreadyEnabled while MFA is on.
+func (a *AuthorizationRepository) SetMFASecret(ctx context.Context, userID int, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	tag, err := a.db.Exec(ctx, querySetMFASecret, userID, secret)
+	if err != nil {
+		a.logger.Error("failed to store mfa secret",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to store mfa secret: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+func (a *AuthorizationRepository) GetUserMFA(ctx context.Context, userID int) (models.UserMFA, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var (
+		mfa    models.UserMFA
+		secret *string
+	)
+	err := a.db.QueryRow(ctx, querySelectUserMFA, userID).Scan(&secret, &mfa.Enabled, &mfa.LastStep)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.UserMFA{}, fmt.Errorf("%w: %w", models.ErrUserNotFound, err)
+		}
+		a.logger.Error("failed to get user mfa",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return models.UserMFA{}, fmt.Errorf("failed to get user mfa: %w", err)
+	}
+	if secret != nil {
+		mfa.Secret = *secret
+	}
+	return mfa, nil
+}
+
+// EnableMFA turns MFA on after the first code, given by its TOTP step, has
+// been checked. The user's recovery codes are replaced by the given hashes.
+// It fails with models.ErrInvalidMFACode when the enrolment changed
+// meanwhile or the step was already used.
+func (a *AuthorizationRepository) EnableMFA(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) (models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var user models.User
+	err := withTx(ctx, a.db, func(tx pgx.Tx) error {
+		var err error
+		if user, err = scanUser(tx.QueryRow(ctx, queryEnableMFA, userID, step)); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return models.ErrInvalidMFACode
+			}
+			return fmt.Errorf("failed to enable mfa: %w", err)
+		}
+
+		if _, err = tx.Exec(ctx, queryDeleteMFARecoveryCodes, userID); err != nil {
+			return fmt.Errorf("failed to delete mfa recovery codes: %w", err)
+		}
+		for _, codeHash := range recoveryCodeHashes {
+			if _, err = tx.Exec(ctx, queryInsertMFARecoveryCode, userID, codeHash); err != nil {
+				return fmt.Errorf("failed to insert mfa recovery code: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if !errors.Is(err, models.ErrInvalidMFACode) {
+			a.logger.Error("failed to enable mfa",
+				zap.Int("user_id", userID),
+				zap.Error(err),
+			)
+		}
+		return models.User{}, err
+	}
+
+	a.logger.Info("mfa enabled",
+		zap.Int("user_id", userID),
+		zap.Int("recovery_codes", len(recoveryCodeHashes)),
+	)
+	return user, nil
+}
+
+// DisableMFA turns MFA off and removes the secret and recovery codes.
+func (a *AuthorizationRepository) DisableMFA(ctx context.Context, userID int) (models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var user models.User
+	err := withTx(ctx, a.db, func(tx pgx.Tx) error {
+		var err error
+		if user, err = scanUser(tx.QueryRow(ctx, queryDisableMFA, userID)); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("%w: %w", models.ErrUserNotFound, err)
+			}
+			return fmt.Errorf("failed to disable mfa: %w", err)
+		}
+
+		if _, err = tx.Exec(ctx, queryDeleteMFARecoveryCodes, userID); err != nil {
+			return fmt.Errorf("failed to delete mfa recovery codes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		a.logger.Error("failed to disable mfa",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return models.User{}, err
+	}
+
+	a.logger.Info("mfa disabled",
+		zap.Int("user_id", userID),
+	)
+	return user, nil
+}
+
+// UseMFAStep records that the TOTP code of the given time step was used. It
+// fails with models.ErrInvalidMFACode if that step or a later one was
+// already used.
+func (a *AuthorizationRepository) UseMFAStep(ctx context.Context, userID int, step int64) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	tag, err := a.db.Exec(ctx, queryUseMFAStep, userID, step)
+	if err != nil {
+		a.logger.Error("failed to record mfa step",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to record mfa step: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrInvalidMFACode
+	}
+	return nil
+}
+
+// UseMFARecoveryCode consumes one of the user's recovery codes. It fails with
+// models.ErrInvalidMFACode for unknown or used codes.
+func (a *AuthorizationRepository) UseMFARecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	tag, err := a.db.Exec(ctx, queryUseMFARecoveryCode, userID, codeHash)
+	if err != nil {
+		a.logger.Error("failed to use mfa recovery code",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to use mfa recovery code: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return models.ErrInvalidMFACode
+	}
+
+	a.logger.Info("mfa recovery code used",
+		zap.Int("user_id", userID),
+	)
+	return nil
+}
+
+func scanUser(row pgx.Row) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.EmailVerified, &user.MFAEnabled)
+	return user, err
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/handler/metrics"
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/cache"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// CachedAPIKeyRepository caches keys by hash, since every request made with
+// an API key looks its key up. The owner's role and the revocation time are
+// not trusted from the cache: roles are changed directly in the database,
+// users are deleted without their keys being known, and a request that was
+// using a key while it was revoked can cache it again. Both are read from
+// Postgres on every hit, so a revoked key or a deleted owner makes the key
+// invalid at once.
+type CachedAPIKeyRepository struct {
+	apiKeyRepo *APIKeyRepository
+	cache      *cache.RedisCache
+	ttls       *LiveCacheTTLs
+	logger     *zap.Logger
+}
+
+func NewCachedAPIKeyRepository(db *pgxpool.Pool, cache *cache.RedisCache, ttls *LiveCacheTTLs, logger *zap.Logger) *CachedAPIKeyRepository {
+	return &CachedAPIKeyRepository{
+		apiKeyRepo: NewAPIKeyRepository(db, logger),
+		cache:      cache,
+		ttls:       ttls,
+		logger:     logger,
+	}
+}
+
+func (c *CachedAPIKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return c.apiKeyRepo.CreateAPIKey(ctx, key)
+}
+
+func (c *CachedAPIKeyRepository) GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	return c.apiKeyRepo.GetAPIKeys(ctx, userID)
+}
+
+func (c *CachedAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error) {
+	cacheKey := apiKeyCacheKey(keyHash)
+
+	var cachedKey models.APIKey
+	err := c.cache.Get(ctx, cacheKey, &cachedKey)
+	if err == nil && cachedKey.ID != 0 {
+		metrics.RecordCacheHit("api_key")
+		// The hash is not serialized; it is the cache key.
+		cachedKey.KeyHash = keyHash
+		if cachedKey.OwnerRole, cachedKey.RevokedAt, err = c.apiKeyRepo.getKeyState(ctx, cachedKey.ID); err != nil {
+			return models.APIKey{}, err
+		}
+		return cachedKey, nil
+	}
+	if err != nil {
+		c.logger.Warn("Redis error when getting api key",
+			zap.Error(err),
+		)
+	}
+
+	metrics.RecordCacheMiss("api_key")
+
+	key, err := c.apiKeyRepo.GetAPIKeyByHash(ctx, keyHash)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	c.cacheKey(ctx, key)
+	return key, nil
+}
+
+func (c *CachedAPIKeyRepository) RevokeAPIKey(ctx context.Context, userID, keyID int) (models.APIKey, error) {
+	key, err := c.apiKeyRepo.RevokeAPIKey(ctx, userID, keyID)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	// A key left in the cache is still rejected, since hits read the
+	// revocation time from Postgres.
+	if cacheErr := c.cache.Delete(ctx, apiKeyCacheKey(key.KeyHash)); cacheErr != nil {
+		c.logger.Warn("Failed to invalidate cached api key",
+			zap.Error(cacheErr),
+			zap.Int("api_key_id", keyID),
+		)
+	}
+
+	return key, nil
+}
+
+// TouchAPIKey also refreshes the cached key, so that its LastUsedAt does not
+// fall behind and cause a database write on every request.
+func (c *CachedAPIKeyRepository) TouchAPIKey(ctx context.Context, key *models.APIKey) error {
+	if err := c.apiKeyRepo.TouchAPIKey(ctx, key); err != nil {
+		return err
+	}
+
+	c.cacheKey(ctx, *key)
+	return nil
+}
+
+func (c *CachedAPIKeyRepository) cacheKey(ctx context.Context, key models.APIKey) {
+	key.OwnerRole = ""
+	if cacheErr := c.cache.Set(ctx, apiKeyCacheKey(key.KeyHash), key, c.ttls.Get().APIKey); cacheErr != nil {
+		c.logger.Warn("Failed to cache api key",
+			zap.Error(cacheErr),
+			zap.Int("api_key_id", key.ID),
+		)
+	}
+}
+
+func apiKeyCacheKey(keyHash string) string {
+	return fmt.Sprintf("apikey:%s", keyHash)
+}
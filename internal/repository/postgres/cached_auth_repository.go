@@ -5,24 +5,28 @@ import (
 	"OrderKeeper/internal/models"
 	"OrderKeeper/internal/repository/cache"
 	"context"
-	"errors"
 	"fmt"
-	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
+	"strings"
 	"time"
 )
 
+// CachedAuthRepository caches user profiles by username. Password hashes are
+// never cached, and every write that changes a profile or the password
+// drops the cached entry.
 type CachedAuthRepository struct {
 	authRepo *AuthorizationRepository
 	cache    *cache.RedisCache
+	ttls     *LiveCacheTTLs
 	logger   *zap.Logger
 }
 
-func NewCachedAuthRepository(db *pgxpool.Pool, cache *cache.RedisCache, logger *zap.Logger) *CachedAuthRepository {
+func NewCachedAuthRepository(db *pgxpool.Pool, cache *cache.RedisCache, ttls *LiveCacheTTLs, logger *zap.Logger) *CachedAuthRepository {
 	return &CachedAuthRepository{
 		authRepo: NewAuthorizationRepository(db, logger),
 		cache:    cache,
+		ttls:     ttls,
 		logger:   logger,
 	}
 }
@@ -35,9 +39,10 @@ func (c *CachedAuthRepository) CreateUser(ctx context.Context, user models.User)
 
 	metrics.RecordUserRegistration()
 
+	// Only the profile is cached; the password hash stays in the database.
 	user.ID = userID
-	cacheKey := fmt.Sprintf("user:username:%s", user.Username)
-	if cacheErr := c.cache.Set(ctx, cacheKey, user, 1*time.Hour); cacheErr != nil {
+	user.Password = ""
+	if cacheErr := c.cache.Set(ctx, userCacheKey(user.Username), user, c.ttls.Get().User); cacheErr != nil {
 		c.logger.Warn("Failed to cache created user",
 			zap.Error(cacheErr),
 			zap.String("username", user.Username),
@@ -47,30 +52,29 @@ func (c *CachedAuthRepository) CreateUser(ctx context.Context, user models.User)
 	return userID, nil
 }
 
-func (c *CachedAuthRepository) GetUser(ctx context.Context, username, password string) (models.User, error) {
-	cacheKey := fmt.Sprintf("user:username:%s", username)
+func (c *CachedAuthRepository) GetUserByUsername(ctx context.Context, username string) (models.User, error) {
+	cacheKey := userCacheKey(username)
 
 	var cachedUser models.User
 	err := c.cache.Get(ctx, cacheKey, &cachedUser)
-	if err == nil {
-		if cachedUser.Password == password {
-			c.logger.Debug("User retrieved from cache",
-				zap.String("username", username),
-			)
-			metrics.RecordCacheHit("user")
-			return cachedUser, nil
-		}
+	if err == nil && cachedUser.ID != 0 {
+		c.logger.Debug("User retrieved from cache",
+			zap.String("username", username),
+		)
+		metrics.RecordCacheHit("user")
+		return cachedUser, nil
 	}
 
-	if errors.Is(err, redis.Nil) {
-		c.logger.Debug("User not found in cache, querying database",
+	if err != nil {
+		c.logger.Warn("Redis error when getting user",
+			zap.Error(err),
 			zap.String("username", username),
 		)
 	}
 
 	metrics.RecordCacheMiss("user")
 
-	user, err := c.authRepo.GetUser(ctx, username, password)
+	user, err := c.authRepo.GetUserByUsername(ctx, username)
 	if err != nil {
 		c.logger.Error("failed to get user from auth repository",
 			zap.Error(err),
@@ -79,7 +83,7 @@ func (c *CachedAuthRepository) GetUser(ctx context.Context, username, password s
 		return models.User{}, fmt.Errorf("failed to get user from auth repository: %w", err)
 	}
 
-	if cacheErr := c.cache.Set(ctx, cacheKey, user, 1*time.Hour); cacheErr != nil {
+	if cacheErr := c.cache.Set(ctx, cacheKey, user, c.ttls.Get().User); cacheErr != nil {
 		c.logger.Warn("Failed to cache retrieved user",
 			zap.Error(cacheErr),
 			zap.String("username", username),
@@ -92,3 +96,143 @@ func (c *CachedAuthRepository) GetUser(ctx context.Context, username, password s
 
 	return user, nil
 }
+
+// GetPasswordHash always reads from the database: password material is
+// never cached.
+func (c *CachedAuthRepository) GetPasswordHash(ctx context.Context, userID int) (string, error) {
+	return c.authRepo.GetPasswordHash(ctx, userID)
+}
+
+func (c *CachedAuthRepository) UpdatePasswordHash(ctx context.Context, userID int, oldHash, newHash string) (bool, error) {
+	return c.authRepo.UpdatePasswordHash(ctx, userID, oldHash, newHash)
+}
+
+func (c *CachedAuthRepository) GetUserByID(ctx context.Context, userID int) (models.User, error) {
+	return c.authRepo.GetUserByID(ctx, userID)
+}
+
+func (c *CachedAuthRepository) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return c.authRepo.GetUserByEmail(ctx, email)
+}
+
+// UpdateUserProfile drops the entry under the previous username: profiles are
+// cached by username, so after a rename nothing else would remove it.
+func (c *CachedAuthRepository) UpdateUserProfile(ctx context.Context, userID int, input models.UserProfileUpdate) (models.User, error) {
+	previous, err := c.authRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err := c.authRepo.UpdateUserProfile(ctx, userID, input)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	c.invalidateUser(ctx, previous.Username, "profile update")
+
+	return user, nil
+}
+
+func (c *CachedAuthRepository) DeleteUser(ctx context.Context, userID int) (models.User, error) {
+	user, err := c.authRepo.DeleteUser(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	c.invalidateUser(ctx, user.Username, "account deletion")
+
+	return user, nil
+}
+
+func (c *CachedAuthRepository) CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	return c.authRepo.CreatePasswordResetToken(ctx, token)
+}
+
+// ResetPassword also publishes the session revocation to Redis, where
+// CachedTokenRepository looks for it.
+func (c *CachedAuthRepository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (models.User, time.Time, error) {
+	user, revokedAt, err := c.authRepo.ResetPassword(ctx, tokenHash, passwordHash)
+	if err != nil {
+		return models.User{}, time.Time{}, err
+	}
+
+	c.invalidateUser(ctx, user.Username, "password reset")
+	cacheSessionsRevoked(ctx, c.cache, user.ID, revokedAt, c.logger)
+
+	return user, revokedAt, nil
+}
+
+func (c *CachedAuthRepository) CreateEmailVerificationToken(ctx context.Context, token *models.EmailVerificationToken) error {
+	return c.authRepo.CreateEmailVerificationToken(ctx, token)
+}
+
+func (c *CachedAuthRepository) ResendEmailVerificationToken(ctx context.Context, token *models.EmailVerificationToken) error {
+	return c.authRepo.ResendEmailVerificationToken(ctx, token)
+}
+
+func (c *CachedAuthRepository) VerifyEmail(ctx context.Context, tokenHash string) (models.User, error) {
+	user, err := c.authRepo.VerifyEmail(ctx, tokenHash)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	c.invalidateUser(ctx, user.Username, "email verification")
+
+	return user, nil
+}
+
+func (c *CachedAuthRepository) SetMFASecret(ctx context.Context, userID int, secret string) error {
+	return c.authRepo.SetMFASecret(ctx, userID, secret)
+}
+
+func (c *CachedAuthRepository) GetUserMFA(ctx context.Context, userID int) (models.UserMFA, error) {
+	return c.authRepo.GetUserMFA(ctx, userID)
+}
+
+func (c *CachedAuthRepository) EnableMFA(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) (models.User, error) {
+	user, err := c.authRepo.EnableMFA(ctx, userID, step, recoveryCodeHashes)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	c.invalidateUser(ctx, user.Username, "enabling mfa")
+
+	return user, nil
+}
+
+func (c *CachedAuthRepository) DisableMFA(ctx context.Context, userID int) (models.User, error) {
+	user, err := c.authRepo.DisableMFA(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	c.invalidateUser(ctx, user.Username, "disabling mfa")
+
+	return user, nil
+}
+
+// One-time codes are checked against the database only, so a code can never
+// be accepted twice through a stale cache entry.
+func (c *CachedAuthRepository) UseMFAStep(ctx context.Context, userID int, step int64) error {
+	return c.authRepo.UseMFAStep(ctx, userID, step)
+}
+
+func (c *CachedAuthRepository) UseMFARecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	return c.authRepo.UseMFARecoveryCode(ctx, userID, codeHash)
+}
+
+func (c *CachedAuthRepository) invalidateUser(ctx context.Context, username, reason string) {
+	if cacheErr := c.cache.Delete(ctx, userCacheKey(username)); cacheErr != nil {
+		c.logger.Warn("Failed to invalidate cached user",
+			zap.Error(cacheErr),
+			zap.String("username", username),
+			zap.String("reason", reason),
+		)
+	}
+}
+
+// userCacheKey is case-insensitive like username lookups, so that every
+// spelling of a username shares the entry that invalidation removes.
+func userCacheKey(username string) string {
+	return fmt.Sprintf("user:profile:%s", strings.ToLower(username))
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/handler/metrics"
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/cache"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"time"
+)
+
+// CachedIdempotencyRepository answers replays of completed requests from
+// Redis. Postgres stays the source of truth for reserving keys, so a Redis
+// outage never lets a duplicate request through.
+type CachedIdempotencyRepository struct {
+	idempotencyRepo *IdempotencyRepository
+	cache           *cache.RedisCache
+	logger          *zap.Logger
+}
+
+func NewCachedIdempotencyRepository(db *pgxpool.Pool, cache *cache.RedisCache, logger *zap.Logger) *CachedIdempotencyRepository {
+	return &CachedIdempotencyRepository{
+		idempotencyRepo: NewIdempotencyRepository(db, logger),
+		cache:           cache,
+		logger:          logger,
+	}
+}
+
+func (c *CachedIdempotencyRepository) ReserveKey(ctx context.Context, record models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+	cacheKey := idempotencyCacheKey(record.UserID, record.Key)
+
+	var cachedRecord models.IdempotencyRecord
+	err := c.cache.Get(ctx, cacheKey, &cachedRecord)
+	if err == nil && cachedRecord.Completed {
+		c.logger.Debug("Idempotent response found in cache",
+			zap.Int("userID", record.UserID),
+			zap.String("key", record.Key),
+		)
+		metrics.RecordCacheHit("idempotency")
+		return cachedRecord, false, nil
+	}
+	if err != nil {
+		c.logger.Warn("Redis error when getting idempotency key",
+			zap.Error(err),
+			zap.Int("userID", record.UserID),
+		)
+	}
+
+	metrics.RecordCacheMiss("idempotency")
+
+	return c.idempotencyRepo.ReserveKey(ctx, record)
+}
+
+func (c *CachedIdempotencyRepository) CompleteKey(ctx context.Context, record models.IdempotencyRecord) error {
+	if err := c.idempotencyRepo.CompleteKey(ctx, record); err != nil {
+		return err
+	}
+
+	ttl := models.IdempotencyKeyTTL - time.Since(record.CreatedAt)
+	if ttl <= 0 {
+		return nil
+	}
+	record.Completed = true
+	if cacheErr := c.cache.Set(ctx, idempotencyCacheKey(record.UserID, record.Key), record, ttl); cacheErr != nil {
+		c.logger.Warn("Failed to cache idempotent response",
+			zap.Error(cacheErr),
+			zap.Int("userID", record.UserID),
+		)
+	}
+
+	return nil
+}
+
+func (c *CachedIdempotencyRepository) ReleaseKey(ctx context.Context, userID int, key string) error {
+	if err := c.idempotencyRepo.ReleaseKey(ctx, userID, key); err != nil {
+		return err
+	}
+
+	if cacheErr := c.cache.Delete(ctx, idempotencyCacheKey(userID, key)); cacheErr != nil {
+		c.logger.Warn("Failed to delete idempotency key from cache",
+			zap.Error(cacheErr),
+			zap.Int("userID", userID),
+		)
+	}
+
+	return nil
+}
+
+func idempotencyCacheKey(userID int, key string) string {
+	return fmt.Sprintf("idempotency:user:%d:key:%s", userID, key)
+}
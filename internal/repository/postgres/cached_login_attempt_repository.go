@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/cache"
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"time"
+)
+
+// CachedLoginAttemptRepository counts failed sign-ins in Redis only, so
+// password guessing never turns into database writes. Whenever Redis fails,
+// Postgres keeps the counters instead; the two are not merged, so counts
+// restart when switching between them.
+type CachedLoginAttemptRepository struct {
+	loginAttemptRepo *LoginAttemptRepository
+	cache            *cache.RedisCache
+	logger           *zap.Logger
+}
+
+func NewCachedLoginAttemptRepository(db *pgxpool.Pool, cache *cache.RedisCache, logger *zap.Logger) *CachedLoginAttemptRepository {
+	return &CachedLoginAttemptRepository{
+		loginAttemptRepo: NewLoginAttemptRepository(db, logger),
+		cache:            cache,
+		logger:           logger,
+	}
+}
+
+func (c *CachedLoginAttemptRepository) GetLoginAttempts(ctx context.Context, key string) (models.LoginAttempts, error) {
+	var attempts models.LoginAttempts
+	err := c.cache.Get(ctx, loginFailuresCacheKey(key), &attempts.Failures)
+	if err == nil && attempts.Failures > 0 {
+		err = c.cache.Get(ctx, loginLastFailureCacheKey(key), &attempts.LastFailureAt)
+	}
+	if err == nil {
+		return attempts, nil
+	}
+
+	c.logger.Warn("Redis error when getting login attempts, falling back to database",
+		zap.Error(err),
+		zap.String("key", key),
+	)
+	return c.loginAttemptRepo.GetLoginAttempts(ctx, key)
+}
+
+func (c *CachedLoginAttemptRepository) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (models.LoginAttempts, error) {
+	now := time.Now()
+	failures, err := c.cache.Increment(ctx, loginFailuresCacheKey(key), window)
+	if err == nil {
+		err = c.cache.Set(ctx, loginLastFailureCacheKey(key), now, window)
+	}
+	if err == nil {
+		return models.LoginAttempts{Failures: int(failures), LastFailureAt: now}, nil
+	}
+
+	c.logger.Warn("Redis error when recording login failure, falling back to database",
+		zap.Error(err),
+		zap.String("key", key),
+	)
+	return c.loginAttemptRepo.RecordLoginFailure(ctx, key, window)
+}
+
+func (c *CachedLoginAttemptRepository) ReleaseLoginAttempt(ctx context.Context, key string) error {
+	err := c.cache.DecrementIfPositive(ctx, loginFailuresCacheKey(key))
+	if err == nil {
+		return nil
+	}
+
+	c.logger.Warn("Redis error when releasing login attempt, falling back to database",
+		zap.Error(err),
+		zap.String("key", key),
+	)
+	return c.loginAttemptRepo.ReleaseLoginAttempt(ctx, key)
+}
+
+// ResetLoginAttempts clears the counters in both stores, since failures may
+// have been recorded in Postgres during a Redis outage.
+func (c *CachedLoginAttemptRepository) ResetLoginAttempts(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		for _, cacheKey := range []string{loginFailuresCacheKey(key), loginLastFailureCacheKey(key)} {
+			if cacheErr := c.cache.Delete(ctx, cacheKey); cacheErr != nil {
+				c.logger.Warn("Failed to reset cached login attempts",
+					zap.Error(cacheErr),
+					zap.String("key", key),
+				)
+			}
+		}
+	}
+
+	return c.loginAttemptRepo.ResetLoginAttempts(ctx, keys...)
+}
+
+func loginFailuresCacheKey(key string) string {
+	return fmt.Sprintf("login:failures:%s", key)
+}
+
+func loginLastFailureCacheKey(key string) string {
+	return fmt.Sprintf("login:last_failure:%s", key)
+}
@@ -5,24 +5,28 @@ import (
 	"OrderKeeper/internal/models"
 	"OrderKeeper/internal/repository/cache"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
-	"time"
 )
 
 type CachedOrderRepository struct {
 	orderRepo *OrderRepository
 	cache     *cache.RedisCache
+	ttls      *LiveCacheTTLs
 	logger    *zap.Logger
 }
 
-func NewCachedOrderRepository(db *pgxpool.Pool, cache *cache.RedisCache, logger *zap.Logger) *CachedOrderRepository {
+func NewCachedOrderRepository(db *pgxpool.Pool, cache *cache.RedisCache, ttls *LiveCacheTTLs, logger *zap.Logger) *CachedOrderRepository {
 	return &CachedOrderRepository{
 		orderRepo: NewOrderRepository(db, logger),
 		cache:     cache,
+		ttls:      ttls,
 		logger:    logger,
 	}
 }
@@ -35,16 +39,10 @@ func (c *CachedOrderRepository) CreateOrder(ctx context.Context, userID int, ord
 
 	metrics.RecordOrder("created")
 
-	listCacheKey := fmt.Sprintf("orders:user:%d", userID)
-	if cacheErr := c.cache.Delete(ctx, listCacheKey); cacheErr != nil {
-		c.logger.Warn("Failed to invalidate order list cache",
-			zap.Error(cacheErr),
-			zap.Int("user_id", userID),
-		)
-	}
+	c.invalidateOrderLists(ctx, userID)
 
 	orderCacheKey := fmt.Sprintf("order:user:%d:id:%d", userID, order.ID)
-	if cacheErr := c.cache.Set(ctx, orderCacheKey, order, 30*time.Minute); cacheErr != nil {
+	if cacheErr := c.cache.Set(ctx, orderCacheKey, order, c.ttls.Get().Order); cacheErr != nil {
 		c.logger.Warn("Failed to cache created order",
 			zap.Error(cacheErr),
 			zap.Int("user_id", userID),
@@ -54,26 +52,27 @@ func (c *CachedOrderRepository) CreateOrder(ctx context.Context, userID int, ord
 
 	return nil
 }
-func (c *CachedOrderRepository) GetOrders(ctx context.Context, userID int) ([]models.Order, error) {
-	cacheKey := fmt.Sprintf("orders:user:%d", userID)
-
-	var cachedOrders []models.Order
-	err := c.cache.Get(ctx, cacheKey, &cachedOrders)
-	if err == nil && len(cachedOrders) > 0 {
-		validOrders := make([]models.Order, 0, len(cachedOrders))
-		for _, order := range cachedOrders {
-			if order.ID > 0 {
-				validOrders = append(validOrders, order)
+func (c *CachedOrderRepository) GetOrders(ctx context.Context, userID int, filter models.OrderFilter) (models.OrderPage, error) {
+	cacheKey := orderListCacheKey(userID, filter)
+
+	var cachedPage models.OrderPage
+	err := c.cache.Get(ctx, cacheKey, &cachedPage)
+	if err == nil && len(cachedPage.Orders) > 0 {
+		valid := true
+		for _, order := range cachedPage.Orders {
+			if order.ID <= 0 {
+				valid = false
+				break
 			}
 		}
 
-		if len(validOrders) > 0 {
+		if valid {
 			c.logger.Debug("Valid orders found in cache",
 				zap.Int("userID", userID),
-				zap.Int("count", len(validOrders)),
+				zap.Int("count", len(cachedPage.Orders)),
 			)
 			metrics.RecordCacheHit("user_orders")
-			return validOrders, nil
+			return cachedPage, nil
 		} else {
 			c.logger.Warn("Cache contained invalid orders, invalidating",
 				zap.Int("userID", userID),
@@ -91,25 +90,25 @@ func (c *CachedOrderRepository) GetOrders(ctx context.Context, userID int) ([]mo
 
 	metrics.RecordCacheMiss("user_orders")
 
-	orders, err := c.orderRepo.GetOrders(ctx, userID)
+	page, err := c.orderRepo.GetOrders(ctx, userID, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get orders from orders repository: %w", err)
+		return models.OrderPage{}, fmt.Errorf("failed to get orders from orders repository: %w", err)
 	}
 
-	if len(orders) > 0 {
-		if cacheErr := c.cache.Set(ctx, cacheKey, orders, 15*time.Minute); cacheErr != nil {
+	if len(page.Orders) > 0 {
+		if cacheErr := c.cache.Set(ctx, cacheKey, page, c.ttls.Get().OrderList); cacheErr != nil {
 			c.logger.Warn("Failed to cache orders",
 				zap.Error(cacheErr),
 				zap.Int("userID", userID))
 		} else {
 			c.logger.Debug("Orders cached successfully",
 				zap.Int("userID", userID),
-				zap.Int("count", len(orders)),
+				zap.Int("count", len(page.Orders)),
 			)
 		}
 	}
 
-	return orders, nil
+	return page, nil
 }
 
 func (c *CachedOrderRepository) GetOrderByID(ctx context.Context, userID int, orderID int) (models.Order, error) {
@@ -152,7 +151,7 @@ func (c *CachedOrderRepository) GetOrderByID(ctx context.Context, userID int, or
 	}
 
 	if order.ID > 0 {
-		if cacheErr := c.cache.Set(ctx, cacheKey, order, 30*time.Minute); cacheErr != nil {
+		if cacheErr := c.cache.Set(ctx, cacheKey, order, c.ttls.Get().Order); cacheErr != nil {
 			c.logger.Warn("Failed to cache order",
 				zap.Error(cacheErr),
 				zap.Int("orderID", orderID))
@@ -167,9 +166,9 @@ func (c *CachedOrderRepository) GetOrderByID(ctx context.Context, userID int, or
 	return order, nil
 }
 
-func (c *CachedOrderRepository) UpdateOrder(ctx context.Context, userID int, orderID int, input models.OrderUpdateInput) error {
+func (c *CachedOrderRepository) UpdateOrder(ctx context.Context, userID int, orderID int, actorID int, input models.OrderUpdateInput) error {
 
-	err := c.orderRepo.UpdateOrder(ctx, userID, orderID, input)
+	err := c.orderRepo.UpdateOrder(ctx, userID, orderID, actorID, input)
 	if err != nil {
 		return err
 	}
@@ -177,7 +176,6 @@ func (c *CachedOrderRepository) UpdateOrder(ctx context.Context, userID int, ord
 	metrics.RecordOrder("updated")
 
 	orderCacheKey := fmt.Sprintf("order:user:%d:id:%d", userID, orderID)
-	listCacheKey := fmt.Sprintf("orders:user:%d", userID)
 
 	if cacheErr := c.cache.Delete(ctx, orderCacheKey); cacheErr != nil {
 		c.logger.Warn("Failed to invalidate order cache",
@@ -185,18 +183,30 @@ func (c *CachedOrderRepository) UpdateOrder(ctx context.Context, userID int, ord
 			zap.Int("orderID", orderID))
 	}
 
-	if cacheErr := c.cache.Delete(ctx, listCacheKey); cacheErr != nil {
-		c.logger.Warn("Failed to invalidate orders list cache",
-			zap.Error(cacheErr),
-			zap.Int("userID", userID))
-	}
+	c.invalidateOrderLists(ctx, userID)
 
 	return nil
 }
 
-func (c *CachedOrderRepository) DeleteOrder(ctx context.Context, userID int, orderID int) error {
+// SearchOrders serves the admin view, which must always be current, so it is
+// never cached.
+func (c *CachedOrderRepository) SearchOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error) {
+	return c.orderRepo.SearchOrders(ctx, filter)
+}
+
+func (c *CachedOrderRepository) GetOrderOwner(ctx context.Context, orderID int) (int, error) {
+	return c.orderRepo.GetOrderOwner(ctx, orderID)
+}
+
+// GetOrderHistory is not cached: the timeline is read rarely and must
+// reflect every transition immediately.
+func (c *CachedOrderRepository) GetOrderHistory(ctx context.Context, userID int, orderID int) ([]models.OrderStatusChange, error) {
+	return c.orderRepo.GetOrderHistory(ctx, userID, orderID)
+}
 
-	err := c.orderRepo.DeleteOrder(ctx, userID, orderID)
+func (c *CachedOrderRepository) DeleteOrder(ctx context.Context, userID int, orderID int, expectedVersion *int) error {
+
+	err := c.orderRepo.DeleteOrder(ctx, userID, orderID, expectedVersion)
 	if err != nil {
 		return err
 	}
@@ -204,7 +214,6 @@ func (c *CachedOrderRepository) DeleteOrder(ctx context.Context, userID int, ord
 	metrics.RecordOrder("deleted")
 
 	orderCacheKey := fmt.Sprintf("order:user:%d:id:%d", userID, orderID)
-	listCacheKey := fmt.Sprintf("orders:user:%d", userID)
 
 	if cacheErr := c.cache.Delete(ctx, orderCacheKey); cacheErr != nil {
 		c.logger.Warn("Failed to delete order from cache",
@@ -212,11 +221,26 @@ func (c *CachedOrderRepository) DeleteOrder(ctx context.Context, userID int, ord
 			zap.Int("orderID", orderID))
 	}
 
-	if cacheErr := c.cache.Delete(ctx, listCacheKey); cacheErr != nil {
+	c.invalidateOrderLists(ctx, userID)
+
+	return nil
+}
+
+// invalidateOrderLists drops every cached page of the user's orders,
+// whatever filter it was cached for.
+func (c *CachedOrderRepository) invalidateOrderLists(ctx context.Context, userID int) {
+	pattern := fmt.Sprintf("orders:user:%d:*", userID)
+	if cacheErr := c.cache.DeletePattern(ctx, pattern); cacheErr != nil {
 		c.logger.Warn("Failed to invalidate orders list cache",
 			zap.Error(cacheErr),
 			zap.Int("userID", userID))
 	}
+}
 
-	return nil
+// orderListCacheKey identifies a page of a user's orders by the hash of its
+// query shape: filters, sort, limit and cursor.
+func orderListCacheKey(userID int, filter models.OrderFilter) string {
+	shape, _ := json.Marshal(filter)
+	sum := sha256.Sum256(shape)
+	return fmt.Sprintf("orders:user:%d:query:%s", userID, hex.EncodeToString(sum[:16]))
 }
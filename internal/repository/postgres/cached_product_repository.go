@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/handler/metrics"
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/cache"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+type CachedProductRepository struct {
+	productRepo *ProductRepository
+	cache       *cache.RedisCache
+	ttls        *LiveCacheTTLs
+	logger      *zap.Logger
+}
+
+func NewCachedProductRepository(db *pgxpool.Pool, cache *cache.RedisCache, ttls *LiveCacheTTLs, logger *zap.Logger) *CachedProductRepository {
+	return &CachedProductRepository{
+		productRepo: NewProductRepository(db, logger),
+		cache:       cache,
+		ttls:        ttls,
+		logger:      logger,
+	}
+}
+
+func (c *CachedProductRepository) CreateProduct(ctx context.Context, product *models.Product) error {
+	if err := c.productRepo.CreateProduct(ctx, product); err != nil {
+		return err
+	}
+
+	c.invalidateProductLists(ctx)
+
+	return nil
+}
+
+func (c *CachedProductRepository) GetProducts(ctx context.Context, includeArchived bool) ([]models.Product, error) {
+	cacheKey := productListCacheKey(includeArchived)
+
+	var cachedProducts []models.Product
+	err := c.cache.Get(ctx, cacheKey, &cachedProducts)
+	if err == nil && len(cachedProducts) > 0 {
+		c.logger.Debug("Products found in cache",
+			zap.Bool("include_archived", includeArchived),
+			zap.Int("count", len(cachedProducts)),
+		)
+		metrics.RecordCacheHit("products")
+		return cachedProducts, nil
+	}
+
+	if !errors.Is(err, redis.Nil) && err != nil {
+		c.logger.Warn("Redis error when getting products",
+			zap.Error(err),
+		)
+	}
+
+	metrics.RecordCacheMiss("products")
+
+	products, err := c.productRepo.GetProducts(ctx, includeArchived)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get products from product repository: %w", err)
+	}
+
+	if len(products) > 0 {
+		if cacheErr := c.cache.Set(ctx, cacheKey, products, c.ttls.Get().ProductList); cacheErr != nil {
+			c.logger.Warn("Failed to cache products",
+				zap.Error(cacheErr),
+			)
+		}
+	}
+
+	return products, nil
+}
+
+func (c *CachedProductRepository) GetProductByID(ctx context.Context, productID int) (models.Product, error) {
+	cacheKey := fmt.Sprintf("product:id:%d", productID)
+
+	var cachedProduct models.Product
+	err := c.cache.Get(ctx, cacheKey, &cachedProduct)
+	if err == nil && cachedProduct.ID == productID {
+		c.logger.Debug("Product found in cache",
+			zap.Int("productID", productID),
+		)
+		metrics.RecordCacheHit("product")
+		return cachedProduct, nil
+	}
+
+	if !errors.Is(err, redis.Nil) && err != nil {
+		c.logger.Warn("Redis error when getting product",
+			zap.Error(err),
+			zap.Int("productID", productID),
+		)
+	}
+
+	metrics.RecordCacheMiss("product")
+
+	product, err := c.productRepo.GetProductByID(ctx, productID)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	if cacheErr := c.cache.Set(ctx, cacheKey, product, c.ttls.Get().Product); cacheErr != nil {
+		c.logger.Warn("Failed to cache product",
+			zap.Error(cacheErr),
+			zap.Int("productID", productID),
+		)
+	}
+
+	return product, nil
+}
+
+// GetProductsBySKUs always reads from the database: it backs order creation,
+// which must see the current price and availability of every product.
+func (c *CachedProductRepository) GetProductsBySKUs(ctx context.Context, skus []string) ([]models.Product, error) {
+	return c.productRepo.GetProductsBySKUs(ctx, skus)
+}
+
+// Stock levels change with every order, so they are never cached.
+func (c *CachedProductRepository) GetStock(ctx context.Context, productID int) (models.ProductStock, error) {
+	return c.productRepo.GetStock(ctx, productID)
+}
+
+func (c *CachedProductRepository) SetStock(ctx context.Context, productID int, onHand int) (models.ProductStock, error) {
+	return c.productRepo.SetStock(ctx, productID, onHand)
+}
+
+func (c *CachedProductRepository) UpdateProduct(ctx context.Context, productID int, input models.ProductUpdateInput) (models.Product, error) {
+	product, err := c.productRepo.UpdateProduct(ctx, productID, input)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	c.invalidateProduct(ctx, productID)
+
+	return product, nil
+}
+
+func (c *CachedProductRepository) ArchiveProduct(ctx context.Context, productID int) error {
+	if err := c.productRepo.ArchiveProduct(ctx, productID); err != nil {
+		return err
+	}
+
+	c.invalidateProduct(ctx, productID)
+
+	return nil
+}
+
+func (c *CachedProductRepository) invalidateProduct(ctx context.Context, productID int) {
+	cacheKey := fmt.Sprintf("product:id:%d", productID)
+	if cacheErr := c.cache.Delete(ctx, cacheKey); cacheErr != nil {
+		c.logger.Warn("Failed to invalidate product cache",
+			zap.Error(cacheErr),
+			zap.Int("productID", productID),
+		)
+	}
+
+	c.invalidateProductLists(ctx)
+}
+
+func (c *CachedProductRepository) invalidateProductLists(ctx context.Context) {
+	for _, includeArchived := range []bool{false, true} {
+		if cacheErr := c.cache.Delete(ctx, productListCacheKey(includeArchived)); cacheErr != nil {
+			c.logger.Warn("Failed to invalidate product list cache",
+				zap.Error(cacheErr),
+				zap.Bool("include_archived", includeArchived),
+			)
+		}
+	}
+}
+
+func productListCacheKey(includeArchived bool) string {
+	if includeArchived {
+		return "products:all"
+	}
+	return "products:active"
+}
@@ -0,0 +1,174 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/handler/metrics"
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/cache"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"time"
+)
+
+// CachedTokenRepository keeps the access token denylist in Redis, where it is
+// checked on every authenticated request. Revocations are also written to
+// Postgres, which answers the check whenever Redis is unavailable. Refresh
+// tokens are only ever read from Postgres.
+type CachedTokenRepository struct {
+	tokenRepo *TokenRepository
+	cache     *cache.RedisCache
+	logger    *zap.Logger
+}
+
+func NewCachedTokenRepository(db *pgxpool.Pool, cache *cache.RedisCache, logger *zap.Logger) *CachedTokenRepository {
+	return &CachedTokenRepository{
+		tokenRepo: NewTokenRepository(db, logger),
+		cache:     cache,
+		logger:    logger,
+	}
+}
+
+func (c *CachedTokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return c.tokenRepo.CreateRefreshToken(ctx, token)
+}
+
+func (c *CachedTokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	return c.tokenRepo.GetRefreshToken(ctx, tokenHash)
+}
+
+func (c *CachedTokenRepository) RotateRefreshToken(ctx context.Context, tokenID int, next *models.RefreshToken) error {
+	return c.tokenRepo.RotateRefreshToken(ctx, tokenID, next)
+}
+
+func (c *CachedTokenRepository) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	return c.tokenRepo.RevokeTokenFamily(ctx, familyID)
+}
+
+func (c *CachedTokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := c.tokenRepo.RevokeAccessToken(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	if cacheErr := c.cache.Set(ctx, revokedAccessTokenCacheKey(jti), true, ttl); cacheErr != nil {
+		c.logger.Warn("Failed to cache revoked access token",
+			zap.Error(cacheErr),
+			zap.String("jti", jti),
+		)
+	}
+
+	return nil
+}
+
+// RevokeUserSessions also publishes the revocation time to Redis. The entry
+// only has to outlive the access tokens issued before it.
+func (c *CachedTokenRepository) RevokeUserSessions(ctx context.Context, userID int) (time.Time, error) {
+	revokedAt, err := c.tokenRepo.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	cacheSessionsRevoked(ctx, c.cache, userID, revokedAt, c.logger)
+
+	return revokedAt, nil
+}
+
+// IsAccessTokenRevoked answers from Redis when both the token's denylist
+// entry and its user's revocation time are cached. A missing entry, as after
+// a flush, eviction or restart of Redis, is looked up in Postgres and cached
+// again; it never counts as "not revoked" on its own.
+func (c *CachedTokenRepository) IsAccessTokenRevoked(ctx context.Context, claims models.AccessTokenClaims) (bool, error) {
+	var (
+		denylisted        *bool
+		sessionsRevokedAt *time.Time
+	)
+	err := c.cache.Get(ctx, revokedAccessTokenCacheKey(claims.ID), &denylisted)
+	if err == nil && (denylisted == nil || !*denylisted) {
+		err = c.cache.Get(ctx, userSessionsRevokedCacheKey(claims.UserID), &sessionsRevokedAt)
+	}
+	if err != nil {
+		c.logger.Warn("Redis error when checking revoked access token, falling back to database",
+			zap.Error(err),
+			zap.String("jti", claims.ID),
+		)
+		metrics.RecordCacheMiss("revoked_token")
+		return c.tokenRepo.IsAccessTokenRevoked(ctx, claims)
+	}
+
+	if denylisted != nil && *denylisted {
+		metrics.RecordCacheHit("revoked_token")
+		return true, nil
+	}
+	if denylisted != nil && sessionsRevokedAt != nil {
+		metrics.RecordCacheHit("revoked_token")
+		return issuedBefore(claims, *sessionsRevokedAt), nil
+	}
+
+	metrics.RecordCacheMiss("revoked_token")
+
+	if denylisted == nil {
+		value, err := c.tokenRepo.isAccessTokenDenylisted(ctx, claims.ID)
+		if err != nil {
+			return false, err
+		}
+		if ttl := time.Until(claims.ExpiresAt); ttl > 0 {
+			c.fillCache(ctx, revokedAccessTokenCacheKey(claims.ID), value, ttl)
+		}
+		if value {
+			return true, nil
+		}
+	}
+
+	if sessionsRevokedAt == nil {
+		value, err := c.tokenRepo.getSessionsRevokedAt(ctx, claims.UserID)
+		if err != nil {
+			if errors.Is(err, models.ErrUserNotFound) {
+				return true, nil
+			}
+			return false, err
+		}
+		c.fillCache(ctx, userSessionsRevokedCacheKey(claims.UserID), value, models.AccessTokenTTL)
+		sessionsRevokedAt = &value
+	}
+	return issuedBefore(claims, *sessionsRevokedAt), nil
+}
+
+// fillCache stores a value read from Postgres unless a revocation has been
+// cached since, which must not be overwritten with the older answer.
+func (c *CachedTokenRepository) fillCache(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	if _, err := c.cache.SetIfAbsent(ctx, key, value, ttl); err != nil {
+		c.logger.Warn("Failed to cache token revocation state",
+			zap.Error(err),
+			zap.String("key", key),
+		)
+	}
+}
+
+// issuedBefore compares at whole seconds, the precision of the iat claim.
+func issuedBefore(claims models.AccessTokenClaims, sessionsRevokedAt time.Time) bool {
+	return claims.IssuedAt.Before(sessionsRevokedAt.Truncate(time.Second))
+}
+
+func revokedAccessTokenCacheKey(jti string) string {
+	return fmt.Sprintf("token:revoked:%s", jti)
+}
+
+func userSessionsRevokedCacheKey(userID int) string {
+	return fmt.Sprintf("token:user:%d:sessions_revoked_at", userID)
+}
+
+// cacheSessionsRevoked stores when the user's sessions were revoked, for
+// IsAccessTokenRevoked to compare token issue times against.
+func cacheSessionsRevoked(ctx context.Context, redisCache *cache.RedisCache, userID int, revokedAt time.Time, logger *zap.Logger) {
+	if err := redisCache.Set(ctx, userSessionsRevokedCacheKey(userID), revokedAt, models.AccessTokenTTL); err != nil {
+		logger.Warn("Failed to cache user session revocation",
+			zap.Error(err),
+			zap.Int("userID", userID),
+		)
+	}
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"time"
+)
+
+type IdempotencyRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewIdempotencyRepository(db *pgxpool.Pool, logger *zap.Logger) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// ReserveKey claims record.Key for the user. When the key is new it returns
+// the reserved record and true; otherwise it returns the stored record, which
+// may still be in progress, and false. Expired keys, and keys whose request
+// did not finish within models.IdempotencyLease, are claimed anew.
+func (i *IdempotencyRepository) ReserveKey(ctx context.Context, record models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	_, err := i.db.Exec(ctx, queryDeleteExpiredIdempotencyKey, record.UserID, record.Key,
+		time.Now().UTC().Add(-models.IdempotencyKeyTTL))
+	if err != nil {
+		return models.IdempotencyRecord{}, false, fmt.Errorf("failed to expire idempotency key: %w", err)
+	}
+
+	err = i.db.QueryRow(ctx, queryInsertIdempotencyKey, record.UserID, record.Key, record.Fingerprint,
+		models.IdempotencyLease.Seconds()).
+		Scan(&record.CreatedAt)
+	if err == nil {
+		i.logger.Debug("idempotency key reserved",
+			zap.Int("user_id", record.UserID),
+			zap.String("key", record.Key),
+			zap.Duration("db_duration", time.Since(start)),
+		)
+		return record, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		i.logger.Error("failed to reserve idempotency key",
+			zap.Int("user_id", record.UserID),
+			zap.String("key", record.Key),
+			zap.Error(err),
+		)
+		return models.IdempotencyRecord{}, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	stored := models.IdempotencyRecord{UserID: record.UserID, Key: record.Key}
+	err = i.db.QueryRow(ctx, querySelectIdempotencyKey, record.UserID, record.Key).
+		Scan(&stored.Fingerprint, &stored.Completed, &stored.StatusCode, &stored.ResponseBody, &stored.CreatedAt)
+	if err != nil {
+		i.logger.Error("failed to read idempotency key",
+			zap.Int("user_id", record.UserID),
+			zap.String("key", record.Key),
+			zap.Error(err),
+		)
+		return models.IdempotencyRecord{}, false, fmt.Errorf("failed to read idempotency key: %w", err)
+	}
+
+	i.logger.Debug("idempotency key already used",
+		zap.Int("user_id", record.UserID),
+		zap.String("key", record.Key),
+		zap.Bool("completed", stored.Completed),
+		zap.Duration("db_duration", time.Since(start)),
+	)
+	return stored, false, nil
+}
+
+func (i *IdempotencyRepository) CompleteKey(ctx context.Context, record models.IdempotencyRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	_, err := i.db.Exec(ctx, queryCompleteIdempotencyKey, record.UserID, record.Key, record.StatusCode, record.ResponseBody)
+	if err != nil {
+		i.logger.Error("failed to store idempotent response",
+			zap.Int("user_id", record.UserID),
+			zap.String("key", record.Key),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (i *IdempotencyRepository) ReleaseKey(ctx context.Context, userID int, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	if _, err := i.db.Exec(ctx, queryDeleteIdempotencyKey, userID, key); err != nil {
+		i.logger.Error("failed to release idempotency key",
+			zap.Int("user_id", userID),
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"time"
+)
+
+type LoginAttemptRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewLoginAttemptRepository(db *pgxpool.Pool, logger *zap.Logger) *LoginAttemptRepository {
+	return &LoginAttemptRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (l *LoginAttemptRepository) GetLoginAttempts(ctx context.Context, key string) (models.LoginAttempts, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var attempts models.LoginAttempts
+	err := l.db.QueryRow(ctx, querySelectLoginAttempts, key, time.Now().UTC()).
+		Scan(&attempts.Failures, &attempts.LastFailureAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.LoginAttempts{}, nil
+		}
+		l.logger.Error("failed to get login attempts",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return models.LoginAttempts{}, fmt.Errorf("failed to get login attempts: %w", err)
+	}
+	return attempts, nil
+}
+
+// RecordLoginFailure counts a failed sign-in for key. The counter is kept
+// until window has passed without another failure. Expired counters are
+// purged on the way.
+func (l *LoginAttemptRepository) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (models.LoginAttempts, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	if _, err := l.db.Exec(ctx, queryDeleteExpiredLoginAttempts, now); err != nil {
+		l.logger.Warn("failed to purge expired login attempts", zap.Error(err))
+	}
+
+	var attempts models.LoginAttempts
+	err := l.db.QueryRow(ctx, queryRecordLoginFailure, key, now, now.Add(window)).
+		Scan(&attempts.Failures, &attempts.LastFailureAt)
+	if err != nil {
+		l.logger.Error("failed to record login failure",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return models.LoginAttempts{}, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return attempts, nil
+}
+
+// ReleaseLoginAttempt takes back one counted attempt for key, for an attempt
+// that was counted up front and then succeeded.
+func (l *LoginAttemptRepository) ReleaseLoginAttempt(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	if _, err := l.db.Exec(ctx, queryReleaseLoginAttempt, key); err != nil {
+		l.logger.Error("failed to release login attempt",
+			zap.String("key", key),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+	return nil
+}
+
+func (l *LoginAttemptRepository) ResetLoginAttempts(ctx context.Context, keys ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	if _, err := l.db.Exec(ctx, queryDeleteLoginAttempts, keys); err != nil {
+		l.logger.Error("failed to reset login attempts",
+			zap.Strings("keys", keys),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/models"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// orderCursor is the keyset position after the last order of a page. It is
+// handed to clients as an opaque base64 string.
+type orderCursor struct {
+	CreatedAt time.Time        `json:"c"`
+	ID        int              `json:"i"`
+	Sort      models.OrderSort `json:"s"`
+}
+
+func encodeOrderCursor(order models.Order, sort models.OrderSort) string {
+	data, _ := json.Marshal(orderCursor{
+		CreatedAt: order.CreatedAt,
+		ID:        order.ID,
+		Sort:      sort,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeOrderCursor(value string, sort models.OrderSort) (*orderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", models.ErrInvalidOrderFilter)
+	}
+
+	var cursor orderCursor
+	if err = json.Unmarshal(data, &cursor); err != nil || cursor.ID <= 0 {
+		return nil, fmt.Errorf("%w: malformed cursor", models.ErrInvalidOrderFilter)
+	}
+	if cursor.Sort != sort {
+		return nil, fmt.Errorf("%w: cursor was issued for sort %s", models.ErrInvalidOrderFilter, cursor.Sort)
+	}
+	return &cursor, nil
+}
+
+// buildOrdersQuery appends the filter, keyset and ordering clauses to base,
+// whose placeholders are already bound to args.
+func buildOrdersQuery(base string, args []any, filter models.OrderFilter, cursor *orderCursor) (string, []any) {
+	var query strings.Builder
+	query.WriteString(base)
+
+	condition := func(clause string, value any) {
+		args = append(args, value)
+		fmt.Fprintf(&query, " AND "+clause, len(args))
+	}
+
+	if filter.UserID != nil {
+		condition("user_id = $%d", *filter.UserID)
+	}
+	if filter.SKU != "" {
+		condition("EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id AND order_items.sku = $%d)", filter.SKU)
+	}
+	if len(filter.Statuses) > 0 {
+		condition("status::text = ANY($%d)", statusStrings(filter.Statuses))
+	}
+	if filter.CreatedFrom != nil {
+		condition("created_at >= $%d", filter.CreatedFrom.UTC())
+	}
+	if filter.CreatedTo != nil {
+		condition("created_at < $%d", filter.CreatedTo.UTC())
+	}
+	if filter.UpdatedFrom != nil {
+		condition("updated_at >= $%d", filter.UpdatedFrom.UTC())
+	}
+	if filter.UpdatedTo != nil {
+		condition("updated_at < $%d", filter.UpdatedTo.UTC())
+	}
+
+	direction, comparison := "DESC", "<"
+	if filter.Sort == models.SortCreatedAtAsc {
+		direction, comparison = "ASC", ">"
+	}
+	if cursor != nil {
+		args = append(args, cursor.CreatedAt, cursor.ID)
+		fmt.Fprintf(&query, " AND (created_at, id) %s ($%d, $%d)", comparison, len(args)-1, len(args))
+	}
+
+	// One extra row tells whether another page follows.
+	args = append(args, filter.Limit+1)
+	fmt.Fprintf(&query, " ORDER BY created_at %s, id %s LIMIT $%d", direction, direction, len(args))
+
+	return query.String(), args
+}
@@ -34,9 +34,41 @@ func (o *OrderRepository) CreateOrder(ctx context.Context, userID int, order *mo
 	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
 
-	_, err := o.db.Exec(ctx, queryInsertOrder, userID, string(order.Status))
+	err := withTx(ctx, o.db, func(tx pgx.Tx) error {
+		if err := reserveStock(ctx, tx, order.Items); err != nil {
+			return err
+		}
+
+		err := tx.QueryRow(ctx, queryInsertOrder, userID, string(order.Status), order.Currency, order.Subtotal, order.Total).
+			Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		order.UserID = userID
+
+		for i := range order.Items {
+			item := &order.Items[i]
+			item.OrderID = order.ID
+			err = tx.QueryRow(ctx, queryInsertOrderItem, order.ID, item.SKU, item.Quantity,
+				item.UnitPrice, item.Currency, item.LineTotal).Scan(&item.ID)
+			if err != nil {
+				return fmt.Errorf("failed to insert order item %s: %w", item.SKU, err)
+			}
+		}
+
+		return insertStatusChange(ctx, tx, order.ID, nil, order.Status, userID, nil)
+	})
 	duration := time.Since(start)
 	if err != nil {
+		var stockErr *models.InsufficientStockError
+		if errors.As(err, &stockErr) {
+			o.logger.Warn("insufficient stock for order",
+				zap.Int("user_id", userID),
+				zap.Int("short_items", len(stockErr.Shortages)),
+				zap.Duration("total_duration", duration),
+			)
+			return err
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			o.logger.Error("database query timeout",
 				zap.Int("user_id", userID),
@@ -59,7 +91,9 @@ func (o *OrderRepository) CreateOrder(ctx context.Context, userID int, order *mo
 	}
 	o.logger.Info("order created successfully",
 		zap.Int("user_id", userID),
+		zap.Int("order_id", order.ID),
 		zap.String("status", string(order.Status)),
+		zap.Int("items", len(order.Items)),
 		zap.Duration("total_duration", duration),
 	)
 
@@ -75,45 +109,29 @@ func (o *OrderRepository) CreateOrder(ctx context.Context, userID int, order *mo
 	return nil
 
 }
-func (o *OrderRepository) GetOrders(ctx context.Context, userID int) ([]models.Order, error) {
+func (o *OrderRepository) GetOrders(ctx context.Context, userID int, filter models.OrderFilter) (models.OrderPage, error) {
 	start := time.Now()
 	o.logger.Debug("fetching orders for user",
 		zap.Int("user_id", userID),
+		zap.Int("limit", filter.Limit),
+		zap.String("sort", string(filter.Sort)),
 		zap.String("operation", "get_orders"),
 	)
 
-	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
-	defer cancel()
-	rows, err := o.db.Query(ctx, querySelectOrdersByUser, userID)
+	page, duration, err := o.fetchOrderPage(ctx, querySelectOrdersByUser, []any{userID}, filter)
 	if err != nil {
 		o.logger.Error("failed to fetch orders",
 			zap.Int("user_id", userID),
 			zap.Error(err),
 			zap.Duration("total_duration", time.Since(start)),
 		)
-		return nil, fmt.Errorf("failed to fetch orders: %w", err)
-	}
-	defer rows.Close()
-	duration := time.Since(start)
-
-	var orders []models.Order
-	for rows.Next() {
-		var order models.Order
-		err := rows.Scan(&order.ID, &order.UserID, &order.Status, &order.CreatedAt, &order.UpdatedAt)
-		if err != nil {
-			o.logger.Error("failed to scan order",
-				zap.Int("user_id", userID),
-				zap.Error(err),
-				zap.Duration("total_duration", time.Since(start)),
-			)
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-		orders = append(orders, order)
+		return models.OrderPage{}, err
 	}
 
 	o.logger.Info("orders fetched successfully",
 		zap.Int("user_id", userID),
-		zap.Int("order_count", len(orders)),
+		zap.Int("order_count", len(page.Orders)),
+		zap.Bool("has_more", page.NextCursor != ""),
 		zap.Duration("total_duration", time.Since(start)),
 	)
 
@@ -126,8 +144,114 @@ func (o *OrderRepository) GetOrders(ctx context.Context, userID int) ([]models.O
 		)
 	}
 
-	return orders, nil
+	return page, nil
+}
+
+// SearchOrders returns a page of orders across all users.
+func (o *OrderRepository) SearchOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error) {
+	start := time.Now()
+	o.logger.Debug("searching orders",
+		zap.Int("limit", filter.Limit),
+		zap.String("sort", string(filter.Sort)),
+		zap.String("operation", "search_orders"),
+	)
+
+	page, duration, err := o.fetchOrderPage(ctx, querySelectOrders, nil, filter)
+	if err != nil {
+		o.logger.Error("failed to search orders",
+			zap.Error(err),
+			zap.Duration("total_duration", time.Since(start)),
+		)
+		return models.OrderPage{}, err
+	}
+
+	o.logger.Info("orders searched successfully",
+		zap.Int("order_count", len(page.Orders)),
+		zap.Bool("has_more", page.NextCursor != ""),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+
+	if duration > SlowQueryThreshold {
+		o.logger.Warn("slow database query detected",
+			zap.String("operation", "search_orders"),
+			zap.Duration("db_duration", duration),
+			zap.Duration("threshold", SlowQueryThreshold),
+		)
+	}
+
+	return page, nil
+}
+
+// GetOrderOwner returns the ID of the user who placed the order.
+func (o *OrderRepository) GetOrderOwner(ctx context.Context, orderID int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var userID int
+	if err := o.db.QueryRow(ctx, querySelectOrderOwner, orderID).Scan(&userID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("%w: %w", models.ErrOrderNotFound, err)
+		}
+		o.logger.Error("failed to fetch order owner",
+			zap.Int("order_id", orderID),
+			zap.Error(err),
+		)
+		return 0, fmt.Errorf("failed to fetch order owner: %w", err)
+	}
+	return userID, nil
+}
+
+// fetchOrderPage runs base, extended by buildOrdersQuery, and returns one page
+// of orders with their items together with the duration of the main query.
+func (o *OrderRepository) fetchOrderPage(ctx context.Context, base string, args []any, filter models.OrderFilter) (models.OrderPage, time.Duration, error) {
+	start := time.Now()
+
+	var cursor *orderCursor
+	if filter.Cursor != "" {
+		var err error
+		if cursor, err = decodeOrderCursor(filter.Cursor, filter.Sort); err != nil {
+			return models.OrderPage{}, 0, err
+		}
+	}
+	query, args := buildOrdersQuery(base, args, filter, cursor)
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+	rows, err := o.db.Query(ctx, query, args...)
+	if err != nil {
+		return models.OrderPage{}, 0, fmt.Errorf("failed to fetch orders: %w", err)
+	}
+	defer rows.Close()
+	duration := time.Since(start)
+
+	orders := make([]models.Order, 0, filter.Limit)
+	for rows.Next() {
+		var order models.Order
+		err := rows.Scan(&order.ID, &order.UserID, &order.Status, &order.Currency,
+			&order.Subtotal, &order.Total, &order.Version, &order.CreatedAt, &order.UpdatedAt)
+		if err != nil {
+			return models.OrderPage{}, 0, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return models.OrderPage{}, 0, fmt.Errorf("failed to fetch orders: %w", err)
+	}
+
+	page := models.OrderPage{Orders: orders}
+	if len(orders) > filter.Limit {
+		page.Orders = orders[:filter.Limit]
+		page.NextCursor = encodeOrderCursor(page.Orders[filter.Limit-1], filter.Sort)
+	}
+
+	if err = loadOrderItems(ctx, o.db, page.Orders); err != nil {
+		return models.OrderPage{}, 0, err
+	}
+
+	return page, duration, nil
 }
+
 func (o *OrderRepository) GetOrderByID(ctx context.Context, userID int, orderID int) (models.Order, error) {
 	start := time.Now()
 
@@ -143,7 +267,8 @@ func (o *OrderRepository) GetOrderByID(ctx context.Context, userID int, orderID
 	duration := time.Since(start)
 
 	var order models.Order
-	err := row.Scan(&order.ID, &order.UserID, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+	err := row.Scan(&order.ID, &order.UserID, &order.Status, &order.Currency,
+		&order.Subtotal, &order.Total, &order.Version, &order.CreatedAt, &order.UpdatedAt)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			o.logger.Error("order not found",
@@ -152,7 +277,7 @@ func (o *OrderRepository) GetOrderByID(ctx context.Context, userID int, orderID
 				zap.Error(err),
 				zap.Duration("total_duration", duration),
 			)
-			return models.Order{}, fmt.Errorf("order not found: %w", err)
+			return models.Order{}, fmt.Errorf("%w: %w", models.ErrOrderNotFound, err)
 		}
 		o.logger.Error("failed to fetch order",
 			zap.Int("user_id", userID),
@@ -163,6 +288,17 @@ func (o *OrderRepository) GetOrderByID(ctx context.Context, userID int, orderID
 		return models.Order{}, fmt.Errorf("failed to fetch order: %w", err)
 	}
 
+	orders := []models.Order{order}
+	if err = loadOrderItems(ctx, o.db, orders); err != nil {
+		o.logger.Error("failed to fetch order items",
+			zap.Int("user_id", userID),
+			zap.Int("order_id", orderID),
+			zap.Error(err),
+		)
+		return models.Order{}, err
+	}
+	order = orders[0]
+
 	o.logger.Info("order fetched successfully",
 		zap.Int("user_id", userID),
 		zap.Int("order_id", orderID),
@@ -180,7 +316,7 @@ func (o *OrderRepository) GetOrderByID(ctx context.Context, userID int, orderID
 
 	return order, nil
 }
-func (o *OrderRepository) DeleteOrder(ctx context.Context, userID int, orderID int) error {
+func (o *OrderRepository) DeleteOrder(ctx context.Context, userID int, orderID int, expectedVersion *int) error {
 	start := time.Now()
 	o.logger.Debug("deleting order",
 		zap.Int("user_id", userID),
@@ -189,7 +325,24 @@ func (o *OrderRepository) DeleteOrder(ctx context.Context, userID int, orderID i
 	)
 	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
-	_, err := o.db.Exec(ctx, queryDeleteOrderByID, userID, orderID)
+	err := withTx(ctx, o.db, func(tx pgx.Tx) error {
+		var status models.OrderStatus
+		var version int
+		err := tx.QueryRow(ctx, querySelectOrderStatusForUpdate, userID, orderID).Scan(&status, &version)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != nil && *expectedVersion != version {
+			return fmt.Errorf("%w: expected %d, current %d", models.ErrOrderVersionMismatch, *expectedVersion, version)
+		}
+
+		// Units still held by the order go back to the available stock.
+		if err = settleStock(ctx, tx, userID, orderID, queryReleaseOrderStock); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, queryDeleteOrderByID, userID, orderID)
+		return err
+	})
 	duration := time.Since(start)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -199,7 +352,15 @@ func (o *OrderRepository) DeleteOrder(ctx context.Context, userID int, orderID i
 				zap.Error(err),
 				zap.Duration("total_duration", time.Since(start)),
 			)
-			return fmt.Errorf("order not found: %w", err)
+			return fmt.Errorf("%w: %w", models.ErrOrderNotFound, err)
+		}
+		if errors.Is(err, models.ErrOrderVersionMismatch) {
+			o.logger.Warn("order version mismatch on delete",
+				zap.Int("user_id", userID),
+				zap.Int("order_id", orderID),
+				zap.Error(err),
+			)
+			return err
 		}
 
 		o.logger.Error("failed to delete order",
@@ -226,38 +387,63 @@ func (o *OrderRepository) DeleteOrder(ctx context.Context, userID int, orderID i
 	}
 	return nil
 }
-func (o *OrderRepository) UpdateOrder(ctx context.Context, userID int, orderID int, input models.OrderUpdateInput) error {
+func (o *OrderRepository) UpdateOrder(ctx context.Context, userID int, orderID int, actorID int, input models.OrderUpdateInput) error {
 	start := time.Now()
 	o.logger.Debug("updating order",
 		zap.Int("user_id", userID),
 		zap.Int("order_id", orderID),
+		zap.Int("actor_id", actorID),
 		zap.String("operation", "update_order"),
 	)
+	if input.Status == nil {
+		return fmt.Errorf("%w: status is required", models.ErrInvalidOrderStatus)
+	}
 	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
 	defer cancel()
-	_, err := o.db.Exec(ctx, queryUpdateOrderByID, input.Status, userID, orderID)
+
+	// The allowed source statuses are part of the WHERE clause so the
+	// transition check and the write happen atomically in one statement;
+	// the history row is written in the same transaction.
+	fromStatuses := statusStrings(input.Status.PreviousStatuses())
+	err := withTx(ctx, o.db, func(tx pgx.Tx) error {
+		var previous models.OrderStatus
+		err := tx.QueryRow(ctx, queryUpdateOrderByID, string(*input.Status), userID, orderID,
+			fromStatuses, input.ExpectedVersion).Scan(&previous)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return o.explainRejectedUpdate(ctx, tx, userID, orderID, input)
+			}
+			return fmt.Errorf("failed to update order: %w", err)
+		}
+
+		switch *input.Status {
+		case models.StatusCancelled:
+			if err = settleStock(ctx, tx, userID, orderID, queryReleaseOrderStock); err != nil {
+				return err
+			}
+		case models.StatusShipped:
+			if err = settleStock(ctx, tx, userID, orderID, queryCommitOrderStock); err != nil {
+				return err
+			}
+		}
+
+		return insertStatusChange(ctx, tx, orderID, &previous, *input.Status, actorID, input.Reason)
+	})
 	duration := time.Since(start)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			o.logger.Error("order not found for update",
-				zap.Int("user_id", userID),
-				zap.Int("order_id", orderID),
-				zap.Error(err),
-				zap.Duration("total_duration", time.Since(start)),
-			)
-			return fmt.Errorf("order not found: %w", err)
-		}
 		o.logger.Error("failed to update order",
 			zap.Int("user_id", userID),
 			zap.Int("order_id", orderID),
 			zap.Error(err),
 			zap.Duration("total_duration", time.Since(start)),
 		)
-		return fmt.Errorf("failed to update order: %w", err)
+		return err
 	}
 	o.logger.Info("order updated successfully",
 		zap.Int("user_id", userID),
 		zap.Int("order_id", orderID),
+		zap.Int("actor_id", actorID),
+		zap.String("status", string(*input.Status)),
 		zap.Duration("total_duration", time.Since(start)),
 	)
 	if duration > SlowQueryThreshold {
@@ -271,3 +457,243 @@ func (o *OrderRepository) UpdateOrder(ctx context.Context, userID int, orderID i
 	}
 	return nil
 }
+
+func (o *OrderRepository) GetOrderHistory(ctx context.Context, userID int, orderID int) ([]models.OrderStatusChange, error) {
+	start := time.Now()
+	o.logger.Debug("fetching order status history",
+		zap.Int("user_id", userID),
+		zap.Int("order_id", orderID),
+		zap.String("operation", "get_order_history"),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+	rows, err := o.db.Query(ctx, querySelectOrderStatusHistory, userID, orderID)
+	if err != nil {
+		o.logger.Error("failed to fetch order history",
+			zap.Int("user_id", userID),
+			zap.Int("order_id", orderID),
+			zap.Error(err),
+			zap.Duration("total_duration", time.Since(start)),
+		)
+		return nil, fmt.Errorf("failed to fetch order history: %w", err)
+	}
+	defer rows.Close()
+
+	history := make([]models.OrderStatusChange, 0)
+	for rows.Next() {
+		var change models.OrderStatusChange
+		err := rows.Scan(&change.ID, &change.OrderID, &change.FromStatus, &change.ToStatus,
+			&change.ActorID, &change.Reason, &change.ChangedAt)
+		if err != nil {
+			o.logger.Error("failed to scan order status change",
+				zap.Int("user_id", userID),
+				zap.Int("order_id", orderID),
+				zap.Error(err),
+			)
+			return nil, fmt.Errorf("failed to scan order status change: %w", err)
+		}
+		history = append(history, change)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch order history: %w", err)
+	}
+
+	// Orders created before history was recorded have no entries, so an
+	// empty result only means "not found" when the order itself is missing.
+	if len(history) == 0 {
+		var status models.OrderStatus
+		var version int
+		err = o.db.QueryRow(ctx, querySelectOrderStatus, userID, orderID).Scan(&status, &version)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %w", models.ErrOrderNotFound, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch order: %w", err)
+		}
+	}
+
+	duration := time.Since(start)
+	o.logger.Info("order history fetched successfully",
+		zap.Int("user_id", userID),
+		zap.Int("order_id", orderID),
+		zap.Int("entries", len(history)),
+		zap.Duration("total_duration", duration),
+	)
+	if duration > SlowQueryThreshold {
+		o.logger.Warn("slow database query detected",
+			zap.String("operation", "get_order_history"),
+			zap.Duration("db_duration", duration),
+			zap.Duration("threshold", SlowQueryThreshold),
+			zap.Int("user_id", userID),
+			zap.Int("order_id", orderID),
+		)
+	}
+
+	return history, nil
+}
+
+// reserveStock locks the products of the order and reserves the requested
+// quantities. When any SKU is short, nothing is reserved and an
+// *models.InsufficientStockError naming every short SKU is returned.
+func reserveStock(ctx context.Context, tx pgx.Tx, items []models.OrderItem) error {
+	skus := make([]string, len(items))
+	for i, item := range items {
+		skus[i] = item.SKU
+	}
+
+	rows, err := tx.Query(ctx, querySelectAvailableStockForUpdate, skus)
+	if err != nil {
+		return fmt.Errorf("failed to lock product stock: %w", err)
+	}
+	available := make(map[string]int, len(items))
+	for rows.Next() {
+		var sku string
+		var units int
+		if err = rows.Scan(&sku, &units); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan product stock: %w", err)
+		}
+		available[sku] = units
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to lock product stock: %w", err)
+	}
+
+	var shortages []models.StockShortage
+	for _, item := range items {
+		if units := available[item.SKU]; units < item.Quantity {
+			shortages = append(shortages, models.StockShortage{
+				SKU:       item.SKU,
+				Requested: item.Quantity,
+				Available: units,
+			})
+		}
+	}
+	if len(shortages) > 0 {
+		return &models.InsufficientStockError{Shortages: shortages}
+	}
+
+	for _, item := range items {
+		if _, err = tx.Exec(ctx, queryReserveStock, item.SKU, item.Quantity); err != nil {
+			return fmt.Errorf("failed to reserve stock for %s: %w", item.SKU, err)
+		}
+	}
+	return nil
+}
+
+// settleStock releases or commits the stock reserved by an order, depending
+// on the given query. Orders without an active reservation are left alone,
+// so each reservation is settled at most once.
+func settleStock(ctx context.Context, tx pgx.Tx, userID int, orderID int, query string) error {
+	var id int
+	err := tx.QueryRow(ctx, queryClearOrderReservation, userID, orderID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to clear order reservation: %w", err)
+	}
+
+	if _, err = tx.Exec(ctx, queryLockOrderProducts, orderID); err != nil {
+		return fmt.Errorf("failed to lock order products: %w", err)
+	}
+	if _, err = tx.Exec(ctx, query, orderID); err != nil {
+		return fmt.Errorf("failed to settle order stock: %w", err)
+	}
+	return nil
+}
+
+// loadOrderItems fetches the items of all given orders with a single query
+// and attaches them in place.
+func loadOrderItems(ctx context.Context, q querier, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(orders))
+	index := make(map[int]int, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].ID
+		index[orders[i].ID] = i
+		orders[i].Items = make([]models.OrderItem, 0)
+	}
+
+	rows, err := q.Query(ctx, querySelectOrderItems, ids)
+	if err != nil {
+		return fmt.Errorf("failed to fetch order items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.OrderItem
+		err = rows.Scan(&item.ID, &item.OrderID, &item.SKU, &item.Quantity,
+			&item.UnitPrice, &item.Currency, &item.LineTotal)
+		if err != nil {
+			return fmt.Errorf("failed to scan order item: %w", err)
+		}
+		i := index[item.OrderID]
+		orders[i].Items = append(orders[i].Items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("failed to fetch order items: %w", err)
+	}
+	return nil
+}
+
+func insertStatusChange(ctx context.Context, q querier, orderID int, from *models.OrderStatus, to models.OrderStatus, actorID int, reason *string) error {
+	var fromStatus *string
+	if from != nil {
+		value := string(*from)
+		fromStatus = &value
+	}
+	if _, err := q.Exec(ctx, queryInsertOrderStatusChange, orderID, fromStatus, string(to), actorID, reason); err != nil {
+		return fmt.Errorf("failed to record order status change: %w", err)
+	}
+	return nil
+}
+
+// explainRejectedUpdate tells apart a missing order, a stale expected
+// version and a current status that does not allow the requested transition.
+func (o *OrderRepository) explainRejectedUpdate(ctx context.Context, q querier, userID int, orderID int, input models.OrderUpdateInput) error {
+	var current models.OrderStatus
+	var version int
+	err := q.QueryRow(ctx, querySelectOrderStatus, userID, orderID).Scan(&current, &version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			o.logger.Error("order not found for update",
+				zap.Int("user_id", userID),
+				zap.Int("order_id", orderID),
+			)
+			return fmt.Errorf("%w: %w", models.ErrOrderNotFound, err)
+		}
+		return fmt.Errorf("failed to read order status: %w", err)
+	}
+
+	if input.ExpectedVersion != nil && *input.ExpectedVersion != version {
+		o.logger.Warn("order version mismatch",
+			zap.Int("user_id", userID),
+			zap.Int("order_id", orderID),
+			zap.Int("expected_version", *input.ExpectedVersion),
+			zap.Int("current_version", version),
+		)
+		return fmt.Errorf("%w: expected %d, current %d", models.ErrOrderVersionMismatch, *input.ExpectedVersion, version)
+	}
+
+	o.logger.Warn("order status transition rejected",
+		zap.Int("user_id", userID),
+		zap.Int("order_id", orderID),
+		zap.String("from", string(current)),
+		zap.String("to", string(*input.Status)),
+	)
+	return fmt.Errorf("%w: %s -> %s", models.ErrInvalidStatusTransition, current, *input.Status)
+}
+
+func statusStrings(statuses []models.OrderStatus) []string {
+	result := make([]string, len(statuses))
+	for i, status := range statuses {
+		result[i] = string(status)
+	}
+	return result
+}
@@ -2,7 +2,10 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -16,23 +19,214 @@ const (
 		VALUES ($1, $2, $3)
 		RETURNING id
 	`
-	querySelectUser = `
-		SELECT id, username, email, password FROM users
-		WHERE username = $1
+	querySelectUserByUsername = `
+		SELECT id, username, email, role, email_verified, mfa_enabled FROM users
+		WHERE LOWER(username) = LOWER($1)
+	`
+	querySelectUserByID = `
+		SELECT id, username, email, role, email_verified, mfa_enabled FROM users
+		WHERE id = $1
+	`
+	querySelectUserPasswordHash = `
+		SELECT password FROM users
+		WHERE id = $1
+	`
+	// queryReplacePasswordHash only matches while the old hash is current,
+	// so a rehash cannot undo a concurrent password change.
+	queryReplacePasswordHash = `
+		UPDATE users
+		SET password = $3
+		WHERE id = $1 AND password = $2
+	`
+	querySelectUserByEmail = `
+		SELECT id, username, email, role, email_verified, mfa_enabled FROM users
+		WHERE LOWER(email) = LOWER($1)
+	`
+	// queryUpdateUserProfile keeps the verification of an email that only
+	// changes case; any other new email has to be verified again.
+	queryUpdateUserProfile = `
+		UPDATE users
+		SET username = COALESCE($2, username),
+			email = COALESCE($3, email),
+			email_verified = email_verified AND LOWER(COALESCE($3, email)) = LOWER(email),
+			email_verified_at = CASE
+				WHEN LOWER(COALESCE($3, email)) = LOWER(email) THEN email_verified_at
+			END
+		WHERE id = $1
+		RETURNING id, username, email, role, email_verified, mfa_enabled
+	`
+	queryDeleteUser = `
+		DELETE FROM users
+		WHERE id = $1
+		RETURNING id, username, email, role, email_verified, mfa_enabled
+	`
+	queryUpdateUserPassword = `
+		UPDATE users
+		SET password = $2
+		WHERE id = $1
+		RETURNING id, username, email, role, email_verified, mfa_enabled
+	`
+)
+
+const (
+	queryInsertEmailVerificationToken = `
+		INSERT INTO email_verification_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	// queryLockUserForVerification serialises verification resends of one
+	// user, so two requests cannot both pass the limits.
+	queryLockUserForVerification = `
+		SELECT id FROM users
+		WHERE id = $1
+		FOR UPDATE
+	`
+	// querySelectEmailVerificationStats uses the database clock for the
+	// window and returns it, so the limits never mix in the caller's clock.
+	querySelectEmailVerificationStats = `
+		SELECT COUNT(*), NOW(), COALESCE(MIN(created_at), 'epoch'::timestamptz), COALESCE(MAX(created_at), 'epoch'::timestamptz)
+		FROM email_verification_tokens
+		WHERE user_id = $1 AND created_at >= NOW() - INTERVAL '1 hour'
+	`
+	queryUseEmailVerificationToken = `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+	queryExpireUserEmailVerificationTokens = `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+	queryMarkEmailVerified = `
+		UPDATE users
+		SET email_verified = TRUE, email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1
+		RETURNING id, username, email, role, email_verified, mfa_enabled
+	`
+)
+
+const (
+	// querySetMFASecret starts (or restarts) enrolment; it matches nothing
+	// while MFA is enabled.
+	querySetMFASecret = `
+		UPDATE users
+		SET mfa_secret = $2, mfa_last_step = NULL
+		WHERE id = $1 AND NOT mfa_enabled
+	`
+	querySelectUserMFA = `
+		SELECT mfa_secret, mfa_enabled, mfa_last_step FROM users
+		WHERE id = $1
+	`
+	queryEnableMFA = `
+		UPDATE users
+		SET mfa_enabled = TRUE, mfa_enabled_at = NOW(), mfa_last_step = $2
+		WHERE id = $1 AND NOT mfa_enabled AND mfa_secret IS NOT NULL
+		  AND (mfa_last_step IS NULL OR mfa_last_step < $2)
+		RETURNING id, username, email, role, email_verified, mfa_enabled
+	`
+	queryDisableMFA = `
+		UPDATE users
+		SET mfa_enabled = FALSE, mfa_enabled_at = NULL, mfa_secret = NULL, mfa_last_step = NULL
+		WHERE id = $1
+		RETURNING id, username, email, role, email_verified, mfa_enabled
+	`
+	// queryUseMFAStep records an accepted TOTP step; it matches nothing when
+	// the step, or a later one, was already used.
+	queryUseMFAStep = `
+		UPDATE users
+		SET mfa_last_step = $2
+		WHERE id = $1 AND mfa_enabled AND (mfa_last_step IS NULL OR mfa_last_step < $2)
+	`
+	queryDeleteMFARecoveryCodes = `
+		DELETE FROM mfa_recovery_codes
+		WHERE user_id = $1
+	`
+	queryInsertMFARecoveryCode = `
+		INSERT INTO mfa_recovery_codes (user_id, code_hash)
+		VALUES ($1, $2)
+	`
+	queryUseMFARecoveryCode = `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+)
+
+const (
+	querySelectLoginAttempts = `
+		SELECT failures, last_failure_at FROM login_attempts
+		WHERE key = $1 AND expires_at > $2
+	`
+	// queryRecordLoginFailure starts counting afresh once the previous
+	// counter has expired.
+	queryRecordLoginFailure = `
+		INSERT INTO login_attempts (key, failures, last_failure_at, expires_at)
+		VALUES ($1, 1, $2, $3)
+		ON CONFLICT (key) DO UPDATE
+		SET failures        = CASE WHEN login_attempts.expires_at <= $2 THEN 1 ELSE login_attempts.failures + 1 END,
+		    last_failure_at = $2,
+		    expires_at      = $3
+		RETURNING failures, last_failure_at
+	`
+	queryReleaseLoginAttempt = `
+		UPDATE login_attempts
+		SET failures = failures - 1
+		WHERE key = $1 AND failures > 0
+	`
+	queryDeleteLoginAttempts = `
+		DELETE FROM login_attempts
+		WHERE key = ANY($1)
+	`
+	queryDeleteExpiredLoginAttempts = `
+		DELETE FROM login_attempts
+		WHERE expires_at <= $1
+	`
+)
+
+const (
+	queryInsertPasswordResetToken = `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	// queryUsePasswordResetToken consumes a token; it matches nothing once
+	// the token has been used or has expired.
+	queryUsePasswordResetToken = `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+	queryExpireUserPasswordResetTokens = `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
 	`
 )
 const (
 	queryInsertOrder = `
-		INSERT INTO orders (user_id, status)
-	    VALUES ($1, $2)
+		INSERT INTO orders (user_id, status, currency, subtotal, total, stock_reserved)
+	    VALUES ($1, $2, $3, $4, $5, TRUE)
+	    RETURNING id, created_at, updated_at
 	`
+	// querySelectOrders and querySelectOrdersByUser are extended with filters, ordering and a limit
+	// by buildOrdersQuery.
 	querySelectOrdersByUser = `
-	SELECT id, user_id, status,  created_at, updated_at
+	SELECT id, user_id, status, COALESCE(currency, ''), subtotal, total, version, created_at, updated_at
 	FROM orders
-	WHERE user_id = $1
+	WHERE user_id = $1`
+	querySelectOrders = `
+	SELECT id, user_id, status, COALESCE(currency, ''), subtotal, total, version, created_at, updated_at
+	FROM orders
+	WHERE TRUE`
+	querySelectOrderOwner = `
+		SELECT user_id FROM orders
+		WHERE id = $1
 	`
 	querySelectOrderByID = `
-		SELECT id, user_id, status, created_at, updated_at
+		SELECT id, user_id, status, COALESCE(currency, ''), subtotal, total, version, created_at, updated_at
 		FROM orders
 		WHERE user_id = $1 AND id = $2
 	   `
@@ -41,12 +235,340 @@ const (
 		WHERE user_id = $1 AND id = $2
 	`
 	queryUpdateOrderByID = `
+		WITH previous AS (
+			SELECT id, status, version
+			FROM orders
+			WHERE user_id = $2 AND id = $3
+			FOR UPDATE
+		)
 		UPDATE orders
-		SET status = $1, updated_at = NOW()
-		WHERE user_id = $2 AND id = $3
+		SET status = $1, updated_at = NOW(), version = orders.version + 1
+		FROM previous
+		WHERE orders.id = previous.id
+		  AND previous.status::text = ANY($4::text[])
+		  AND ($5::int IS NULL OR previous.version = $5)
+		RETURNING previous.status
 		`
+	querySelectOrderStatus = `
+		SELECT status, version
+		FROM orders
+		WHERE user_id = $1 AND id = $2
+	`
+	querySelectOrderStatusForUpdate = `
+		SELECT status, version
+		FROM orders
+		WHERE user_id = $1 AND id = $2
+		FOR UPDATE
+	`
 )
 
+const (
+	queryInsertOrderItem = `
+		INSERT INTO order_items (order_id, sku, quantity, unit_price, currency, line_total)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+	querySelectOrderItems = `
+		SELECT id, order_id, sku, quantity, unit_price, currency, line_total
+		FROM order_items
+		WHERE order_id = ANY($1)
+		ORDER BY order_id, id
+	`
+)
+
+const (
+	querySelectAvailableStockForUpdate = `
+		SELECT sku, stock_on_hand - stock_reserved
+		FROM products
+		WHERE sku = ANY($1) AND active
+		ORDER BY sku
+		FOR UPDATE
+	`
+	queryReserveStock = `
+		UPDATE products
+		SET stock_reserved = stock_reserved + $2
+		WHERE sku = $1
+	`
+	queryClearOrderReservation = `
+		UPDATE orders
+		SET stock_reserved = FALSE
+		WHERE user_id = $1 AND id = $2 AND stock_reserved
+		RETURNING id
+	`
+	queryLockOrderProducts = `
+		SELECT p.id
+		FROM products p
+		JOIN order_items i ON i.sku = p.sku
+		WHERE i.order_id = $1
+		ORDER BY p.sku
+		FOR UPDATE OF p
+	`
+	queryReleaseOrderStock = `
+		UPDATE products p
+		SET stock_reserved = p.stock_reserved - i.quantity
+		FROM order_items i
+		WHERE i.order_id = $1 AND p.sku = i.sku
+	`
+	queryCommitOrderStock = `
+		UPDATE products p
+		SET stock_on_hand  = p.stock_on_hand - i.quantity,
+		    stock_reserved = p.stock_reserved - i.quantity
+		FROM order_items i
+		WHERE i.order_id = $1 AND p.sku = i.sku
+	`
+	querySelectProductStock = `
+		SELECT id, sku, stock_on_hand, stock_reserved
+		FROM products
+		WHERE id = $1
+	`
+	querySetProductStock = `
+		UPDATE products
+		SET stock_on_hand = $2
+		WHERE id = $1 AND $2 >= stock_reserved
+		RETURNING id, sku, stock_on_hand, stock_reserved
+	`
+)
+
+const (
+	queryInsertOrderStatusChange = `
+		INSERT INTO order_status_history (order_id, from_status, to_status, actor_id, reason)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	querySelectOrderStatusHistory = `
+		SELECT h.id, h.order_id, h.from_status, h.to_status, h.actor_id, h.reason, h.changed_at
+		FROM order_status_history h
+		JOIN orders o ON o.id = h.order_id
+		WHERE o.user_id = $1 AND h.order_id = $2
+		ORDER BY h.changed_at, h.id
+	`
+)
+
+const (
+	queryInsertProduct = `
+		INSERT INTO products (sku, name, description, price, currency)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, active, created_at, updated_at
+	`
+	querySelectProducts = `
+		SELECT id, sku, name, description, price, currency, active, archived_at, created_at, updated_at
+		FROM products
+		WHERE active OR $1
+		ORDER BY id
+	`
+	querySelectProductByID = `
+		SELECT id, sku, name, description, price, currency, active, archived_at, created_at, updated_at
+		FROM products
+		WHERE id = $1
+	`
+	querySelectProductsBySKUs = `
+		SELECT id, sku, name, description, price, currency, active, archived_at, created_at, updated_at
+		FROM products
+		WHERE sku = ANY($1)
+	`
+	queryUpdateProduct = `
+		UPDATE products
+		SET name        = COALESCE($2, name),
+		    description = COALESCE($3, description),
+		    price       = COALESCE($4, price),
+		    currency    = COALESCE($5, currency),
+		    updated_at  = NOW()
+		WHERE id = $1
+		RETURNING id, sku, name, description, price, currency, active, archived_at, created_at, updated_at
+	`
+	queryArchiveProduct = `
+		UPDATE products
+		SET active = FALSE, archived_at = COALESCE(archived_at, NOW()), updated_at = NOW()
+		WHERE id = $1
+	`
+)
+
+const (
+	queryDeleteExpiredIdempotencyKey = `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2 AND created_at < $3
+	`
+	// queryInsertIdempotencyKey also takes over a key whose request never
+	// finished once its lease has expired; the row lock taken by the upsert
+	// lets only one retry win.
+	queryInsertIdempotencyKey = `
+		INSERT INTO idempotency_keys (user_id, key, fingerprint, locked_until)
+		VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+		ON CONFLICT (user_id, key) DO UPDATE
+		SET fingerprint  = EXCLUDED.fingerprint,
+		    created_at   = NOW(),
+		    locked_until = EXCLUDED.locked_until
+		WHERE idempotency_keys.status_code IS NULL AND idempotency_keys.locked_until <= NOW()
+		RETURNING created_at
+	`
+	querySelectIdempotencyKey = `
+		SELECT fingerprint, status_code IS NOT NULL, COALESCE(status_code, 0), COALESCE(response_body, ''::bytea), created_at
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`
+	queryCompleteIdempotencyKey = `
+		UPDATE idempotency_keys
+		SET status_code = $3, response_body = $4, completed_at = NOW()
+		WHERE user_id = $1 AND key = $2
+	`
+	queryDeleteIdempotencyKey = `
+		DELETE FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+	`
+)
+
+const (
+	queryInsertRefreshToken = `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	querySelectRefreshToken = `
+		SELECT id, user_id, family_id, token_hash, expires_at, rotated_at, revoked_at, created_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+	// queryRotateRefreshToken only succeeds for a token that is still live,
+	// so two concurrent refreshes with the same token cannot both win.
+	queryRotateRefreshToken = `
+		UPDATE refresh_tokens
+		SET rotated_at = NOW()
+		WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+	`
+	queryRevokeRefreshTokenFamily = `
+		UPDATE refresh_tokens
+		SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE family_id = $1
+	`
+	queryDeleteExpiredRevokedAccessTokens = `
+		DELETE FROM revoked_access_tokens
+		WHERE expires_at < NOW()
+	`
+	queryInsertRevokedAccessToken = `
+		INSERT INTO revoked_access_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	// querySelectRevokedAccessToken compares at whole seconds, the
+	// precision of the token's iat claim. Tokens of deleted users are
+	// revoked too.
+	querySelectRevokedAccessToken = `
+		SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)
+			OR EXISTS (SELECT 1 FROM users WHERE id = $2 AND date_trunc('second', sessions_revoked_at) > $3)
+			OR NOT EXISTS (SELECT 1 FROM users WHERE id = $2)
+	`
+	querySelectAccessTokenDenylisted = `
+		SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti = $1)
+	`
+	querySelectSessionsRevokedAt = `
+		SELECT sessions_revoked_at FROM users WHERE id = $1
+	`
+	queryRevokeUserSessions = `
+		UPDATE users
+		SET sessions_revoked_at = NOW()
+		WHERE id = $1
+		RETURNING sessions_revoked_at
+	`
+	queryRevokeUserRefreshTokens = `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+)
+
+const (
+	queryInsertAPIKey = `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	querySelectAPIKeys = `
+		SELECT k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, u.role,
+		       k.expires_at, k.last_used_at, k.revoked_at, k.created_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.user_id = $1
+		ORDER BY k.created_at DESC, k.id DESC
+	`
+	querySelectAPIKeyByHash = `
+		SELECT k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, u.role,
+		       k.expires_at, k.last_used_at, k.revoked_at, k.created_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1
+	`
+	queryRevokeAPIKey = `
+		UPDATE api_keys k
+		SET revoked_at = COALESCE(k.revoked_at, NOW())
+		FROM users u
+		WHERE k.id = $2 AND k.user_id = $1 AND u.id = k.user_id
+		RETURNING k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, u.role,
+		          k.expires_at, k.last_used_at, k.revoked_at, k.created_at
+	`
+	queryTouchAPIKey = `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE id = $1
+		RETURNING last_used_at, revoked_at
+	`
+	querySelectAPIKeyState = `
+		SELECT u.role, k.revoked_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.id = $1
+	`
+)
+
+const uniqueViolationCode = "23505"
+
+// Unique indexes on users, see the add_users_unique_username_email migration.
+const (
+	usersUsernameKey = "users_username_lower_key"
+	usersEmailKey    = "users_email_lower_key"
+)
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation.
+func isUniqueViolation(err error) bool {
+	_, ok := uniqueViolationConstraint(err)
+	return ok
+}
+
+// uniqueViolationConstraint returns the name of the constraint or unique
+// index that err violated, if err is a PostgreSQL unique_violation.
+func uniqueViolationConstraint(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolationCode {
+		return "", false
+	}
+	return pgErr.ConstraintName, true
+}
+
+// querier is the subset of pgx shared by *pgxpool.Pool and pgx.Tx, so that
+// helpers can run either standalone or inside a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// withTx runs fn inside a transaction, committing when fn succeeds and
+// rolling back otherwise.
+func withTx(ctx context.Context, db *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 type Config struct {
 	Host     string
 	Port     string
@@ -6,36 +6,161 @@ import (
 	"context"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
+	"sync/atomic"
+	"time"
 )
 
 type Authorization interface {
 	CreateUser(ctx context.Context, user models.User) (int, error)
-	GetUser(ctx context.Context, username, password string) (models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (models.User, error)
+	GetPasswordHash(ctx context.Context, userID int) (string, error)
+	UpdatePasswordHash(ctx context.Context, userID int, oldHash, newHash string) (bool, error)
+	GetUserByID(ctx context.Context, userID int) (models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (models.User, error)
+	UpdateUserProfile(ctx context.Context, userID int, input models.UserProfileUpdate) (models.User, error)
+	DeleteUser(ctx context.Context, userID int) (models.User, error)
+	CreatePasswordResetToken(ctx context.Context, token *models.PasswordResetToken) error
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (models.User, time.Time, error)
+	CreateEmailVerificationToken(ctx context.Context, token *models.EmailVerificationToken) error
+	ResendEmailVerificationToken(ctx context.Context, token *models.EmailVerificationToken) error
+	VerifyEmail(ctx context.Context, tokenHash string) (models.User, error)
+	SetMFASecret(ctx context.Context, userID int, secret string) error
+	GetUserMFA(ctx context.Context, userID int) (models.UserMFA, error)
+	EnableMFA(ctx context.Context, userID int, step int64, recoveryCodeHashes []string) (models.User, error)
+	DisableMFA(ctx context.Context, userID int) (models.User, error)
+	UseMFAStep(ctx context.Context, userID int, step int64) error
+	UseMFARecoveryCode(ctx context.Context, userID int, codeHash string) error
 }
 
 type Order interface {
 	CreateOrder(ctx context.Context, userID int, order *models.Order) error
-	GetOrders(ctx context.Context, userID int) ([]models.Order, error)
+	GetOrders(ctx context.Context, userID int, filter models.OrderFilter) (models.OrderPage, error)
+	SearchOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error)
+	GetOrderOwner(ctx context.Context, orderID int) (int, error)
 	GetOrderByID(ctx context.Context, userID int, orderID int) (models.Order, error)
-	DeleteOrder(ctx context.Context, userID int, orderID int) error
-	UpdateOrder(ctx context.Context, userID int, orderID int, input models.OrderUpdateInput) error
+	DeleteOrder(ctx context.Context, userID int, orderID int, expectedVersion *int) error
+	UpdateOrder(ctx context.Context, userID int, orderID int, actorID int, input models.OrderUpdateInput) error
+	GetOrderHistory(ctx context.Context, userID int, orderID int) ([]models.OrderStatusChange, error)
+}
+
+type Product interface {
+	CreateProduct(ctx context.Context, product *models.Product) error
+	GetProducts(ctx context.Context, includeArchived bool) ([]models.Product, error)
+	GetProductByID(ctx context.Context, productID int) (models.Product, error)
+	GetProductsBySKUs(ctx context.Context, skus []string) ([]models.Product, error)
+	UpdateProduct(ctx context.Context, productID int, input models.ProductUpdateInput) (models.Product, error)
+	ArchiveProduct(ctx context.Context, productID int) error
+	GetStock(ctx context.Context, productID int) (models.ProductStock, error)
+	SetStock(ctx context.Context, productID int, onHand int) (models.ProductStock, error)
+}
+
+type Idempotency interface {
+	ReserveKey(ctx context.Context, record models.IdempotencyRecord) (models.IdempotencyRecord, bool, error)
+	CompleteKey(ctx context.Context, record models.IdempotencyRecord) error
+	ReleaseKey(ctx context.Context, userID int, key string) error
+}
+
+type Token interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, tokenID int, next *models.RefreshToken) error
+	RevokeTokenFamily(ctx context.Context, familyID string) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	RevokeUserSessions(ctx context.Context, userID int) (time.Time, error)
+	IsAccessTokenRevoked(ctx context.Context, claims models.AccessTokenClaims) (bool, error)
+}
+
+type APIKey interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID int) (models.APIKey, error)
+	TouchAPIKey(ctx context.Context, key *models.APIKey) error
+}
+
+type LoginAttempt interface {
+	GetLoginAttempts(ctx context.Context, key string) (models.LoginAttempts, error)
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (models.LoginAttempts, error)
+	ReleaseLoginAttempt(ctx context.Context, key string) error
+	ResetLoginAttempts(ctx context.Context, keys ...string) error
 }
 
 type Repository struct {
 	Authorization
 	Order
+	Product
+	Idempotency
+	Token
+	LoginAttempt
+	APIKey
 }
 
 func NewRepository(db *pgxpool.Pool, logger *zap.Logger) *Repository {
 	return &Repository{
 		Authorization: NewAuthorizationRepository(db, logger),
 		Order:         NewOrderRepository(db, logger),
+		Product:       NewProductRepository(db, logger),
+		Idempotency:   NewIdempotencyRepository(db, logger),
+		Token:         NewTokenRepository(db, logger),
+		LoginAttempt:  NewLoginAttemptRepository(db, logger),
+		APIKey:        NewAPIKeyRepository(db, logger),
 	}
 }
 
-func NewCachedRepository(db *pgxpool.Pool, cache *cache.RedisCache, logger *zap.Logger) *Repository {
+// CacheTTLs sets how long reads stay cached in Redis. Writes invalidate the
+// affected entries, so the TTLs only bound how stale a missed invalidation
+// can get.
+type CacheTTLs struct {
+	User        time.Duration
+	Order       time.Duration
+	OrderList   time.Duration
+	Product     time.Duration
+	ProductList time.Duration
+	// APIKey also bounds how long a change of the owner's role takes to
+	// reach a key. Revocations drop the cached key right away.
+	APIKey time.Duration
+}
+
+func DefaultCacheTTLs() CacheTTLs {
+	return CacheTTLs{
+		User:        time.Hour,
+		Order:       30 * time.Minute,
+		OrderList:   15 * time.Minute,
+		Product:     30 * time.Minute,
+		ProductList: 15 * time.Minute,
+		APIKey:      5 * time.Minute,
+	}
+}
+
+// LiveCacheTTLs holds the CacheTTLs that the cached repositories read each
+// time they cache a value, so the TTLs can be changed at runtime.
+type LiveCacheTTLs struct {
+	ttls atomic.Pointer[CacheTTLs]
+}
+
+func NewLiveCacheTTLs(ttls CacheTTLs) *LiveCacheTTLs {
+	l := &LiveCacheTTLs{}
+	l.Set(ttls)
+	return l
+}
+
+func (l *LiveCacheTTLs) Get() CacheTTLs {
+	return *l.ttls.Load()
+}
+
+// Set applies to values cached from now on; cached entries keep their TTL.
+func (l *LiveCacheTTLs) Set(ttls CacheTTLs) {
+	l.ttls.Store(&ttls)
+}
+
+func NewCachedRepository(db *pgxpool.Pool, cache *cache.RedisCache, ttls *LiveCacheTTLs, logger *zap.Logger) *Repository {
 	return &Repository{
-		Authorization: NewCachedAuthRepository(db, cache, logger),
-		Order:         NewCachedOrderRepository(db, cache, logger),
+		Authorization: NewCachedAuthRepository(db, cache, ttls, logger),
+		Order:         NewCachedOrderRepository(db, cache, ttls, logger),
+		Product:       NewCachedProductRepository(db, cache, ttls, logger),
+		Idempotency:   NewCachedIdempotencyRepository(db, cache, logger),
+		Token:         NewCachedTokenRepository(db, cache, logger),
+		LoginAttempt:  NewCachedLoginAttemptRepository(db, cache, logger),
+		APIKey:        NewCachedAPIKeyRepository(db, cache, ttls, logger),
 	}
 }
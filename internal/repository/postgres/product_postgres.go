@@ -0,0 +1,342 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"time"
+)
+
+type ProductRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewProductRepository(db *pgxpool.Pool, logger *zap.Logger) *ProductRepository {
+	return &ProductRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (p *ProductRepository) CreateProduct(ctx context.Context, product *models.Product) error {
+	start := time.Now()
+	p.logger.Debug("database insert operation started",
+		zap.String("sku", product.SKU),
+		zap.String("operation", "insert_product"),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	err := p.db.QueryRow(ctx, queryInsertProduct, product.SKU, product.Name, product.Description,
+		product.Price, product.Currency).Scan(&product.ID, &product.Active, &product.CreatedAt, &product.UpdatedAt)
+	duration := time.Since(start)
+	if err != nil {
+		if isUniqueViolation(err) {
+			p.logger.Warn("product sku already exists",
+				zap.String("sku", product.SKU),
+				zap.Duration("db_duration", duration),
+			)
+			return fmt.Errorf("%w: %s", models.ErrProductSKUExists, product.SKU)
+		}
+		p.logger.Error("database insert failed",
+			zap.String("sku", product.SKU),
+			zap.String("operation", "insert_product"),
+			zap.String("query", "INSERT INTO products"),
+			zap.Error(err),
+			zap.Duration("db_duration", duration),
+		)
+		return fmt.Errorf("could not create product: %w", err)
+	}
+
+	p.logger.Info("product inserted successfully",
+		zap.Int("product_id", product.ID),
+		zap.String("sku", product.SKU),
+		zap.Duration("db_duration", duration),
+	)
+	p.warnIfSlow("insert_product", duration)
+
+	return nil
+}
+
+func (p *ProductRepository) GetProducts(ctx context.Context, includeArchived bool) ([]models.Product, error) {
+	start := time.Now()
+	p.logger.Debug("fetching products",
+		zap.Bool("include_archived", includeArchived),
+		zap.String("operation", "get_products"),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	products, err := queryProducts(ctx, p.db, querySelectProducts, includeArchived)
+	duration := time.Since(start)
+	if err != nil {
+		p.logger.Error("failed to fetch products",
+			zap.Error(err),
+			zap.Duration("db_duration", duration),
+		)
+		return nil, err
+	}
+
+	p.logger.Info("products fetched successfully",
+		zap.Int("product_count", len(products)),
+		zap.Duration("db_duration", duration),
+	)
+	p.warnIfSlow("get_products", duration)
+
+	return products, nil
+}
+
+func (p *ProductRepository) GetProductByID(ctx context.Context, productID int) (models.Product, error) {
+	start := time.Now()
+	p.logger.Debug("fetching product by ID",
+		zap.Int("product_id", productID),
+		zap.String("operation", "get_product_by_id"),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	product, err := scanProduct(p.db.QueryRow(ctx, querySelectProductByID, productID))
+	duration := time.Since(start)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			p.logger.Warn("product not found",
+				zap.Int("product_id", productID),
+				zap.Duration("db_duration", duration),
+			)
+			return models.Product{}, fmt.Errorf("%w: %w", models.ErrProductNotFound, err)
+		}
+		p.logger.Error("failed to fetch product",
+			zap.Int("product_id", productID),
+			zap.Error(err),
+			zap.Duration("db_duration", duration),
+		)
+		return models.Product{}, fmt.Errorf("failed to fetch product: %w", err)
+	}
+
+	p.logger.Info("product fetched successfully",
+		zap.Int("product_id", productID),
+		zap.Duration("db_duration", duration),
+	)
+	p.warnIfSlow("get_product_by_id", duration)
+
+	return product, nil
+}
+
+func (p *ProductRepository) GetProductsBySKUs(ctx context.Context, skus []string) ([]models.Product, error) {
+	start := time.Now()
+	p.logger.Debug("fetching products by sku",
+		zap.Strings("skus", skus),
+		zap.String("operation", "get_products_by_skus"),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	products, err := queryProducts(ctx, p.db, querySelectProductsBySKUs, skus)
+	duration := time.Since(start)
+	if err != nil {
+		p.logger.Error("failed to fetch products by sku",
+			zap.Strings("skus", skus),
+			zap.Error(err),
+			zap.Duration("db_duration", duration),
+		)
+		return nil, err
+	}
+
+	p.logger.Info("products by sku fetched successfully",
+		zap.Int("requested", len(skus)),
+		zap.Int("found", len(products)),
+		zap.Duration("db_duration", duration),
+	)
+	p.warnIfSlow("get_products_by_skus", duration)
+
+	return products, nil
+}
+
+func (p *ProductRepository) UpdateProduct(ctx context.Context, productID int, input models.ProductUpdateInput) (models.Product, error) {
+	start := time.Now()
+	p.logger.Debug("updating product",
+		zap.Int("product_id", productID),
+		zap.String("operation", "update_product"),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	product, err := scanProduct(p.db.QueryRow(ctx, queryUpdateProduct, productID,
+		input.Name, input.Description, input.Price, input.Currency))
+	duration := time.Since(start)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			p.logger.Warn("product not found for update",
+				zap.Int("product_id", productID),
+				zap.Duration("db_duration", duration),
+			)
+			return models.Product{}, fmt.Errorf("%w: %w", models.ErrProductNotFound, err)
+		}
+		p.logger.Error("failed to update product",
+			zap.Int("product_id", productID),
+			zap.Error(err),
+			zap.Duration("db_duration", duration),
+		)
+		return models.Product{}, fmt.Errorf("failed to update product: %w", err)
+	}
+
+	p.logger.Info("product updated successfully",
+		zap.Int("product_id", productID),
+		zap.Duration("db_duration", duration),
+	)
+	p.warnIfSlow("update_product", duration)
+
+	return product, nil
+}
+
+func (p *ProductRepository) ArchiveProduct(ctx context.Context, productID int) error {
+	start := time.Now()
+	p.logger.Debug("archiving product",
+		zap.Int("product_id", productID),
+		zap.String("operation", "archive_product"),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	tag, err := p.db.Exec(ctx, queryArchiveProduct, productID)
+	duration := time.Since(start)
+	if err != nil {
+		p.logger.Error("failed to archive product",
+			zap.Int("product_id", productID),
+			zap.Error(err),
+			zap.Duration("db_duration", duration),
+		)
+		return fmt.Errorf("failed to archive product: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		p.logger.Warn("product not found for archiving",
+			zap.Int("product_id", productID),
+		)
+		return models.ErrProductNotFound
+	}
+
+	p.logger.Info("product archived successfully",
+		zap.Int("product_id", productID),
+		zap.Duration("db_duration", duration),
+	)
+	p.warnIfSlow("archive_product", duration)
+
+	return nil
+}
+
+func (p *ProductRepository) GetStock(ctx context.Context, productID int) (models.ProductStock, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	stock, err := scanProductStock(p.db.QueryRow(ctx, querySelectProductStock, productID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.ProductStock{}, fmt.Errorf("%w: %w", models.ErrProductNotFound, err)
+		}
+		p.logger.Error("failed to fetch product stock",
+			zap.Int("product_id", productID),
+			zap.Error(err),
+		)
+		return models.ProductStock{}, fmt.Errorf("failed to fetch product stock: %w", err)
+	}
+	return stock, nil
+}
+
+func (p *ProductRepository) SetStock(ctx context.Context, productID int, onHand int) (models.ProductStock, error) {
+	start := time.Now()
+	p.logger.Debug("setting product stock",
+		zap.Int("product_id", productID),
+		zap.Int("on_hand", onHand),
+		zap.String("operation", "set_product_stock"),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	stock, err := scanProductStock(p.db.QueryRow(ctx, querySetProductStock, productID, onHand))
+	duration := time.Since(start)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Either the product is missing or the new level is below the
+			// units already reserved by open orders.
+			current, getErr := p.GetStock(ctx, productID)
+			if getErr != nil {
+				return models.ProductStock{}, getErr
+			}
+			return models.ProductStock{}, fmt.Errorf("%w: %d units are reserved by open orders",
+				models.ErrInvalidStock, current.Reserved)
+		}
+		p.logger.Error("failed to set product stock",
+			zap.Int("product_id", productID),
+			zap.Error(err),
+			zap.Duration("db_duration", duration),
+		)
+		return models.ProductStock{}, fmt.Errorf("failed to set product stock: %w", err)
+	}
+
+	p.logger.Info("product stock updated successfully",
+		zap.Int("product_id", productID),
+		zap.Int("on_hand", stock.OnHand),
+		zap.Int("reserved", stock.Reserved),
+		zap.Duration("db_duration", duration),
+	)
+	p.warnIfSlow("set_product_stock", duration)
+
+	return stock, nil
+}
+
+func (p *ProductRepository) warnIfSlow(operation string, duration time.Duration) {
+	if duration > SlowQueryThreshold {
+		p.logger.Warn("slow database query detected",
+			zap.String("operation", operation),
+			zap.Duration("db_duration", duration),
+			zap.Duration("threshold", SlowQueryThreshold),
+		)
+	}
+}
+
+func queryProducts(ctx context.Context, q querier, query string, args ...any) ([]models.Product, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+	defer rows.Close()
+
+	products := make([]models.Product, 0)
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		products = append(products, product)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+	return products, nil
+}
+
+func scanProduct(row pgx.Row) (models.Product, error) {
+	var product models.Product
+	err := row.Scan(&product.ID, &product.SKU, &product.Name, &product.Description, &product.Price,
+		&product.Currency, &product.Active, &product.ArchivedAt, &product.CreatedAt, &product.UpdatedAt)
+	return product, err
+}
+
+func scanProductStock(row pgx.Row) (models.ProductStock, error) {
+	var stock models.ProductStock
+	err := row.Scan(&stock.ProductID, &stock.SKU, &stock.OnHand, &stock.Reserved)
+	stock.Available = stock.OnHand - stock.Reserved
+	return stock, err
+}
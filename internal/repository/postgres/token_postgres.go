@@ -0,0 +1,238 @@
+package postgres
+
+import (
+	"OrderKeeper/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"time"
+)
+
+type TokenRepository struct {
+	db     *pgxpool.Pool
+	logger *zap.Logger
+}
+
+func NewTokenRepository(db *pgxpool.Pool, logger *zap.Logger) *TokenRepository {
+	return &TokenRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (t *TokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	if err := insertRefreshToken(ctx, t.db, token); err != nil {
+		t.logger.Error("failed to insert refresh token",
+			zap.Int("user_id", token.UserID),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	t.logger.Debug("refresh token created",
+		zap.Int("user_id", token.UserID),
+		zap.Int("token_id", token.ID),
+	)
+	return nil
+}
+
+func (t *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (models.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var token models.RefreshToken
+	err := t.db.QueryRow(ctx, querySelectRefreshToken, tokenHash).Scan(&token.ID, &token.UserID, &token.FamilyID,
+		&token.TokenHash, &token.ExpiresAt, &token.RotatedAt, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.RefreshToken{}, models.ErrInvalidRefreshToken
+		}
+		t.logger.Error("failed to fetch refresh token", zap.Error(err))
+		return models.RefreshToken{}, fmt.Errorf("failed to fetch refresh token: %w", err)
+	}
+	return token, nil
+}
+
+// RotateRefreshToken marks the token tokenID as used and stores next in its
+// place. It fails with models.ErrRefreshTokenReused when the token has
+// already been rotated, revoked or has expired.
+func (t *TokenRepository) RotateRefreshToken(ctx context.Context, tokenID int, next *models.RefreshToken) error {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	err := withTx(ctx, t.db, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, queryRotateRefreshToken, tokenID)
+		if err != nil {
+			return fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return models.ErrRefreshTokenReused
+		}
+		return insertRefreshToken(ctx, tx, next)
+	})
+	if err != nil {
+		if !errors.Is(err, models.ErrRefreshTokenReused) {
+			t.logger.Error("failed to rotate refresh token",
+				zap.Int("token_id", tokenID),
+				zap.Error(err),
+			)
+		}
+		return err
+	}
+
+	t.logger.Debug("refresh token rotated",
+		zap.Int("token_id", tokenID),
+		zap.Int("next_token_id", next.ID),
+		zap.Duration("db_duration", time.Since(start)),
+	)
+	return nil
+}
+
+func (t *TokenRepository) RevokeTokenFamily(ctx context.Context, familyID string) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	tag, err := t.db.Exec(ctx, queryRevokeRefreshTokenFamily, familyID)
+	if err != nil {
+		t.logger.Error("failed to revoke refresh token family",
+			zap.String("family_id", familyID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+
+	t.logger.Info("refresh token family revoked",
+		zap.String("family_id", familyID),
+		zap.Int64("tokens", tag.RowsAffected()),
+	)
+	return nil
+}
+
+// RevokeAccessToken denylists the access token jti until it expires. Expired
+// entries are purged on the way.
+func (t *TokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	if _, err := t.db.Exec(ctx, queryDeleteExpiredRevokedAccessTokens); err != nil {
+		t.logger.Warn("failed to purge expired revoked access tokens", zap.Error(err))
+	}
+
+	if _, err := t.db.Exec(ctx, queryInsertRevokedAccessToken, jti, expiresAt.UTC()); err != nil {
+		t.logger.Error("failed to revoke access token",
+			zap.String("jti", jti),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSessions signs the user out everywhere: every refresh token is
+// revoked and access tokens issued until now are rejected. It returns the
+// moment from which new tokens are accepted again.
+func (t *TokenRepository) RevokeUserSessions(ctx context.Context, userID int) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var revokedAt time.Time
+	err := withTx(ctx, t.db, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, queryRevokeUserSessions, userID).Scan(&revokedAt); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return models.ErrUserNotFound
+			}
+			return fmt.Errorf("failed to revoke user sessions: %w", err)
+		}
+		if _, err := tx.Exec(ctx, queryRevokeUserRefreshTokens, userID); err != nil {
+			return fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.logger.Error("failed to revoke user sessions",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return time.Time{}, err
+	}
+
+	t.logger.Info("user sessions revoked",
+		zap.Int("user_id", userID),
+	)
+	return revokedAt, nil
+}
+
+// IsAccessTokenRevoked reports whether the token was denylisted or issued
+// before its user's sessions were revoked.
+func (t *TokenRepository) IsAccessTokenRevoked(ctx context.Context, claims models.AccessTokenClaims) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var revoked bool
+	err := t.db.QueryRow(ctx, querySelectRevokedAccessToken, claims.ID, claims.UserID, claims.IssuedAt.UTC()).Scan(&revoked)
+	if err != nil {
+		t.logger.Error("failed to check access token revocation",
+			zap.String("jti", claims.ID),
+			zap.Error(err),
+		)
+		return false, fmt.Errorf("failed to check access token revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+// isAccessTokenDenylisted reports whether the token was revoked on its own,
+// as by logout.
+func (t *TokenRepository) isAccessTokenDenylisted(ctx context.Context, jti string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var denylisted bool
+	if err := t.db.QueryRow(ctx, querySelectAccessTokenDenylisted, jti).Scan(&denylisted); err != nil {
+		t.logger.Error("failed to check access token denylist",
+			zap.String("jti", jti),
+			zap.Error(err),
+		)
+		return false, fmt.Errorf("failed to check access token denylist: %w", err)
+	}
+	return denylisted, nil
+}
+
+// getSessionsRevokedAt returns when the user's sessions were last revoked,
+// or the zero time if never. A deleted user fails with
+// models.ErrUserNotFound.
+func (t *TokenRepository) getSessionsRevokedAt(ctx context.Context, userID int) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultDBTimeout)
+	defer cancel()
+
+	var revokedAt *time.Time
+	if err := t.db.QueryRow(ctx, querySelectSessionsRevokedAt, userID).Scan(&revokedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, models.ErrUserNotFound
+		}
+		t.logger.Error("failed to get sessions revocation time",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return time.Time{}, fmt.Errorf("failed to get sessions revocation time: %w", err)
+	}
+	if revokedAt == nil {
+		return time.Time{}, nil
+	}
+	return *revokedAt, nil
+}
+
+func insertRefreshToken(ctx context.Context, q querier, token *models.RefreshToken) error {
+	err := q.QueryRow(ctx, queryInsertRefreshToken, token.UserID, token.FamilyID, token.TokenHash,
+		token.ExpiresAt.UTC()).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+	return nil
+}
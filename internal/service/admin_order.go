@@ -0,0 +1,79 @@
+package service
+
+import (
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/postgres"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+)
+
+// AdminOrderService gives staff access to the orders of every user. Changes
+// go through the same checks as customer updates and are recorded with the
+// acting staff member's ID.
+type AdminOrderService struct {
+	orders     *OrderService
+	repository postgres.Order
+	logger     *zap.Logger
+}
+
+func NewAdminOrderService(orders *OrderService, repo postgres.Order, logger *zap.Logger) *AdminOrderService {
+	return &AdminOrderService{
+		orders:     orders,
+		repository: repo,
+		logger:     logger,
+	}
+}
+
+func (a *AdminOrderService) SearchOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error) {
+	start := time.Now()
+	if err := normalizeOrderFilter(&filter); err != nil {
+		a.logger.Warn("invalid admin order filter", zap.Error(err))
+		return models.OrderPage{}, err
+	}
+
+	page, err := a.repository.SearchOrders(ctx, filter)
+	if err != nil {
+		a.logger.Error("failed to search orders",
+			zap.Error(err),
+			zap.Duration("total_duration", time.Since(start)),
+		)
+		return models.OrderPage{}, fmt.Errorf("failed to search orders: %w", err)
+	}
+	for i := range page.Orders {
+		page.Orders[i].NextStatuses = page.Orders[i].Status.NextStatuses()
+	}
+	return page, nil
+}
+
+func (a *AdminOrderService) GetOrderByID(ctx context.Context, orderID int) (models.Order, error) {
+	ownerID, err := a.repository.GetOrderOwner(ctx, orderID)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("failed to fetch order by ID: %w", err)
+	}
+	return a.orders.GetOrderByID(ctx, ownerID, orderID)
+}
+
+func (a *AdminOrderService) UpdateOrder(ctx context.Context, adminID int, orderID int, input models.OrderUpdateInput) error {
+	ownerID, err := a.repository.GetOrderOwner(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+
+	a.logger.Info("admin order update",
+		zap.Int("admin_id", adminID),
+		zap.Int("user_id", ownerID),
+		zap.Int("order_id", orderID),
+	)
+
+	return a.orders.updateOrder(ctx, ownerID, orderID, adminID, input)
+}
+
+func (a *AdminOrderService) GetOrderHistory(ctx context.Context, orderID int) ([]models.OrderStatusChange, error) {
+	ownerID, err := a.repository.GetOrderOwner(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order history: %w", err)
+	}
+	return a.orders.GetOrderHistory(ctx, ownerID, orderID)
+}
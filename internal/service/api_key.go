@@ -0,0 +1,147 @@
+package service
+
+import (
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/postgres"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// apiKeyPrefix starts every API key, which makes keys recognisable, for
+// example to secret scanners.
+const apiKeyPrefix = "ok_"
+
+type APIKeyService struct {
+	repo   postgres.APIKey
+	logger *zap.Logger
+}
+
+func NewAPIKeyService(repo postgres.APIKey, logger *zap.Logger) *APIKeyService {
+	return &APIKeyService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// CreateAPIKey creates a key for the user and returns it together with the
+// key itself. Only its hash is stored, so the key cannot be shown again.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, userID int, input models.APIKeyInput) (models.APIKey, string, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return models.APIKey{}, "", fmt.Errorf("%w: name must not be empty", models.ErrInvalidAPIKeyInput)
+	}
+	scopes, err := normalizeScopes(input.Scopes)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return models.APIKey{}, "", fmt.Errorf("%w: expires_at must be in the future", models.ErrInvalidAPIKeyInput)
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+	key := apiKeyPrefix + secret
+
+	stored := models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:models.APIKeyPrefixLength],
+		KeyHash:   hashToken(key),
+		Scopes:    scopes,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err = s.repo.CreateAPIKey(ctx, &stored); err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	s.logger.Info("api key created",
+		zap.Int("user_id", userID),
+		zap.Int("api_key_id", stored.ID),
+		zap.Any("scopes", scopes),
+	)
+	return stored, key, nil
+}
+
+func (s *APIKeyService) GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	return s.repo.GetAPIKeys(ctx, userID)
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID, keyID int) error {
+	if _, err := s.repo.RevokeAPIKey(ctx, userID, keyID); err != nil {
+		return err
+	}
+
+	s.logger.Info("api key revoked",
+		zap.Int("user_id", userID),
+		zap.Int("api_key_id", keyID),
+	)
+	return nil
+}
+
+// AuthenticateAPIKey returns the claims a request made with the key acts
+// with: the owner's current role, limited to the key's scopes. Unknown,
+// revoked and expired keys all fail with models.ErrInvalidAPIKey.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (models.AccessTokenClaims, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return models.AccessTokenClaims{}, models.ErrInvalidAPIKey
+	}
+
+	stored, err := s.repo.GetAPIKeyByHash(ctx, hashToken(key))
+	if err != nil {
+		return models.AccessTokenClaims{}, err
+	}
+
+	now := time.Now()
+	if !stored.IsActive(now) {
+		return models.AccessTokenClaims{}, models.ErrInvalidAPIKey
+	}
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= models.APIKeyTouchInterval {
+		if err = s.repo.TouchAPIKey(ctx, &stored); err != nil {
+			s.logger.Warn("failed to record api key use",
+				zap.Int("api_key_id", stored.ID),
+				zap.Error(err),
+			)
+		}
+		// The key may have been revoked since it was read.
+		if !stored.IsActive(now) {
+			return models.AccessTokenClaims{}, models.ErrInvalidAPIKey
+		}
+	}
+
+	claims := models.AccessTokenClaims{
+		UserID:   stored.UserID,
+		Role:     stored.OwnerRole,
+		Scopes:   stored.Scopes,
+		APIKeyID: stored.ID,
+		IssuedAt: stored.CreatedAt,
+	}
+	if stored.ExpiresAt != nil {
+		claims.ExpiresAt = *stored.ExpiresAt
+	}
+	return claims, nil
+}
+
+// normalizeScopes rejects unknown scopes and drops duplicates.
+func normalizeScopes(scopes []models.Scope) ([]models.Scope, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", models.ErrInvalidAPIKeyInput)
+	}
+
+	seen := make(map[models.Scope]bool, len(scopes))
+	normalized := make([]models.Scope, 0, len(scopes))
+	for _, scope := range scopes {
+		if !scope.IsValid() {
+			return nil, fmt.Errorf("%w: unknown scope %q", models.ErrInvalidAPIKeyInput, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	return normalized, nil
+}
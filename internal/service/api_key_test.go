@@ -0,0 +1,133 @@
+package service
+
+import (
+	"OrderKeeper/internal/models"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeAPIKeyRepo struct {
+	keys    map[string]models.APIKey
+	touches int
+}
+
+func (f *fakeAPIKeyRepo) CreateAPIKey(_ context.Context, key *models.APIKey) error {
+	key.ID = len(f.keys) + 1
+	key.CreatedAt = time.Now()
+	key.OwnerRole = models.RoleCustomer
+	f.keys[key.KeyHash] = *key
+	return nil
+}
+
+func (f *fakeAPIKeyRepo) GetAPIKeys(_ context.Context, userID int) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	for _, key := range f.keys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeAPIKeyRepo) GetAPIKeyByHash(_ context.Context, keyHash string) (models.APIKey, error) {
+	key, ok := f.keys[keyHash]
+	if !ok {
+		return models.APIKey{}, models.ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+func (f *fakeAPIKeyRepo) RevokeAPIKey(_ context.Context, userID, keyID int) (models.APIKey, error) {
+	for hash, key := range f.keys {
+		if key.ID == keyID && key.UserID == userID {
+			now := time.Now()
+			key.RevokedAt = &now
+			f.keys[hash] = key
+			return key, nil
+		}
+	}
+	return models.APIKey{}, models.ErrAPIKeyNotFound
+}
+
+func (f *fakeAPIKeyRepo) TouchAPIKey(_ context.Context, key *models.APIKey) error {
+	now := time.Now()
+	key.LastUsedAt = &now
+	f.keys[key.KeyHash] = *key
+	f.touches++
+	return nil
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	repo := &fakeAPIKeyRepo{keys: make(map[string]models.APIKey)}
+	service := NewAPIKeyService(repo, zap.NewNop())
+	ctx := context.Background()
+
+	stored, key, err := service.CreateAPIKey(ctx, 1, models.APIKeyInput{
+		Name:   "warehouse",
+		Scopes: []models.Scope{models.ScopeOrdersRead, models.ScopeOrdersRead},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(key, stored.Prefix) || stored.KeyHash == key {
+		t.Fatalf("key %q does not match stored prefix %q or is stored in clear text", key, stored.Prefix)
+	}
+
+	for i := 0; i < 2; i++ {
+		claims, err := service.AuthenticateAPIKey(ctx, key)
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i+1, err)
+		}
+		if claims.UserID != 1 || claims.APIKeyID != stored.ID || claims.Role != models.RoleCustomer {
+			t.Errorf("request %d: unexpected claims %+v", i+1, claims)
+		}
+		if !claims.HasScope(models.ScopeOrdersRead) || claims.HasScope(models.ScopeOrdersWrite) || len(claims.Scopes) != 1 {
+			t.Errorf("request %d: scopes = %v, want only %s", i+1, claims.Scopes, models.ScopeOrdersRead)
+		}
+	}
+	if repo.touches != 1 {
+		t.Errorf("last use recorded %d times, want 1 within %s", repo.touches, models.APIKeyTouchInterval)
+	}
+
+	if _, err = service.AuthenticateAPIKey(ctx, key+"x"); !errors.Is(err, models.ErrInvalidAPIKey) {
+		t.Errorf("unknown key: expected ErrInvalidAPIKey, got %v", err)
+	}
+
+	if err = service.RevokeAPIKey(ctx, 2, stored.ID); !errors.Is(err, models.ErrAPIKeyNotFound) {
+		t.Errorf("revoking another user's key: expected ErrAPIKeyNotFound, got %v", err)
+	}
+	if err = service.RevokeAPIKey(ctx, 1, stored.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Make the key due for a touch, which a revoked key must not get.
+	revoked := repo.keys[stored.KeyHash]
+	revoked.LastUsedAt = nil
+	repo.keys[stored.KeyHash] = revoked
+	touches := repo.touches
+	if _, err = service.AuthenticateAPIKey(ctx, key); !errors.Is(err, models.ErrInvalidAPIKey) {
+		t.Errorf("revoked key: expected ErrInvalidAPIKey, got %v", err)
+	}
+	if repo.touches != touches {
+		t.Errorf("last use of a revoked key was recorded")
+	}
+}
+
+func TestCreateAPIKeyValidatesInput(t *testing.T) {
+	service := NewAPIKeyService(&fakeAPIKeyRepo{keys: make(map[string]models.APIKey)}, zap.NewNop())
+	past := time.Now().Add(-time.Hour)
+
+	for name, input := range map[string]models.APIKeyInput{
+		"no scopes":     {Name: "ci"},
+		"unknown scope": {Name: "ci", Scopes: []models.Scope{"orders:delete"}},
+		"blank name":    {Name: "  ", Scopes: []models.Scope{models.ScopeOrdersRead}},
+		"expired":       {Name: "ci", Scopes: []models.Scope{models.ScopeOrdersRead}, ExpiresAt: &past},
+	} {
+		if _, _, err := service.CreateAPIKey(context.Background(), 1, input); !errors.Is(err, models.ErrInvalidAPIKeyInput) {
+			t.Errorf("%s: expected ErrInvalidAPIKeyInput, got %v", name, err)
+		}
+	}
+}
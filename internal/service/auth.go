@@ -2,34 +2,95 @@ package service
 
 import (
 	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/notifier"
 	"OrderKeeper/internal/repository/postgres"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
-	"os"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// Purposes of tokens that are not access tokens. Access tokens carry no
+// purpose, so these tokens can never be used as access tokens.
 const (
-	tokenTTL = 12 * time.Hour
+	tokenPurposeMFA             = "mfa"
+	tokenPurposeAccountDeletion = "account_deletion"
 )
 
+// passwordResetEmailTimeout bounds the background work of ForgotPassword,
+// which outlives the request that queued it.
+const passwordResetEmailTimeout = 30 * time.Second
+
 type tokenClaims struct {
 	jwt.RegisteredClaims
-	UserID int `json:"user_id"`
+	UserID  int         `json:"user_id"`
+	Role    models.Role `json:"role"`
+	Purpose string      `json:"purpose,omitempty"`
 }
 type AuthorizationService struct {
-	repo   postgres.Authorization
-	logger *zap.Logger
+	repo             postgres.Authorization
+	tokens           postgres.Token
+	keys             *TokenKeys
+	notifier         notifier.Notifier
+	passwordResetURL string
+	verificationURL  string
+	mfaIssuer        string
+	bcryptCost       int
+	throttle         *loginThrottle
+	background       *Background
+	// dummyHash is compared against when the username is unknown, so that
+	// the request takes as long as a real password check.
+	dummyHash []byte
+	logger    *zap.Logger
 }
 
-func NewAuthorizationService(repository postgres.Authorization, logger *zap.Logger) *AuthorizationService {
-	return &AuthorizationService{
-		repo:   repository,
-		logger: logger,
+// NewAuthorizationService fails when the dummy password hash cannot be made
+// with the configured cost: without it, unknown usernames would be rejected
+// faster than wrong passwords.
+func NewAuthorizationService(repository postgres.Authorization, tokens postgres.Token, attempts postgres.LoginAttempt, opts AuthOptions, logger *zap.Logger) (*AuthorizationService, error) {
+	cost := opts.BcryptCost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	// Made up front, so that the first sign-in with an unknown username does
+	// not take two bcrypt runs.
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("order-keeper-dummy-password"), cost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate dummy password hash: %w", err)
 	}
+	return &AuthorizationService{
+		repo:             repository,
+		tokens:           tokens,
+		keys:             opts.TokenKeys,
+		notifier:         opts.Notifier,
+		passwordResetURL: opts.PasswordResetURL,
+		verificationURL:  opts.EmailVerificationURL,
+		mfaIssuer:        opts.MFAIssuer,
+		bcryptCost:       cost,
+		throttle:         newLoginThrottle(attempts, opts.LoginThrottle, logger),
+		background:       opts.Background,
+		dummyHash:        dummyHash,
+		logger:           logger,
+	}, nil
+}
+
+// SetLoginThrottle replaces the sign-in throttling policies. Failures
+// already counted are kept and judged by the new policies.
+func (a *AuthorizationService) SetLoginThrottle(opts LoginThrottleOptions) {
+	a.throttle.setOptions(opts)
+	a.logger.Info("login throttle updated",
+		zap.Int("username_free_attempts", opts.Username.FreeAttempts),
+		zap.Int("ip_free_attempts", opts.IP.FreeAttempts),
+	)
 }
 
 func (a *AuthorizationService) CreateUser(ctx context.Context, user models.User) (int, error) {
@@ -40,7 +101,15 @@ func (a *AuthorizationService) CreateUser(ctx context.Context, user models.User)
 		zap.String("username", user.Username),
 	)
 
-	user.Password = generatePasswordHash(user.Password)
+	user.Role = models.RoleCustomer
+
+	hash, err := a.generatePasswordHash(user.Password)
+	if err != nil {
+		a.logger.Error("failed to hash password", zap.Error(err))
+		return 0, fmt.Errorf("failed to create user: %w", err)
+	}
+	user.Password = hash
+
 	id, err := a.repo.CreateUser(ctx, user)
 	if err != nil {
 		a.logger.Error("failed to create user", zap.Error(err),
@@ -59,23 +128,131 @@ func (a *AuthorizationService) CreateUser(ctx context.Context, user models.User)
 		zap.Duration("total_service_duration", time.Since(start)),
 	)
 
+	// The account exists either way; the user can ask for another email.
+	user.ID = id
+	if err = a.sendVerificationEmail(ctx, user, false); err != nil {
+		a.logger.Warn("failed to send verification email after sign-up",
+			zap.Int("user_id", id),
+			zap.Error(err),
+		)
+	}
+
 	return id, nil
 }
 
-func (a *AuthorizationService) GenerateToken(ctx context.Context, username, password string) (string, error) {
+// VerifyEmail marks the email of the token's user as verified.
+func (a *AuthorizationService) VerifyEmail(ctx context.Context, token string) error {
+	user, err := a.repo.VerifyEmail(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+
+	a.logger.Info("email verification completed",
+		zap.Int("user_id", user.ID),
+	)
+	return nil
+}
+
+// ResendVerificationEmail sends the user a new verification link. Requests
+// closer together than models.EmailVerificationResendInterval, or beyond
+// models.MaxEmailVerificationsPerHour, fail with a *models.RetryAfterError.
+func (a *AuthorizationService) ResendVerificationEmail(ctx context.Context, userID int) error {
+	user, err := a.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.EmailVerified {
+		return models.ErrEmailAlreadyVerified
+	}
+
+	return a.sendVerificationEmail(ctx, user, true)
+}
+
+// sendVerificationEmail stores a new verification token and emails it to the
+// user. Resends go through the repository's per-user limits.
+func (a *AuthorizationService) sendVerificationEmail(ctx context.Context, user models.User, resend bool) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	stored := models.EmailVerificationToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(models.EmailVerificationTokenTTL),
+	}
+	store := a.repo.CreateEmailVerificationToken
+	if resend {
+		store = a.repo.ResendEmailVerificationToken
+	}
+	if err = store(ctx, &stored); err != nil {
+		return err
+	}
+
+	err = a.notifier.Send(ctx, notifier.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease confirm your email address with the link below. "+
+			"It expires in %s.\n\n%s",
+			user.Username, models.EmailVerificationTokenTTL, tokenLink(a.verificationURL, "Verification token", token)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+
+	a.logger.Info("verification email sent",
+		zap.Int("user_id", user.ID),
+	)
+	return nil
+}
+
+// GenerateToken checks the user's password. Users without MFA are signed in
+// with a new refresh token family; users with MFA get a challenge that
+// CompleteMFASignIn exchanges for tokens. Repeated failures for the username
+// or the client IP are throttled with a *models.RetryAfterError.
+func (a *AuthorizationService) GenerateToken(ctx context.Context, username, password, clientIP string) (models.SignInResult, error) {
 	start := time.Now()
 	a.logger.Info("user get process started",
 		zap.String("username", username),
 	)
 
-	user, err := a.repo.GetUser(ctx, username, password)
+	attempt, err := a.throttle.reserve(ctx, a.throttle.passwordScopes(username, clientIP))
+	if err != nil {
+		return models.SignInResult{}, err
+	}
+	defer a.throttle.settle(ctx, attempt)
+
+	user, err := a.authenticate(ctx, username, password)
 	if err != nil {
-		a.logger.Error("failed to get user",
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			a.logger.Warn("invalid credentials",
+				zap.String("username", username),
+				zap.String("client_ip", clientIP),
+				zap.Duration("total_duration", time.Since(start)),
+			)
+			a.throttle.fail(attempt)
+			return models.SignInResult{}, err
+		}
+		a.logger.Error("failed to authenticate user",
 			zap.String("username", username),
 			zap.Error(err),
 			zap.Duration("total_duration", time.Since(start)),
 		)
-		return "", fmt.Errorf("failed to get user: %w", err)
+		return models.SignInResult{}, err
+	}
+
+	// With MFA the sign-in is not complete yet; CompleteMFASignIn clears
+	// the counters once the code is accepted.
+	if user.MFAEnabled {
+		challenge, err := a.newMFAChallenge(user)
+		if err != nil {
+			return models.SignInResult{}, err
+		}
+		a.logger.Info("mfa challenge issued",
+			zap.String("username", username),
+			zap.Int("user_id", user.ID),
+			zap.Duration("total_service_duration", time.Since(start)),
+		)
+		return models.SignInResult{MFAChallenge: &challenge}, nil
 	}
 
 	a.logger.Info("token generation process started",
@@ -83,13 +260,11 @@ func (a *AuthorizationService) GenerateToken(ctx context.Context, username, pass
 		zap.Int("user_id", user.ID),
 	)
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &tokenClaims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenTTL)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-		UserID: user.ID,
-	})
+	pair, err := a.startSession(ctx, user)
+	if err != nil {
+		return models.SignInResult{}, err
+	}
+	a.throttle.reset(ctx, a.throttle.usernameScope(username))
 
 	a.logger.Info("token generated successfully",
 		zap.String("username", username),
@@ -97,33 +272,422 @@ func (a *AuthorizationService) GenerateToken(ctx context.Context, username, pass
 		zap.Duration("total_service_duration", time.Since(start)),
 	)
 
-	return token.SignedString([]byte(os.Getenv("SIGNING_KEY")))
+	return models.SignInResult{Tokens: pair}, nil
+}
+
+// authenticate checks the username and password. Unknown usernames and wrong
+// passwords both fail with models.ErrInvalidCredentials after one bcrypt
+// comparison, so response times do not reveal which usernames exist.
+// Hashes made with a cost other than the configured one are replaced with a
+// new hash of the password.
+func (a *AuthorizationService) authenticate(ctx context.Context, username, password string) (models.User, error) {
+	user, err := a.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			_ = bcrypt.CompareHashAndPassword(a.dummyHash, []byte(password))
+			return models.User{}, models.ErrInvalidCredentials
+		}
+		return models.User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	hash, err := a.verifyPassword(ctx, user.ID, password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if cost, err := bcrypt.Cost([]byte(hash)); err == nil && cost != a.bcryptCost {
+		a.upgradePasswordHash(ctx, user.ID, hash, password, cost)
+	}
+
+	return user, nil
+}
+
+// verifyPassword checks password against the user's stored hash, which it
+// returns, and fails with models.ErrInvalidCredentials on a mismatch.
+func (a *AuthorizationService) verifyPassword(ctx context.Context, userID int, password string) (string, error) {
+	hash, err := a.repo.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get password hash: %w", err)
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return "", models.ErrInvalidCredentials
+		}
+		return "", fmt.Errorf("failed to compare password: %w", err)
+	}
+	return hash, nil
+}
+
+// upgradePasswordHash rehashes the password with the configured cost. A
+// failure only means the upgrade is retried on the next sign-in.
+func (a *AuthorizationService) upgradePasswordHash(ctx context.Context, userID int, oldHash, password string, oldCost int) {
+	newHash, err := a.generatePasswordHash(password)
+	if err != nil {
+		a.logger.Warn("failed to rehash password", zap.Int("user_id", userID), zap.Error(err))
+		return
+	}
+	if _, err = a.repo.UpdatePasswordHash(ctx, userID, oldHash, newHash); err != nil {
+		a.logger.Warn("failed to store rehashed password", zap.Int("user_id", userID), zap.Error(err))
+		return
+	}
+
+	a.logger.Info("password rehashed with new bcrypt cost",
+		zap.Int("user_id", userID),
+		zap.Int("old_cost", oldCost),
+		zap.Int("new_cost", a.bcryptCost),
+	)
+}
+
+// startSession issues a token pair that starts a new refresh token family.
+func (a *AuthorizationService) startSession(ctx context.Context, user models.User) (models.TokenPair, error) {
+	familyID, err := randomHex(16)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	refreshToken, stored, err := newRefreshToken(user.ID, familyID)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	if err = a.tokens.CreateRefreshToken(ctx, &stored); err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	pair, err := a.signAccessToken(user)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	pair.RefreshToken = refreshToken
+	return pair, nil
+}
+
+// RefreshToken exchanges a refresh token for a new token pair. The presented
+// token is rotated and cannot be used again; presenting a rotated token is
+// treated as theft and revokes its whole family.
+func (a *AuthorizationService) RefreshToken(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	stored, err := a.tokens.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	if stored.RotatedAt != nil {
+		a.revokeReusedFamily(ctx, stored)
+		return models.TokenPair{}, models.ErrRefreshTokenReused
+	}
+	if stored.RevokedAt != nil || !time.Now().Before(stored.ExpiresAt) {
+		return models.TokenPair{}, models.ErrInvalidRefreshToken
+	}
+
+	// The role is read afresh so that role changes apply from the next refresh.
+	user, err := a.repo.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	nextToken, next, err := newRefreshToken(stored.UserID, stored.FamilyID)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	if err = a.tokens.RotateRefreshToken(ctx, stored.ID, &next); err != nil {
+		if errors.Is(err, models.ErrRefreshTokenReused) {
+			a.revokeReusedFamily(ctx, stored)
+		}
+		return models.TokenPair{}, err
+	}
+
+	pair, err := a.signAccessToken(user)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	pair.RefreshToken = nextToken
+
+	a.logger.Info("refresh token rotated",
+		zap.Int("user_id", stored.UserID),
+		zap.String("family_id", stored.FamilyID),
+	)
+
+	return pair, nil
 }
-func (a *AuthorizationService) ParseToken(ctx context.Context, token string) (int, error) {
-	parsedToken, err := jwt.ParseWithClaims(token, &tokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+
+// Logout revokes the access token described by claims and, when given, the
+// family of the user's refresh token.
+func (a *AuthorizationService) Logout(ctx context.Context, claims models.AccessTokenClaims, refreshToken string) error {
+	if err := a.tokens.RevokeAccessToken(ctx, claims.ID, claims.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	if refreshToken != "" {
+		stored, err := a.tokens.GetRefreshToken(ctx, hashToken(refreshToken))
+		if err != nil {
+			return err
+		}
+		if stored.UserID != claims.UserID {
+			return models.ErrInvalidRefreshToken
 		}
-		return []byte(os.Getenv("SIGNING_KEY")), nil
+		if err = a.tokens.RevokeTokenFamily(ctx, stored.FamilyID); err != nil {
+			return fmt.Errorf("failed to revoke refresh token: %w", err)
+		}
+	}
+
+	a.logger.Info("user logged out",
+		zap.Int("user_id", claims.UserID),
+		zap.Bool("refresh_token_revoked", refreshToken != ""),
+	)
+
+	return nil
+}
+
+func (a *AuthorizationService) ParseToken(ctx context.Context, token string) (models.AccessTokenClaims, error) {
+	parsedToken, err := jwt.ParseWithClaims(token, &tokenClaims{}, a.keys.Keyfunc)
+	if err != nil {
+		return models.AccessTokenClaims{}, err
+	}
+
+	claims, ok := parsedToken.Claims.(*tokenClaims)
+	if !ok || !parsedToken.Valid || claims.ID == "" || claims.ExpiresAt == nil || claims.IssuedAt == nil ||
+		claims.Purpose != "" {
+		return models.AccessTokenClaims{}, fmt.Errorf("invalid token")
+	}
+
+	return models.AccessTokenClaims{
+		ID:        claims.ID,
+		UserID:    claims.UserID,
+		Role:      claims.Role,
+		Scopes:    models.AllScopes(),
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// JWKS returns the public keys that verify access tokens.
+func (a *AuthorizationService) JWKS() models.JSONWebKeySet {
+	return a.keys.JWKS()
+}
+
+func (a *AuthorizationService) IsTokenRevoked(ctx context.Context, claims models.AccessTokenClaims) (bool, error) {
+	return a.tokens.IsAccessTokenRevoked(ctx, claims)
+}
+
+// ForgotPassword emails a password reset link to the user with this email.
+// Requests are limited per email and per client IP; a throttled request
+// fails with a *models.RetryAfterError whether or not the address has an
+// account. The email is sent in the background and nothing about it is
+// reported back, so neither the result nor the response time tells whether
+// the address has an account. Failures are only logged.
+func (a *AuthorizationService) ForgotPassword(ctx context.Context, email, clientIP string) error {
+	// Every request counts, whatever happens to the email, so the attempt
+	// is never settled.
+	if _, err := a.throttle.reserve(ctx, a.throttle.passwordResetScopes(email, clientIP)); err != nil {
+		return err
+	}
+
+	err := a.background.Go("password_reset_email", passwordResetEmailTimeout, func(ctx context.Context) error {
+		return a.sendPasswordReset(ctx, email)
+	})
+	if err != nil {
+		a.logger.Error("failed to queue password reset email", zap.Error(err))
+	}
+	return nil
+}
+
+func (a *AuthorizationService) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := a.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			a.logger.Info("password reset requested for unknown email")
+			return nil
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+	stored := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(models.PasswordResetTokenTTL),
+	}
+	if err = a.repo.CreatePasswordResetToken(ctx, &stored); err != nil {
+		return err
+	}
+
+	err = a.notifier.Send(ctx, notifier.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nUse the link below to choose a new password. "+
+			"It expires in %s and can be used once.\n\n%s\n\n"+
+			"If you did not ask for a password reset, you can ignore this email.",
+			user.Username, models.PasswordResetTokenTTL, tokenLink(a.passwordResetURL, "Reset token", token)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send password reset email to user %d: %w", user.ID, err)
+	}
+
+	a.logger.Info("password reset email sent",
+		zap.Int("user_id", user.ID),
+	)
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token and, in the same
+// transaction, signs the user out of every existing session.
+func (a *AuthorizationService) ResetPassword(ctx context.Context, token, password string) error {
+	hash, err := a.generatePasswordHash(password)
+	if err != nil {
+		return err
+	}
+
+	user, _, err := a.repo.ResetPassword(ctx, hashToken(token), hash)
+	if err != nil {
+		return err
+	}
+
+	a.logger.Info("password reset completed",
+		zap.Int("user_id", user.ID),
+	)
+	return nil
+}
+
+// tokenLink returns page with the token appended as the token query
+// parameter, or the labelled bare token when no page is configured.
+func tokenLink(page, label, token string) string {
+	if page == "" {
+		return label + ": " + token
+	}
+	separator := "?"
+	if strings.Contains(page, "?") {
+		separator = "&"
+	}
+	return page + separator + "token=" + url.QueryEscape(token)
+}
+
+func (a *AuthorizationService) signAccessToken(user models.User) (models.TokenPair, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(models.AccessTokenTTL)
+	signed, err := a.keys.Sign(&tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		UserID: user.ID,
+		Role:   user.Role,
 	})
 	if err != nil {
-		return 0, err
+		return models.TokenPair{}, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	return models.TokenPair{
+		AccessToken:          signed,
+		AccessTokenExpiresAt: expiresAt,
+	}, nil
+}
+
+// signPurposeToken signs a short-lived token for a step of a multi-step flow,
+// such as an MFA sign-in. The purpose keeps it from being accepted anywhere
+// else.
+func (a *AuthorizationService) signPurposeToken(userID int, purpose string, ttl time.Duration) (string, time.Time, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	signed, err := a.keys.Sign(&tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		UserID:  userID,
+		Purpose: purpose,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+func (a *AuthorizationService) parsePurposeToken(token, purpose string) (models.AccessTokenClaims, error) {
+	parsedToken, err := jwt.ParseWithClaims(token, &tokenClaims{}, a.keys.Keyfunc)
+	if err != nil {
+		return models.AccessTokenClaims{}, err
 	}
 
 	claims, ok := parsedToken.Claims.(*tokenClaims)
-	if !ok || !parsedToken.Valid {
-		return 0, fmt.Errorf("invalid token")
+	if !ok || !parsedToken.Valid || claims.ID == "" || claims.ExpiresAt == nil || claims.IssuedAt == nil ||
+		claims.Purpose != purpose {
+		return models.AccessTokenClaims{}, fmt.Errorf("invalid %s token", purpose)
+	}
+
+	return models.AccessTokenClaims{
+		ID:        claims.ID,
+		UserID:    claims.UserID,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+func (a *AuthorizationService) revokeReusedFamily(ctx context.Context, token models.RefreshToken) {
+	a.logger.Warn("refresh token reuse detected, revoking token family",
+		zap.Int("user_id", token.UserID),
+		zap.String("family_id", token.FamilyID),
+	)
+	if err := a.tokens.RevokeTokenFamily(ctx, token.FamilyID); err != nil {
+		a.logger.Error("failed to revoke reused refresh token family",
+			zap.String("family_id", token.FamilyID),
+			zap.Error(err),
+		)
 	}
+}
 
-	return claims.UserID, nil
+// newRefreshToken returns a fresh opaque refresh token together with the
+// record to store for it.
+func newRefreshToken(userID int, familyID string) (string, models.RefreshToken, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	return token, models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(models.RefreshTokenTTL),
+	}, nil
 }
 
-func generatePasswordHash(password string) string {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
 
+func (a *AuthorizationService) generatePasswordHash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), a.bcryptCost)
 	if err != nil {
-		fmt.Errorf("could not generate password: %v", err)
+		return "", fmt.Errorf("could not generate password: %w", err)
 	}
 
-	return string(hashedPassword)
+	return string(hashedPassword), nil
 }
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/postgres"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testUsername = "john"
+	testPassword = "secret123"
+)
+
+// fakeAuthRepo keeps users in memory. It does not stand in for
+// CachedAuthRepository, which needs Redis and Postgres; these tests cover the
+// service only.
+type fakeAuthRepo struct {
+	postgres.Authorization
+
+	users              map[string]models.User
+	hashes             map[int]string
+	hashReads          atomic.Int32
+	rehashes           int
+	verificationTokens int
+}
+
+func newFakeAuthRepo(t *testing.T) *fakeAuthRepo {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	return &fakeAuthRepo{
+		users: map[string]models.User{
+			testUsername: {ID: 1, Username: testUsername, Email: "john@example.com", Role: models.RoleCustomer},
+		},
+		hashes: map[int]string{1: string(hash)},
+	}
+}
+
+func (f *fakeAuthRepo) GetUserByUsername(_ context.Context, username string) (models.User, error) {
+	user, ok := f.users[username]
+	if !ok {
+		return models.User{}, fmt.Errorf("%w: %s", models.ErrUserNotFound, username)
+	}
+	return user, nil
+}
+
+func (f *fakeAuthRepo) GetPasswordHash(_ context.Context, userID int) (string, error) {
+	f.hashReads.Add(1)
+	hash, ok := f.hashes[userID]
+	if !ok {
+		return "", models.ErrUserNotFound
+	}
+	return hash, nil
+}
+
+func (f *fakeAuthRepo) UpdatePasswordHash(_ context.Context, userID int, oldHash, newHash string) (bool, error) {
+	if f.hashes[userID] != oldHash {
+		return false, nil
+	}
+	f.hashes[userID] = newHash
+	f.rehashes++
+	return true, nil
+}
+
+type fakeTokenRepo struct {
+	postgres.Token
+
+	refreshTokens   []models.RefreshToken
+	sessionsRevoked int
+}
+
+func (f *fakeTokenRepo) CreateRefreshToken(_ context.Context, token *models.RefreshToken) error {
+	token.ID = len(f.refreshTokens) + 1
+	f.refreshTokens = append(f.refreshTokens, *token)
+	return nil
+}
+
+type fakeLoginAttemptRepo struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempts
+}
+
+func (f *fakeLoginAttemptRepo) GetLoginAttempts(_ context.Context, key string) (models.LoginAttempts, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts[key], nil
+}
+
+func (f *fakeLoginAttemptRepo) RecordLoginFailure(_ context.Context, key string, _ time.Duration) (models.LoginAttempts, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	attempts := f.attempts[key]
+	attempts.Failures++
+	attempts.LastFailureAt = time.Now()
+	f.attempts[key] = attempts
+	return attempts, nil
+}
+
+func (f *fakeLoginAttemptRepo) ReleaseLoginAttempt(_ context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if attempts, ok := f.attempts[key]; ok && attempts.Failures > 0 {
+		attempts.Failures--
+		f.attempts[key] = attempts
+	}
+	return nil
+}
+
+func (f *fakeLoginAttemptRepo) ResetLoginAttempts(_ context.Context, keys ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, key := range keys {
+		delete(f.attempts, key)
+	}
+	return nil
+}
+
+func newTestAuthService(t *testing.T, repo *fakeAuthRepo) (*AuthorizationService, *fakeTokenRepo, *fakeLoginAttemptRepo) {
+	return newTestAuthServiceWithCost(t, repo, bcrypt.MinCost)
+}
+
+func newTestAuthServiceWithCost(t *testing.T, repo *fakeAuthRepo, cost int) (*AuthorizationService, *fakeTokenRepo, *fakeLoginAttemptRepo) {
+	t.Helper()
+
+	keys, err := LoadTokenKeys(TokenKeysConfig{Algorithm: AlgorithmHS256, Secret: "test-secret"})
+	if err != nil {
+		t.Fatalf("failed to load token keys: %v", err)
+	}
+
+	tokens := &fakeTokenRepo{}
+	attempts := &fakeLoginAttemptRepo{attempts: make(map[string]models.LoginAttempts)}
+	service, err := NewAuthorizationService(repo, tokens, attempts, AuthOptions{
+		TokenKeys:     keys,
+		LoginThrottle: DefaultLoginThrottleOptions(),
+		BcryptCost:    cost,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create auth service: %v", err)
+	}
+	return service, tokens, attempts
+}
+
+func TestGenerateTokenVerifiesPassword(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	service, tokens, _ := newTestAuthService(t, repo)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		result, err := service.GenerateToken(ctx, testUsername, testPassword, "10.0.0.1")
+		if err != nil {
+			t.Fatalf("sign-in %d: unexpected error: %v", i+1, err)
+		}
+		if result.MFAChallenge != nil || result.Tokens.AccessToken == "" || result.Tokens.RefreshToken == "" {
+			t.Fatalf("sign-in %d: expected a token pair, got %+v", i+1, result)
+		}
+
+		claims, err := service.ParseToken(ctx, result.Tokens.AccessToken)
+		if err != nil {
+			t.Fatalf("sign-in %d: issued access token does not parse: %v", i+1, err)
+		}
+		if claims.UserID != 1 {
+			t.Errorf("sign-in %d: token user id = %d, want 1", i+1, claims.UserID)
+		}
+	}
+
+	if repo.hashReads.Load() != 2 {
+		t.Errorf("password hash reads = %d, want 2: the hash must be read on every sign-in", repo.hashReads.Load())
+	}
+	if len(tokens.refreshTokens) != 2 {
+		t.Errorf("refresh tokens created = %d, want 2", len(tokens.refreshTokens))
+	}
+}
+
+func TestGenerateTokenRejectsWrongPassword(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	service, tokens, attempts := newTestAuthService(t, repo)
+	ctx := context.Background()
+
+	for _, password := range []string{"wrong-password", testPassword + " ", strings.ToUpper(testPassword)} {
+		_, err := service.GenerateToken(ctx, testUsername, password, "10.0.0.1")
+		if !errors.Is(err, models.ErrInvalidCredentials) {
+			t.Errorf("password %q: expected ErrInvalidCredentials, got %v", password, err)
+		}
+	}
+
+	if len(tokens.refreshTokens) != 0 {
+		t.Errorf("refresh tokens were issued for wrong passwords")
+	}
+	if got := attempts.attempts["user:"+testUsername].Failures; got != 3 {
+		t.Errorf("recorded failures = %d, want 3", got)
+	}
+}
+
+// Parallel attempts must not all get past the limit before the first of them
+// has failed.
+func TestGenerateTokenThrottlesParallelAttempts(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	service, _, _ := newTestAuthService(t, repo)
+	ctx := context.Background()
+
+	const burst = 20
+	errs := make(chan error, burst)
+	var wg sync.WaitGroup
+	for i := 0; i < burst; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.GenerateToken(ctx, testUsername, "wrong-password", "10.0.0.1")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	verified := 0
+	for err := range errs {
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
+			verified++
+		case !errors.Is(err, models.ErrTooManyLoginAttempts):
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if limit := DefaultLoginThrottleOptions().Username.FreeAttempts + 1; verified > limit {
+		t.Errorf("verified %d of %d parallel attempts, want at most %d", verified, burst, limit)
+	}
+}
+
+func TestGenerateTokenResetsOnlyUsernameCounter(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	service, _, attempts := newTestAuthService(t, repo)
+	ctx := context.Background()
+
+	if _, err := service.GenerateToken(ctx, testUsername, "wrong-password", "10.0.0.1"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	attempts.attempts["mfa:1"] = models.LoginAttempts{Failures: 2, LastFailureAt: time.Now()}
+
+	if _, err := service.GenerateToken(ctx, testUsername, testPassword, "10.0.0.1"); err != nil {
+		t.Fatalf("sign-in failed: %v", err)
+	}
+	if _, ok := attempts.attempts["user:"+testUsername]; ok {
+		t.Errorf("username counter was not reset")
+	}
+	if got := attempts.attempts["ip:10.0.0.1"].Failures; got != 1 {
+		t.Errorf("ip failures = %d, want 1: a sign-in must not lift the IP counter", got)
+	}
+	if got := attempts.attempts["mfa:1"].Failures; got != 2 {
+		t.Errorf("mfa failures = %d, want 2: a password must not reset the MFA counter", got)
+	}
+}
+
+func TestGenerateTokenUnknownUser(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	service, tokens, attempts := newTestAuthService(t, repo)
+
+	_, err := service.GenerateToken(context.Background(), "nobody", testPassword, "10.0.0.1")
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if errors.Is(err, models.ErrUserNotFound) {
+		t.Errorf("error reveals that the user does not exist: %v", err)
+	}
+	if len(tokens.refreshTokens) != 0 {
+		t.Errorf("refresh tokens were issued for an unknown user")
+	}
+	if got := attempts.attempts["user:nobody"].Failures; got != 1 {
+		t.Errorf("recorded failures = %d, want 1", got)
+	}
+}
+
+func TestNewAuthorizationServiceRejectsInvalidCost(t *testing.T) {
+	_, err := NewAuthorizationService(newFakeAuthRepo(t), &fakeTokenRepo{}, &fakeLoginAttemptRepo{}, AuthOptions{
+		BcryptCost: bcrypt.MaxCost + 1,
+	}, zap.NewNop())
+	if err == nil {
+		t.Fatal("expected an error for a cost without a dummy password hash")
+	}
+}
+
+func TestGenerateTokenUpgradesBcryptCost(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	service, _, _ := newTestAuthServiceWithCost(t, repo, bcrypt.MinCost+1)
+	ctx := context.Background()
+
+	if _, err := service.GenerateToken(ctx, testUsername, testPassword, "10.0.0.1"); err != nil {
+		t.Fatalf("sign-in failed: %v", err)
+	}
+	if repo.rehashes != 1 {
+		t.Fatalf("rehashes = %d, want 1", repo.rehashes)
+	}
+	cost, err := bcrypt.Cost([]byte(repo.hashes[1]))
+	if err != nil {
+		t.Fatalf("stored hash is not a bcrypt hash: %v", err)
+	}
+	if cost != bcrypt.MinCost+1 {
+		t.Errorf("stored hash cost = %d, want %d", cost, bcrypt.MinCost+1)
+	}
+
+	// The upgraded hash still accepts the password and is not rehashed again.
+	if _, err = service.GenerateToken(ctx, testUsername, testPassword, "10.0.0.1"); err != nil {
+		t.Fatalf("sign-in with upgraded hash failed: %v", err)
+	}
+	if repo.rehashes != 1 {
+		t.Errorf("rehashes = %d, want 1", repo.rehashes)
+	}
+}
+
+func TestGenerateTokenKeepsHashOfConfiguredCost(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	service, _, _ := newTestAuthService(t, repo)
+
+	if _, err := service.GenerateToken(context.Background(), testUsername, testPassword, "10.0.0.1"); err != nil {
+		t.Fatalf("sign-in failed: %v", err)
+	}
+	if repo.rehashes != 0 {
+		t.Errorf("rehashes = %d, want 0", repo.rehashes)
+	}
+}
+
+func TestUserJSONOmitsPassword(t *testing.T) {
+	data, err := json.Marshal(models.User{ID: 1, Username: testUsername, Password: "$2a$10$secret-hash"})
+	if err != nil {
+		t.Fatalf("failed to marshal user: %v", err)
+	}
+	if strings.Contains(string(data), "secret-hash") || strings.Contains(string(data), "password") {
+		t.Errorf("serialized user contains password material: %s", data)
+	}
+}
+
+func TestForgotPasswordThrottlesPerEmail(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	service, _, _ := newTestAuthService(t, repo)
+	service.background = NewBackground(zap.NewNop())
+	ctx := context.Background()
+
+	free := DefaultLoginThrottleOptions().Username.FreeAttempts
+	for i := 0; i <= free; i++ {
+		if err := service.ForgotPassword(ctx, "John@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i+1, err)
+		}
+	}
+	err := service.ForgotPassword(ctx, "john@example.com", "10.0.0.1")
+	var retryErr *models.RetryAfterError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("expected a RetryAfterError once the free requests are used, got %v", err)
+	}
+	if queued := len(service.background.jobs); queued != free+1 {
+		t.Errorf("queued emails = %d, want %d: a throttled request must not send one", queued, free+1)
+	}
+
+	if err = service.ForgotPassword(ctx, "jane@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("another email from the same client IP was throttled: %v", err)
+	}
+}
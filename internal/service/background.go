@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+const (
+	backgroundWorkers   = 4
+	backgroundQueueSize = 256
+)
+
+// ErrBackgroundQueueFull is returned by Background.Go when the queue has no
+// room left or the worker is shutting down.
+var ErrBackgroundQueueFull = errors.New("background queue is full")
+
+type backgroundJob struct {
+	name    string
+	timeout time.Duration
+	fn      func(ctx context.Context) error
+}
+
+// Background runs work that outlives the request that queued it, such as
+// sending password reset emails, on a fixed number of goroutines. It is a
+// lifecycle component: Run executes the queued jobs until Stop, which waits
+// for the queue to drain, so the jobs finish before Postgres and Redis are
+// closed.
+type Background struct {
+	mu      sync.RWMutex
+	stopped bool
+	jobs    chan backgroundJob
+	drained chan struct{}
+	logger  *zap.Logger
+}
+
+func NewBackground(logger *zap.Logger) *Background {
+	return &Background{
+		jobs:    make(chan backgroundJob, backgroundQueueSize),
+		drained: make(chan struct{}),
+		logger:  logger,
+	}
+}
+
+// Go queues fn to run with a context derived from the one passed to Run and
+// bounded by timeout. It does not block: when the queue is full or the worker
+// is stopping, the job is dropped and ErrBackgroundQueueFull returned.
+func (b *Background) Go(name string, timeout time.Duration, fn func(ctx context.Context) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.stopped {
+		return ErrBackgroundQueueFull
+	}
+	select {
+	case b.jobs <- backgroundJob{name: name, timeout: timeout, fn: fn}:
+		return nil
+	default:
+		return ErrBackgroundQueueFull
+	}
+}
+
+// Run executes queued jobs until Stop has been called and the queue is
+// empty. Jobs still running when ctx is cancelled see their context done.
+func (b *Background) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for range backgroundWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range b.jobs {
+				b.run(ctx, job)
+			}
+		}()
+	}
+	wg.Wait()
+	close(b.drained)
+	return nil
+}
+
+func (b *Background) run(ctx context.Context, job backgroundJob) {
+	ctx, cancel := context.WithTimeout(ctx, job.timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := job.fn(ctx); err != nil {
+		b.logger.Error("background job failed",
+			zap.String("job", job.name),
+			zap.Duration("duration", time.Since(start)),
+			zap.Error(err),
+		)
+		return
+	}
+	b.logger.Debug("background job completed",
+		zap.String("job", job.name),
+		zap.Duration("duration", time.Since(start)),
+	)
+}
+
+// Stop stops accepting jobs and waits until the queued ones have run, or
+// until ctx is done.
+func (b *Background) Stop(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.stopped {
+		b.stopped = true
+		close(b.jobs)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.drained:
+		return nil
+	case <-ctx.Done():
+		b.logger.Warn("background jobs still running at shutdown",
+			zap.Int("queued", len(b.jobs)),
+		)
+		return ctx.Err()
+	}
+}
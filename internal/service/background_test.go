@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackgroundStopRunsQueuedJobs(t *testing.T) {
+	background := NewBackground(zap.NewNop())
+	var ran atomic.Int32
+	for range 10 {
+		err := background.Go("test", time.Second, func(ctx context.Context) error {
+			ran.Add(1)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Go() error = %v", err)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- background.Run(context.Background()) }()
+
+	if err := background.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if n := ran.Load(); n != 10 {
+		t.Fatalf("ran %d jobs, want 10", n)
+	}
+
+	err := background.Go("test", time.Second, func(ctx context.Context) error { return nil })
+	if !errors.Is(err, ErrBackgroundQueueFull) {
+		t.Fatalf("Go() after Stop error = %v, want ErrBackgroundQueueFull", err)
+	}
+}
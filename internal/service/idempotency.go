@@ -0,0 +1,75 @@
+package service
+
+import (
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/postgres"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+type IdempotencyService struct {
+	repository postgres.Idempotency
+	logger     *zap.Logger
+}
+
+func NewIdempotencyService(repo postgres.Idempotency, logger *zap.Logger) *IdempotencyService {
+	return &IdempotencyService{
+		repository: repo,
+		logger:     logger,
+	}
+}
+
+// Begin claims key for the user. When the key was already used for the same
+// request and that request has finished, it returns the stored record with
+// replay set to true; the caller must then send the stored response instead
+// of processing the request again.
+func (i *IdempotencyService) Begin(ctx context.Context, userID int, key, fingerprint string) (models.IdempotencyRecord, bool, error) {
+	record, reserved, err := i.repository.ReserveKey(ctx, models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+	})
+	if err != nil {
+		return models.IdempotencyRecord{}, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if reserved {
+		return record, false, nil
+	}
+
+	if record.Fingerprint != fingerprint {
+		i.logger.Warn("idempotency key reused with a different request",
+			zap.Int("user_id", userID),
+			zap.String("key", key),
+		)
+		return models.IdempotencyRecord{}, false, models.ErrIdempotencyKeyMismatch
+	}
+	if !record.Completed {
+		return models.IdempotencyRecord{}, false, models.ErrIdempotencyKeyInProgress
+	}
+
+	i.logger.Info("replaying idempotent response",
+		zap.Int("user_id", userID),
+		zap.String("key", key),
+		zap.Int("status_code", record.StatusCode),
+	)
+	return record, true, nil
+}
+
+// Complete stores the response of the request that reserved the key.
+func (i *IdempotencyService) Complete(ctx context.Context, record models.IdempotencyRecord) error {
+	record.Completed = true
+	if err := i.repository.CompleteKey(ctx, record); err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// Abandon frees the key after a failure that the client should be able to
+// retry with the same key.
+func (i *IdempotencyService) Abandon(ctx context.Context, userID int, key string) error {
+	if err := i.repository.ReleaseKey(ctx, userID, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"OrderKeeper/internal/handler/metrics"
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/postgres"
+	"context"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	loginScopeUsername = "username"
+	loginScopeIP       = "ip"
+	loginScopeMFA      = "mfa"
+	// Password reset requests are counted apart from sign-ins, so asking
+	// for reset emails does not slow down signing in.
+	loginScopePasswordReset   = "password_reset"
+	loginScopePasswordResetIP = "password_reset_ip"
+)
+
+// LoginThrottlePolicy slows down password guessing for one throttling scope.
+// The first FreeAttempts failures cost nothing; every further failure doubles
+// the wait before the next attempt, starting at BaseDelay and capped at
+// MaxDelay. LockoutThreshold failures lock the key for LockoutDuration.
+// Counters are forgotten once Window passes without a failure.
+type LoginThrottlePolicy struct {
+	FreeAttempts     int           `mapstructure:"free_attempts"`
+	BaseDelay        time.Duration `mapstructure:"base_delay"`
+	MaxDelay         time.Duration `mapstructure:"max_delay"`
+	LockoutThreshold int           `mapstructure:"lockout_threshold"`
+	LockoutDuration  time.Duration `mapstructure:"lockout_duration"`
+	Window           time.Duration `mapstructure:"window"`
+}
+
+// LoginThrottleOptions holds the policies for failures counted per username
+// and per client IP. The IP policy is usually more lenient, since many users
+// can share an address.
+type LoginThrottleOptions struct {
+	Username LoginThrottlePolicy `mapstructure:"username"`
+	IP       LoginThrottlePolicy `mapstructure:"ip"`
+}
+
+func DefaultLoginThrottleOptions() LoginThrottleOptions {
+	return LoginThrottleOptions{
+		Username: LoginThrottlePolicy{
+			FreeAttempts:     3,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: 10,
+			LockoutDuration:  15 * time.Minute,
+			Window:           15 * time.Minute,
+		},
+		IP: LoginThrottlePolicy{
+			FreeAttempts:     20,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: 100,
+			LockoutDuration:  15 * time.Minute,
+			Window:           15 * time.Minute,
+		},
+	}
+}
+
+// delay is the wait imposed after the given number of failures.
+func (p LoginThrottlePolicy) delay(failures int) time.Duration {
+	if p.LockoutThreshold > 0 && failures >= p.LockoutThreshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	shift := failures - p.FreeAttempts - 1
+	if shift >= 32 {
+		return p.MaxDelay
+	}
+	delay := p.BaseDelay << shift
+	if delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+type loginThrottleScope struct {
+	name   string
+	key    string
+	policy LoginThrottlePolicy
+}
+
+// loginThrottle tracks failed sign-ins per username, per client IP and, for
+// MFA codes, per user ID. The options can be replaced while requests are
+// being served.
+//
+// Wrong MFA codes are counted under their own key, which no password success
+// clears: otherwise whoever knows the password could sign in again after
+// every few wrong codes and guess them without limit. They use the username
+// policy. IP counters are never reset and only expire with their window, so
+// signing in to an account of one's own does not lift an IP lockout.
+type loginThrottle struct {
+	repo   postgres.LoginAttempt
+	opts   atomic.Pointer[LoginThrottleOptions]
+	logger *zap.Logger
+}
+
+func newLoginThrottle(repo postgres.LoginAttempt, opts LoginThrottleOptions, logger *zap.Logger) *loginThrottle {
+	t := &loginThrottle{
+		repo:   repo,
+		logger: logger,
+	}
+	t.opts.Store(&opts)
+	return t
+}
+
+func (t *loginThrottle) setOptions(opts LoginThrottleOptions) {
+	t.opts.Store(&opts)
+}
+
+func (t *loginThrottle) usernameScope(username string) loginThrottleScope {
+	return loginThrottleScope{
+		name:   loginScopeUsername,
+		key:    "user:" + strings.ToLower(username),
+		policy: t.opts.Load().Username,
+	}
+}
+
+func (t *loginThrottle) mfaScope(userID int) loginThrottleScope {
+	return loginThrottleScope{
+		name:   loginScopeMFA,
+		key:    "mfa:" + strconv.Itoa(userID),
+		policy: t.opts.Load().Username,
+	}
+}
+
+func (t *loginThrottle) withIPScope(scope loginThrottleScope, clientIP string) []loginThrottleScope {
+	scopes := []loginThrottleScope{scope}
+	if clientIP != "" {
+		scopes = append(scopes, loginThrottleScope{
+			name:   loginScopeIP,
+			key:    "ip:" + clientIP,
+			policy: t.opts.Load().IP,
+		})
+	}
+	return scopes
+}
+
+// passwordScopes are the counters for a password attempt.
+func (t *loginThrottle) passwordScopes(username, clientIP string) []loginThrottleScope {
+	return t.withIPScope(t.usernameScope(username), clientIP)
+}
+
+// mfaScopes are the counters for an MFA code attempt.
+func (t *loginThrottle) mfaScopes(userID int, clientIP string) []loginThrottleScope {
+	return t.withIPScope(t.mfaScope(userID), clientIP)
+}
+
+// passwordResetScopes are the counters for a password reset request, per
+// email with the username policy and per client IP with the IP policy.
+func (t *loginThrottle) passwordResetScopes(email, clientIP string) []loginThrottleScope {
+	opts := t.opts.Load()
+	scopes := []loginThrottleScope{{
+		name:   loginScopePasswordReset,
+		key:    "reset:" + strings.ToLower(email),
+		policy: opts.Username,
+	}}
+	if clientIP != "" {
+		scopes = append(scopes, loginThrottleScope{
+			name:   loginScopePasswordResetIP,
+			key:    "reset-ip:" + clientIP,
+			policy: opts.IP,
+		})
+	}
+	return scopes
+}
+
+// loginAttempt is an attempt counted by reserve. It is counted as a failure
+// from the start, so parallel attempts cannot all get past the limits before
+// the first of them has failed.
+type loginAttempt struct {
+	scopes   []loginThrottleScope
+	failures []int
+	failed   bool
+}
+
+// reserve counts an attempt against every scope before the caller verifies
+// anything. It fails with a *models.RetryAfterError while any of the scopes
+// has to wait, or when attempts made in parallel have used up the free ones;
+// a rejected attempt is not counted. Callers mark a wrong password or code
+// with fail and call settle once done, which takes back the attempts that did
+// not fail.
+func (t *loginThrottle) reserve(ctx context.Context, scopes []loginThrottleScope) (*loginAttempt, error) {
+	now := time.Now()
+	attempt := &loginAttempt{}
+	for _, scope := range scopes {
+		before, err := t.repo.GetLoginAttempts(ctx, scope.key)
+		if err != nil {
+			t.settle(ctx, attempt)
+			return nil, err
+		}
+		if wait := before.LastFailureAt.Add(scope.policy.delay(before.Failures)).Sub(now); wait > 0 {
+			t.settle(ctx, attempt)
+			return nil, t.throttled(scope, before.Failures, wait)
+		}
+
+		after, err := t.repo.RecordLoginFailure(ctx, scope.key, scope.policy.Window)
+		if err != nil {
+			t.settle(ctx, attempt)
+			return nil, err
+		}
+		attempt.scopes = append(attempt.scopes, scope)
+		attempt.failures = append(attempt.failures, after.Failures)
+
+		// Other attempts were counted since the read above, so the previous
+		// failure is at most a moment old: this attempt waits as long as the
+		// next one after them would.
+		if earlier := after.Failures - 1; earlier > before.Failures {
+			if wait := scope.policy.delay(earlier); wait > 0 {
+				t.settle(ctx, attempt)
+				return nil, t.throttled(scope, earlier, wait)
+			}
+		}
+	}
+	return attempt, nil
+}
+
+func (t *loginThrottle) throttled(scope loginThrottleScope, failures int, wait time.Duration) error {
+	metrics.RecordLoginThrottled(scope.name)
+	t.logger.Warn("sign-in throttled",
+		zap.String("scope", scope.name),
+		zap.String("key", scope.key),
+		zap.Int("failures", failures),
+		zap.Duration("retry_after", wait),
+	)
+	return &models.RetryAfterError{Err: models.ErrTooManyLoginAttempts, RetryAfter: wait}
+}
+
+// fail keeps the reserved attempt counted as a failure.
+func (t *loginThrottle) fail(attempt *loginAttempt) {
+	attempt.failed = true
+	for i, scope := range attempt.scopes {
+		if attempt.failures[i] == scope.policy.LockoutThreshold {
+			metrics.RecordLoginLockout(scope.name)
+			t.logger.Warn("sign-in locked out",
+				zap.String("scope", scope.name),
+				zap.String("key", scope.key),
+				zap.Int("failures", attempt.failures[i]),
+				zap.Duration("lockout_duration", scope.policy.LockoutDuration),
+			)
+		}
+	}
+}
+
+// settle takes back a reserved attempt that did not fail. Counters cleared
+// by reset in the meantime stay cleared. Errors are only logged: the attempt
+// is over and its outcome is what the caller reports.
+func (t *loginThrottle) settle(ctx context.Context, attempt *loginAttempt) {
+	if attempt.failed {
+		return
+	}
+	for _, scope := range attempt.scopes {
+		if err := t.repo.ReleaseLoginAttempt(ctx, scope.key); err != nil {
+			t.logger.Warn("failed to release sign-in attempt",
+				zap.String("scope", scope.name),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// reset clears the counters of a completed sign-in. Callers pass the
+// username scope, plus the MFA scope once a code was accepted; never the IP
+// scope.
+func (t *loginThrottle) reset(ctx context.Context, scopes ...loginThrottleScope) {
+	keys := make([]string, len(scopes))
+	for i, scope := range scopes {
+		keys[i] = scope.key
+	}
+	if err := t.repo.ResetLoginAttempts(ctx, keys...); err != nil {
+		t.logger.Warn("failed to reset sign-in failures",
+			zap.Strings("keys", keys),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"OrderKeeper/internal/models"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMFAIssuer = "OrderKeeper"
+	// recoveryCodeSize is the number of random bytes in a recovery code,
+	// which is shown as 16 base32 characters in groups of four.
+	recoveryCodeSize = 10
+)
+
+// EnrollMFA starts MFA enrolment with a new TOTP secret. MFA is not enforced
+// until ConfirmMFA accepts a first code; enrolling again before that replaces
+// the secret.
+func (a *AuthorizationService) EnrollMFA(ctx context.Context, userID int) (models.MFAEnrolment, error) {
+	user, err := a.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.MFAEnrolment{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.MFAEnabled {
+		return models.MFAEnrolment{}, models.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return models.MFAEnrolment{}, err
+	}
+	if err = a.repo.SetMFASecret(ctx, userID, secret); err != nil {
+		return models.MFAEnrolment{}, err
+	}
+
+	issuer := a.mfaIssuer
+	if issuer == "" {
+		issuer = defaultMFAIssuer
+	}
+
+	a.logger.Info("mfa enrolment started",
+		zap.Int("user_id", userID),
+	)
+	return models.MFAEnrolment{
+		Secret: secret,
+		URI:    totpURI(issuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmMFA enables MFA once the user proves the authenticator app works
+// with a first code. It returns the recovery codes, which are only stored
+// hashed and cannot be shown again. Wrong codes count against the user's MFA
+// counter and the client IP.
+func (a *AuthorizationService) ConfirmMFA(ctx context.Context, userID int, code, clientIP string) ([]string, error) {
+	mfa, err := a.repo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled {
+		return nil, models.ErrMFAAlreadyEnabled
+	}
+	if mfa.Secret == "" {
+		return nil, models.ErrMFANotEnrolled
+	}
+
+	attempt, err := a.throttle.reserve(ctx, a.throttle.mfaScopes(userID, clientIP))
+	if err != nil {
+		return nil, err
+	}
+	defer a.throttle.settle(ctx, attempt)
+	step, ok, err := validateTOTP(mfa.Secret, strings.TrimSpace(code), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		a.logger.Warn("mfa confirmation code rejected",
+			zap.Int("user_id", userID),
+		)
+		a.throttle.fail(attempt)
+		return nil, models.ErrInvalidMFACode
+	}
+
+	codes := make([]string, models.MFARecoveryCodeCount)
+	hashes := make([]string, models.MFARecoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = newRecoveryCode(); err != nil {
+			return nil, err
+		}
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	if _, err = a.repo.EnableMFA(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	a.logger.Info("mfa enrolment confirmed",
+		zap.Int("user_id", userID),
+	)
+	return codes, nil
+}
+
+// DisableMFA turns MFA off. It takes a fresh TOTP code or an unused recovery
+// code, so a stolen access token alone cannot remove the second factor. Wrong
+// codes count against the user's MFA counter and the client IP, so the codes
+// cannot be guessed either.
+func (a *AuthorizationService) DisableMFA(ctx context.Context, userID int, code, clientIP string) error {
+	mfa, err := a.repo.GetUserMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled {
+		return models.ErrMFANotEnabled
+	}
+
+	attempt, err := a.throttle.reserve(ctx, a.throttle.mfaScopes(userID, clientIP))
+	if err != nil {
+		return err
+	}
+	defer a.throttle.settle(ctx, attempt)
+	if err = a.verifyMFACode(ctx, userID, mfa, code); err != nil {
+		if errors.Is(err, models.ErrInvalidMFACode) {
+			a.throttle.fail(attempt)
+		}
+		return err
+	}
+	if _, err = a.repo.DisableMFA(ctx, userID); err != nil {
+		return err
+	}
+
+	a.logger.Info("mfa disabled by user",
+		zap.Int("user_id", userID),
+	)
+	return nil
+}
+
+// CompleteMFASignIn finishes a sign-in started by GenerateToken. The code is
+// either a TOTP code or one of the user's recovery codes. Wrong codes count
+// against the user's MFA counter and the client IP; the password counters
+// of the username are only cleared once the code is accepted.
+func (a *AuthorizationService) CompleteMFASignIn(ctx context.Context, challengeToken, code, clientIP string) (models.TokenPair, error) {
+	challenge, err := a.parseMFAChallenge(challengeToken)
+	if err != nil {
+		a.logger.Warn("mfa challenge rejected", zap.Error(err))
+		return models.TokenPair{}, models.ErrInvalidMFAChallenge
+	}
+
+	// A password reset or sign-out everywhere since the password step voids
+	// the challenge, just like it voids access tokens.
+	revoked, err := a.tokens.IsAccessTokenRevoked(ctx, challenge)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to check mfa challenge: %w", err)
+	}
+	if revoked {
+		return models.TokenPair{}, models.ErrInvalidMFAChallenge
+	}
+
+	user, err := a.repo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return models.TokenPair{}, models.ErrInvalidMFAChallenge
+		}
+		return models.TokenPair{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	mfa, err := a.repo.GetUserMFA(ctx, user.ID)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	if !mfa.Enabled {
+		return models.TokenPair{}, models.ErrInvalidMFAChallenge
+	}
+
+	attempt, err := a.throttle.reserve(ctx, a.throttle.mfaScopes(user.ID, clientIP))
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	defer a.throttle.settle(ctx, attempt)
+	if err = a.verifyMFACode(ctx, user.ID, mfa, code); err != nil {
+		a.logger.Warn("mfa code rejected",
+			zap.Int("user_id", user.ID),
+			zap.Error(err),
+		)
+		if errors.Is(err, models.ErrInvalidMFACode) {
+			a.throttle.fail(attempt)
+		}
+		return models.TokenPair{}, err
+	}
+
+	pair, err := a.startSession(ctx, user)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	a.throttle.reset(ctx, a.throttle.usernameScope(user.Username), a.throttle.mfaScope(user.ID))
+
+	a.logger.Info("mfa sign-in completed",
+		zap.Int("user_id", user.ID),
+	)
+	return pair, nil
+}
+
+// verifyMFACode accepts a TOTP code, each time step at most once, or consumes
+// a recovery code.
+func (a *AuthorizationService) verifyMFACode(ctx context.Context, userID int, mfa models.UserMFA, code string) error {
+	code = strings.TrimSpace(code)
+	if !isTOTPCode(code) {
+		return a.repo.UseMFARecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code)))
+	}
+
+	step, ok, err := validateTOTP(mfa.Secret, code, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok || (mfa.LastStep != nil && step <= *mfa.LastStep) {
+		return models.ErrInvalidMFACode
+	}
+	return a.repo.UseMFAStep(ctx, userID, step)
+}
+
+func (a *AuthorizationService) newMFAChallenge(user models.User) (models.MFAChallenge, error) {
+	token, expiresAt, err := a.signPurposeToken(user.ID, tokenPurposeMFA, models.MFAChallengeTTL)
+	if err != nil {
+		return models.MFAChallenge{}, fmt.Errorf("failed to sign mfa challenge: %w", err)
+	}
+	return models.MFAChallenge{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+func (a *AuthorizationService) parseMFAChallenge(token string) (models.AccessTokenClaims, error) {
+	return a.parsePurposeToken(token, tokenPurposeMFA)
+}
+
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, recoveryCodeSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %w", err)
+	}
+	raw := totpEncoding.EncodeToString(buf)
+	groups := make([]string, 0, len(raw)/4)
+	for i := 0; i < len(raw); i += 4 {
+		groups = append(groups, raw[i:i+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// normalizeRecoveryCode lets users type recovery codes in any case and with
+// or without the separators.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != totpDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
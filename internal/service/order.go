@@ -6,17 +6,25 @@ import (
 	"context"
 	"fmt"
 	"go.uber.org/zap"
+	"math"
+	"strings"
 	"time"
 )
 
 type OrderService struct {
 	repository postgres.Order
+	products   postgres.Product
+	users      postgres.Authorization
+	opts       OrderOptions
 	logger     *zap.Logger
 }
 
-func NewOrderService(repo postgres.Order, logger *zap.Logger) *OrderService {
+func NewOrderService(repo postgres.Order, products postgres.Product, users postgres.Authorization, opts OrderOptions, logger *zap.Logger) *OrderService {
 	return &OrderService{
 		repository: repo,
+		products:   products,
+		users:      users,
+		opts:       opts,
 		logger:     logger,
 	}
 }
@@ -29,6 +37,48 @@ func (o *OrderService) CreateOrder(ctx context.Context, userID int, order *model
 		zap.String("status", string(order.Status)),
 	)
 
+	if o.opts.RequireVerifiedEmail {
+		user, err := o.users.GetUserByID(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to get user: %w", err)
+		}
+		if !user.EmailVerified {
+			o.logger.Warn("order rejected, email not verified",
+				zap.Int("user_id", userID),
+			)
+			return models.ErrEmailNotVerified
+		}
+	}
+
+	if order.Status == "" {
+		order.Status = models.StatusPending
+	}
+	if order.Status != models.StatusPending {
+		o.logger.Warn("order must be created in pending status",
+			zap.Int("user_id", userID),
+			zap.String("status", string(order.Status)),
+		)
+		return fmt.Errorf("%w: new orders must be %s", models.ErrInvalidOrderStatus, models.StatusPending)
+	}
+
+	if err := o.applyCatalogPrices(ctx, order); err != nil {
+		o.logger.Warn("order items rejected by catalog",
+			zap.Int("user_id", userID),
+			zap.Int("items", len(order.Items)),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := calculateTotals(order); err != nil {
+		o.logger.Warn("invalid order items",
+			zap.Int("user_id", userID),
+			zap.Int("items", len(order.Items)),
+			zap.Error(err),
+		)
+		return err
+	}
+
 	err := o.repository.CreateOrder(ctx, userID, order)
 	if err != nil {
 		o.logger.Error("failed to create order",
@@ -42,32 +92,47 @@ func (o *OrderService) CreateOrder(ctx context.Context, userID int, order *model
 
 	o.logger.Info("order created successfully",
 		zap.Int("user_id", userID),
+		zap.Int("order_id", order.ID),
 		zap.String("status", string(order.Status)),
+		zap.Int64("total", order.Total),
+		zap.String("currency", order.Currency),
 		zap.Duration("total_service_duration", time.Since(start)),
 	)
 
 	return nil
 }
-func (o *OrderService) GetOrders(ctx context.Context, userID int) ([]models.Order, error) {
+func (o *OrderService) GetOrders(ctx context.Context, userID int, filter models.OrderFilter) (models.OrderPage, error) {
 	start := time.Now()
 	o.logger.Info("fetching orders for user",
 		zap.Int("user_id", userID),
 	)
-	orders, err := o.repository.GetOrders(ctx, userID)
+	if err := normalizeOrderFilter(&filter); err != nil {
+		o.logger.Warn("invalid order filter",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return models.OrderPage{}, err
+	}
+
+	page, err := o.repository.GetOrders(ctx, userID, filter)
 	if err != nil {
 		o.logger.Error("failed to fetch orders",
 			zap.Int("user_id", userID),
 			zap.Error(err),
 			zap.Duration("total_duration", time.Since(start)),
 		)
-		return nil, fmt.Errorf("failed to fetch orders: %w", err)
+		return models.OrderPage{}, fmt.Errorf("failed to fetch orders: %w", err)
+	}
+	for i := range page.Orders {
+		page.Orders[i].NextStatuses = page.Orders[i].Status.NextStatuses()
 	}
 	o.logger.Info("orders fetched successfully",
 		zap.Int("user_id", userID),
-		zap.Int("order_count", len(orders)),
+		zap.Int("order_count", len(page.Orders)),
+		zap.Bool("has_more", page.NextCursor != ""),
 		zap.Duration("total_duration", time.Since(start)),
 	)
-	return orders, nil
+	return page, nil
 }
 func (o *OrderService) GetOrderByID(ctx context.Context, userID int, orderID int) (models.Order, error) {
 	start := time.Now()
@@ -86,6 +151,7 @@ func (o *OrderService) GetOrderByID(ctx context.Context, userID int, orderID int
 		)
 		return models.Order{}, fmt.Errorf("failed to fetch order by ID: %w", err)
 	}
+	order.NextStatuses = order.Status.NextStatuses()
 	o.logger.Info("order fetched successfully",
 		zap.Int("user_id", userID),
 		zap.Int("order_id", orderID),
@@ -93,14 +159,14 @@ func (o *OrderService) GetOrderByID(ctx context.Context, userID int, orderID int
 	)
 	return order, nil
 }
-func (o *OrderService) DeleteOrder(ctx context.Context, userID int, orderID int) error {
+func (o *OrderService) DeleteOrder(ctx context.Context, userID int, orderID int, expectedVersion *int) error {
 	start := time.Now()
 	o.logger.Info("deleting order",
 		zap.Int("user_id", userID),
 		zap.Int("order_id", orderID),
 	)
 
-	err := o.repository.DeleteOrder(ctx, userID, orderID)
+	err := o.repository.DeleteOrder(ctx, userID, orderID, expectedVersion)
 	if err != nil {
 		o.logger.Error("failed to delete order",
 			zap.Int("user_id", userID),
@@ -118,12 +184,56 @@ func (o *OrderService) DeleteOrder(ctx context.Context, userID int, orderID int)
 	return nil
 }
 func (o *OrderService) UpdateOrder(ctx context.Context, userID int, orderID int, input models.OrderUpdateInput) error {
+	return o.updateOrder(ctx, userID, orderID, userID, input)
+}
+
+// updateOrder changes the status of the user's order on behalf of actorID,
+// who is recorded in the status history.
+func (o *OrderService) updateOrder(ctx context.Context, userID int, orderID int, actorID int, input models.OrderUpdateInput) error {
 	start := time.Now()
 	o.logger.Info("updating order",
 		zap.Int("user_id", userID),
 		zap.Int("order_id", orderID),
+		zap.Int("actor_id", actorID),
 	)
-	err := o.repository.UpdateOrder(ctx, userID, orderID, input)
+	if input.Status == nil || !input.Status.IsValid() {
+		o.logger.Warn("invalid order status in update",
+			zap.Int("user_id", userID),
+			zap.Int("order_id", orderID),
+		)
+		return models.ErrInvalidOrderStatus
+	}
+
+	current, err := o.repository.GetOrderByID(ctx, userID, orderID)
+	if err != nil {
+		o.logger.Error("failed to fetch order for update",
+			zap.Int("user_id", userID),
+			zap.Int("order_id", orderID),
+			zap.Error(err),
+			zap.Duration("total_duration", time.Since(start)),
+		)
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+	if input.ExpectedVersion != nil && *input.ExpectedVersion != current.Version {
+		o.logger.Warn("order version mismatch",
+			zap.Int("user_id", userID),
+			zap.Int("order_id", orderID),
+			zap.Int("expected_version", *input.ExpectedVersion),
+			zap.Int("current_version", current.Version),
+		)
+		return fmt.Errorf("%w: expected %d, current %d", models.ErrOrderVersionMismatch, *input.ExpectedVersion, current.Version)
+	}
+	if !current.Status.CanTransitionTo(*input.Status) {
+		o.logger.Warn("order status transition not allowed",
+			zap.Int("user_id", userID),
+			zap.Int("order_id", orderID),
+			zap.String("from", string(current.Status)),
+			zap.String("to", string(*input.Status)),
+		)
+		return fmt.Errorf("%w: %s -> %s", models.ErrInvalidStatusTransition, current.Status, *input.Status)
+	}
+
+	err = o.repository.UpdateOrder(ctx, userID, orderID, actorID, input)
 	if err != nil {
 		o.logger.Error("failed to update order",
 			zap.Int("user_id", userID),
@@ -140,3 +250,146 @@ func (o *OrderService) UpdateOrder(ctx context.Context, userID int, orderID int,
 	)
 	return nil
 }
+
+func (o *OrderService) GetOrderHistory(ctx context.Context, userID int, orderID int) ([]models.OrderStatusChange, error) {
+	start := time.Now()
+	o.logger.Info("fetching order history",
+		zap.Int("user_id", userID),
+		zap.Int("order_id", orderID),
+	)
+
+	history, err := o.repository.GetOrderHistory(ctx, userID, orderID)
+	if err != nil {
+		o.logger.Error("failed to fetch order history",
+			zap.Int("user_id", userID),
+			zap.Int("order_id", orderID),
+			zap.Error(err),
+			zap.Duration("total_duration", time.Since(start)),
+		)
+		return nil, fmt.Errorf("failed to fetch order history: %w", err)
+	}
+	o.logger.Info("order history fetched successfully",
+		zap.Int("user_id", userID),
+		zap.Int("order_id", orderID),
+		zap.Int("entries", len(history)),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+	return history, nil
+}
+
+// applyCatalogPrices checks that every item references an existing, active
+// product and snapshots the product's current price and currency onto the
+// item, so later catalog changes do not alter placed orders.
+func (o *OrderService) applyCatalogPrices(ctx context.Context, order *models.Order) error {
+	skus := make([]string, 0, len(order.Items))
+	seen := make(map[string]bool, len(order.Items))
+	for _, item := range order.Items {
+		if seen[item.SKU] {
+			return fmt.Errorf("%w: duplicate sku %s", models.ErrInvalidOrderItems, item.SKU)
+		}
+		seen[item.SKU] = true
+		skus = append(skus, item.SKU)
+	}
+
+	products, err := o.products.GetProductsBySKUs(ctx, skus)
+	if err != nil {
+		return fmt.Errorf("failed to look up products: %w", err)
+	}
+	bySKU := make(map[string]models.Product, len(products))
+	for _, product := range products {
+		bySKU[product.SKU] = product
+	}
+
+	var unavailable []string
+	for i := range order.Items {
+		product, ok := bySKU[order.Items[i].SKU]
+		if !ok || !product.Active {
+			unavailable = append(unavailable, order.Items[i].SKU)
+			continue
+		}
+		order.Items[i].UnitPrice = product.Price
+		order.Items[i].Currency = product.Currency
+	}
+	if len(unavailable) > 0 {
+		return fmt.Errorf("%w: %s", models.ErrProductUnavailable, strings.Join(unavailable, ", "))
+	}
+	return nil
+}
+
+// calculateTotals fills in line totals, the subtotal and the total of an
+// order from its items. All items must share one currency, which becomes the
+// order currency. Amounts are in minor units.
+func calculateTotals(order *models.Order) error {
+	if len(order.Items) == 0 {
+		return fmt.Errorf("%w: order must contain at least one item", models.ErrInvalidOrderItems)
+	}
+
+	currency := order.Items[0].Currency
+	var subtotal int64
+	for i := range order.Items {
+		item := &order.Items[i]
+		if item.Quantity <= 0 {
+			return fmt.Errorf("%w: quantity of %s must be positive", models.ErrInvalidOrderItems, item.SKU)
+		}
+		if item.UnitPrice < 0 {
+			return fmt.Errorf("%w: unit price of %s must not be negative", models.ErrInvalidOrderItems, item.SKU)
+		}
+		if item.Currency != currency {
+			return fmt.Errorf("%w: mixed currencies %s and %s", models.ErrInvalidOrderItems, currency, item.Currency)
+		}
+		if item.UnitPrice > 0 && int64(item.Quantity) > math.MaxInt64/item.UnitPrice {
+			return fmt.Errorf("%w: line total of %s is too large", models.ErrInvalidOrderItems, item.SKU)
+		}
+
+		item.LineTotal = int64(item.Quantity) * item.UnitPrice
+		if subtotal > math.MaxInt64-item.LineTotal {
+			return fmt.Errorf("%w: order total is too large", models.ErrInvalidOrderItems)
+		}
+		subtotal += item.LineTotal
+	}
+
+	order.Currency = currency
+	order.Subtotal = subtotal
+	order.Total = subtotal
+	return nil
+}
+
+// normalizeOrderFilter applies defaults and rejects filters that cannot match
+// anything meaningful. Times are converted to UTC so that equal filters share
+// one cache entry.
+func normalizeOrderFilter(filter *models.OrderFilter) error {
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = models.DefaultOrdersPageSize
+	case filter.Limit < 0 || filter.Limit > models.MaxOrdersPageSize:
+		return fmt.Errorf("%w: limit must be between 1 and %d", models.ErrInvalidOrderFilter, models.MaxOrdersPageSize)
+	}
+
+	switch filter.Sort {
+	case "":
+		filter.Sort = models.SortCreatedAtDesc
+	case models.SortCreatedAtDesc, models.SortCreatedAtAsc:
+	default:
+		return fmt.Errorf("%w: unknown sort %q", models.ErrInvalidOrderFilter, filter.Sort)
+	}
+
+	for _, status := range filter.Statuses {
+		if !status.IsValid() {
+			return fmt.Errorf("%w: unknown status %q", models.ErrInvalidOrderFilter, status)
+		}
+	}
+
+	for _, t := range []**time.Time{&filter.CreatedFrom, &filter.CreatedTo, &filter.UpdatedFrom, &filter.UpdatedTo} {
+		if *t != nil {
+			utc := (*t).UTC()
+			*t = &utc
+		}
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		return fmt.Errorf("%w: created_from must be before created_to", models.ErrInvalidOrderFilter)
+	}
+	if filter.UpdatedFrom != nil && filter.UpdatedTo != nil && !filter.UpdatedFrom.Before(*filter.UpdatedTo) {
+		return fmt.Errorf("%w: updated_from must be before updated_to", models.ErrInvalidOrderFilter)
+	}
+	return nil
+}
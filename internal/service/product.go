@@ -0,0 +1,193 @@
+package service
+
+import (
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/repository/postgres"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+type ProductService struct {
+	repository postgres.Product
+	logger     *zap.Logger
+}
+
+func NewProductService(repo postgres.Product, logger *zap.Logger) *ProductService {
+	return &ProductService{
+		repository: repo,
+		logger:     logger,
+	}
+}
+
+func (p *ProductService) CreateProduct(ctx context.Context, product *models.Product) error {
+	start := time.Now()
+	p.logger.Info("product creation process started",
+		zap.String("sku", product.SKU),
+	)
+
+	product.SKU = strings.TrimSpace(product.SKU)
+	product.Currency = strings.ToUpper(product.Currency)
+	if err := validateProduct(product.SKU, product.Name, product.Price, product.Currency); err != nil {
+		p.logger.Warn("invalid product",
+			zap.String("sku", product.SKU),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := p.repository.CreateProduct(ctx, product); err != nil {
+		p.logger.Error("failed to create product",
+			zap.String("sku", product.SKU),
+			zap.Error(err),
+			zap.Duration("total_duration", time.Since(start)),
+		)
+		return fmt.Errorf("failed to create product: %w", err)
+	}
+
+	p.logger.Info("product created successfully",
+		zap.Int("product_id", product.ID),
+		zap.String("sku", product.SKU),
+		zap.Duration("total_service_duration", time.Since(start)),
+	)
+	return nil
+}
+
+func (p *ProductService) GetProducts(ctx context.Context, includeArchived bool) ([]models.Product, error) {
+	products, err := p.repository.GetProducts(ctx, includeArchived)
+	if err != nil {
+		p.logger.Error("failed to fetch products",
+			zap.Bool("include_archived", includeArchived),
+			zap.Error(err),
+		)
+		return nil, fmt.Errorf("failed to fetch products: %w", err)
+	}
+	return products, nil
+}
+
+func (p *ProductService) GetProductByID(ctx context.Context, productID int) (models.Product, error) {
+	product, err := p.repository.GetProductByID(ctx, productID)
+	if err != nil {
+		p.logger.Error("failed to fetch product",
+			zap.Int("product_id", productID),
+			zap.Error(err),
+		)
+		return models.Product{}, fmt.Errorf("failed to fetch product: %w", err)
+	}
+	return product, nil
+}
+
+func (p *ProductService) UpdateProduct(ctx context.Context, productID int, input models.ProductUpdateInput) (models.Product, error) {
+	start := time.Now()
+	p.logger.Info("updating product",
+		zap.Int("product_id", productID),
+	)
+
+	current, err := p.repository.GetProductByID(ctx, productID)
+	if err != nil {
+		return models.Product{}, fmt.Errorf("failed to update product: %w", err)
+	}
+
+	if input.Currency != nil {
+		currency := strings.ToUpper(*input.Currency)
+		input.Currency = &currency
+	}
+	name, price, currency := current.Name, current.Price, current.Currency
+	if input.Name != nil {
+		name = *input.Name
+	}
+	if input.Price != nil {
+		price = *input.Price
+	}
+	if input.Currency != nil {
+		currency = *input.Currency
+	}
+	if err = validateProduct(current.SKU, name, price, currency); err != nil {
+		p.logger.Warn("invalid product update",
+			zap.Int("product_id", productID),
+			zap.Error(err),
+		)
+		return models.Product{}, err
+	}
+
+	product, err := p.repository.UpdateProduct(ctx, productID, input)
+	if err != nil {
+		p.logger.Error("failed to update product",
+			zap.Int("product_id", productID),
+			zap.Error(err),
+			zap.Duration("total_duration", time.Since(start)),
+		)
+		return models.Product{}, fmt.Errorf("failed to update product: %w", err)
+	}
+
+	p.logger.Info("product updated successfully",
+		zap.Int("product_id", productID),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+	return product, nil
+}
+
+func (p *ProductService) ArchiveProduct(ctx context.Context, productID int) error {
+	start := time.Now()
+	if err := p.repository.ArchiveProduct(ctx, productID); err != nil {
+		p.logger.Error("failed to archive product",
+			zap.Int("product_id", productID),
+			zap.Error(err),
+			zap.Duration("total_duration", time.Since(start)),
+		)
+		return fmt.Errorf("failed to archive product: %w", err)
+	}
+
+	p.logger.Info("product archived successfully",
+		zap.Int("product_id", productID),
+		zap.Duration("total_duration", time.Since(start)),
+	)
+	return nil
+}
+
+func (p *ProductService) GetStock(ctx context.Context, productID int) (models.ProductStock, error) {
+	stock, err := p.repository.GetStock(ctx, productID)
+	if err != nil {
+		return models.ProductStock{}, fmt.Errorf("failed to fetch product stock: %w", err)
+	}
+	return stock, nil
+}
+
+func (p *ProductService) SetStock(ctx context.Context, productID int, onHand int) (models.ProductStock, error) {
+	if onHand < 0 {
+		return models.ProductStock{}, fmt.Errorf("%w: on hand must not be negative", models.ErrInvalidStock)
+	}
+
+	stock, err := p.repository.SetStock(ctx, productID, onHand)
+	if err != nil {
+		p.logger.Error("failed to set product stock",
+			zap.Int("product_id", productID),
+			zap.Int("on_hand", onHand),
+			zap.Error(err),
+		)
+		return models.ProductStock{}, fmt.Errorf("failed to set product stock: %w", err)
+	}
+
+	p.logger.Info("product stock set successfully",
+		zap.Int("product_id", productID),
+		zap.Int("on_hand", stock.OnHand),
+		zap.Int("available", stock.Available),
+	)
+	return stock, nil
+}
+
+func validateProduct(sku, name string, price int64, currency string) error {
+	switch {
+	case sku == "":
+		return fmt.Errorf("%w: sku is required", models.ErrInvalidProduct)
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("%w: name is required", models.ErrInvalidProduct)
+	case price < 0:
+		return fmt.Errorf("%w: price must not be negative", models.ErrInvalidProduct)
+	case len(currency) != 3:
+		return fmt.Errorf("%w: currency must be a 3-letter code", models.ErrInvalidProduct)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/notifier"
 	"OrderKeeper/internal/repository/postgres"
 	"context"
 	"go.uber.org/zap"
@@ -9,26 +10,121 @@ import (
 
 type Authorization interface {
 	CreateUser(ctx context.Context, user models.User) (int, error)
-	GenerateToken(ctx context.Context, username, password string) (string, error)
-	ParseToken(ctx context.Context, token string) (int, error)
+	GenerateToken(ctx context.Context, username, password, clientIP string) (models.SignInResult, error)
+	CompleteMFASignIn(ctx context.Context, challengeToken, code, clientIP string) (models.TokenPair, error)
+	RefreshToken(ctx context.Context, refreshToken string) (models.TokenPair, error)
+	Logout(ctx context.Context, claims models.AccessTokenClaims, refreshToken string) error
+	ParseToken(ctx context.Context, token string) (models.AccessTokenClaims, error)
+	IsTokenRevoked(ctx context.Context, claims models.AccessTokenClaims) (bool, error)
+	JWKS() models.JSONWebKeySet
+	ForgotPassword(ctx context.Context, email, clientIP string) error
+	ResetPassword(ctx context.Context, token, password string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, userID int) error
+	EnrollMFA(ctx context.Context, userID int) (models.MFAEnrolment, error)
+	ConfirmMFA(ctx context.Context, userID int, code, clientIP string) ([]string, error)
+	DisableMFA(ctx context.Context, userID int, code, clientIP string) error
+	SetLoginThrottle(opts LoginThrottleOptions)
+}
+
+type User interface {
+	GetProfile(ctx context.Context, userID int) (models.User, error)
+	UpdateProfile(ctx context.Context, userID int, input models.UserProfileUpdate, currentPassword, clientIP string) (models.User, error)
+	ChangePassword(ctx context.Context, userID int, currentPassword, newPassword, clientIP string) (models.TokenPair, error)
+	RequestAccountDeletion(ctx context.Context, userID int, password, mfaCode, clientIP string) (models.AccountDeletionChallenge, error)
+	DeleteAccount(ctx context.Context, userID int, confirmationToken string) error
+}
+
+type APIKey interface {
+	CreateAPIKey(ctx context.Context, userID int, input models.APIKeyInput) (models.APIKey, string, error)
+	GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, keyID int) error
+	AuthenticateAPIKey(ctx context.Context, key string) (models.AccessTokenClaims, error)
 }
 
 type Order interface {
 	CreateOrder(ctx context.Context, userID int, order *models.Order) error
-	GetOrders(ctx context.Context, userID int) ([]models.Order, error)
+	GetOrders(ctx context.Context, userID int, filter models.OrderFilter) (models.OrderPage, error)
 	GetOrderByID(ctx context.Context, userID int, orderID int) (models.Order, error)
-	DeleteOrder(ctx context.Context, userID int, orderID int) error
+	DeleteOrder(ctx context.Context, userID int, orderID int, expectedVersion *int) error
 	UpdateOrder(ctx context.Context, userID int, orderID int, input models.OrderUpdateInput) error
+	GetOrderHistory(ctx context.Context, userID int, orderID int) ([]models.OrderStatusChange, error)
+}
+
+type AdminOrder interface {
+	SearchOrders(ctx context.Context, filter models.OrderFilter) (models.OrderPage, error)
+	GetOrderByID(ctx context.Context, orderID int) (models.Order, error)
+	UpdateOrder(ctx context.Context, adminID int, orderID int, input models.OrderUpdateInput) error
+	GetOrderHistory(ctx context.Context, orderID int) ([]models.OrderStatusChange, error)
+}
+
+type Product interface {
+	CreateProduct(ctx context.Context, product *models.Product) error
+	GetProducts(ctx context.Context, includeArchived bool) ([]models.Product, error)
+	GetProductByID(ctx context.Context, productID int) (models.Product, error)
+	UpdateProduct(ctx context.Context, productID int, input models.ProductUpdateInput) (models.Product, error)
+	ArchiveProduct(ctx context.Context, productID int) error
+	GetStock(ctx context.Context, productID int) (models.ProductStock, error)
+	SetStock(ctx context.Context, productID int, onHand int) (models.ProductStock, error)
+}
+
+type Idempotency interface {
+	Begin(ctx context.Context, userID int, key, fingerprint string) (models.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, record models.IdempotencyRecord) error
+	Abandon(ctx context.Context, userID int, key string) error
+}
+
+// AuthOptions configures the authorization service.
+type AuthOptions struct {
+	TokenKeys *TokenKeys
+	Notifier  notifier.Notifier
+	// Background sends the emails that outlive their request, such as
+	// password resets. Its owner runs it as a lifecycle component.
+	Background *Background
+	// PasswordResetURL is the page that password reset emails link to; the
+	// token is appended as the token query parameter.
+	PasswordResetURL string
+	// EmailVerificationURL is the page that verification emails link to.
+	EmailVerificationURL string
+	// MFAIssuer is the account issuer shown by authenticator apps.
+	MFAIssuer string
+	// LoginThrottle limits failed sign-ins per username and client IP.
+	LoginThrottle LoginThrottleOptions
+	// BcryptCost is the cost of new password hashes; bcrypt.DefaultCost when
+	// zero. Existing hashes are upgraded on the next successful sign-in.
+	BcryptCost int
+}
+
+// OrderOptions configures the order service.
+type OrderOptions struct {
+	// RequireVerifiedEmail rejects orders from users whose email is not
+	// verified yet.
+	RequireVerifiedEmail bool
 }
 
 type Service struct {
 	Authorization
+	User
+	APIKey
 	Order
+	AdminOrder AdminOrder
+	Product
+	Idempotency
 }
 
-func NewService(repo *postgres.Repository, logger *zap.Logger) *Service {
-	return &Service{
-		Authorization: NewAuthorizationService(repo.Authorization, logger),
-		Order:         NewOrderService(repo.Order, logger),
+func NewService(repo *postgres.Repository, authOpts AuthOptions, orderOpts OrderOptions, logger *zap.Logger) (*Service, error) {
+	auth, err := NewAuthorizationService(repo.Authorization, repo.Token, repo.LoginAttempt, authOpts, logger)
+	if err != nil {
+		return nil, err
 	}
+	orders := NewOrderService(repo.Order, repo.Product, repo.Authorization, orderOpts, logger)
+	return &Service{
+		Authorization: auth,
+		User:          NewUserService(auth, repo.Authorization, repo.Token, logger),
+		APIKey:        NewAPIKeyService(repo.APIKey, logger),
+		Order:         orders,
+		AdminOrder:    NewAdminOrderService(orders, repo.Order, logger),
+		Product:       NewProductService(repo.Product, logger),
+		Idempotency:   NewIdempotencyService(repo.Idempotency, logger),
+	}, nil
 }
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"OrderKeeper/internal/models"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"sort"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// TokenKeysConfig describes the keys used for access tokens. With HS256 the
+// shared Secret is the only key. With RS256 or EdDSA, Keys lists every key
+// that is still accepted for verification; the one named by SigningKeyID
+// signs new tokens and needs a private key file. Keeping the previous key in
+// the list while tokens signed with it are still valid lets keys be rotated
+// without signing anyone out.
+type TokenKeysConfig struct {
+	Algorithm    string           `mapstructure:"algorithm"`
+	SigningKeyID string           `mapstructure:"signing_key_id"`
+	Secret       string           `mapstructure:"-"`
+	Keys         []TokenKeyConfig `mapstructure:"keys"`
+}
+
+// TokenKeyConfig names a PEM key file. A private key file is enough for a key
+// that signs; keys that only verify need the public key file.
+type TokenKeyConfig struct {
+	ID             string `mapstructure:"id"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    any
+}
+
+// TokenKeys signs access tokens with the active key and verifies them with
+// any configured key, chosen by the token's kid header.
+type TokenKeys struct {
+	method     jwt.SigningMethod
+	signingKID string
+	signingKey any
+	verifyKeys map[string]verificationKey
+}
+
+func LoadTokenKeys(cfg TokenKeysConfig) (*TokenKeys, error) {
+	switch cfg.Algorithm {
+	case "", AlgorithmHS256:
+		if cfg.Secret == "" {
+			return nil, fmt.Errorf("HS256 requires a signing secret")
+		}
+		kid := cfg.SigningKeyID
+		if kid == "" {
+			kid = "hs256"
+		}
+		secret := []byte(cfg.Secret)
+		return &TokenKeys{
+			method:     jwt.SigningMethodHS256,
+			signingKID: kid,
+			signingKey: secret,
+			verifyKeys: map[string]verificationKey{kid: {method: jwt.SigningMethodHS256, key: secret}},
+		}, nil
+	case AlgorithmRS256, AlgorithmEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported token algorithm %q", cfg.Algorithm)
+	}
+
+	if cfg.SigningKeyID == "" {
+		return nil, fmt.Errorf("%s requires signing_key_id", cfg.Algorithm)
+	}
+
+	keys := &TokenKeys{
+		signingKID: cfg.SigningKeyID,
+		verifyKeys: make(map[string]verificationKey, len(cfg.Keys)),
+	}
+	for _, keyCfg := range cfg.Keys {
+		if keyCfg.ID == "" {
+			return nil, fmt.Errorf("token key without id")
+		}
+		if _, ok := keys.verifyKeys[keyCfg.ID]; ok {
+			return nil, fmt.Errorf("duplicate token key id %q", keyCfg.ID)
+		}
+
+		private, public, err := loadKeyPair(keyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("token key %q: %w", keyCfg.ID, err)
+		}
+		method, err := methodForKey(public)
+		if err != nil {
+			return nil, fmt.Errorf("token key %q: %w", keyCfg.ID, err)
+		}
+		keys.verifyKeys[keyCfg.ID] = verificationKey{method: method, key: public}
+
+		if keyCfg.ID == cfg.SigningKeyID {
+			if private == nil {
+				return nil, fmt.Errorf("signing key %q has no private key file", keyCfg.ID)
+			}
+			if method.Alg() != cfg.Algorithm {
+				return nil, fmt.Errorf("signing key %q is a %s key, expected %s", keyCfg.ID, method.Alg(), cfg.Algorithm)
+			}
+			keys.method = method
+			keys.signingKey = private
+		}
+	}
+	if keys.signingKey == nil {
+		return nil, fmt.Errorf("signing key %q is not configured", cfg.SigningKeyID)
+	}
+
+	return keys, nil
+}
+
+// Sign returns the signed token, tagged with the signing key's kid.
+func (k *TokenKeys) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.signingKID
+	return token.SignedString(k.signingKey)
+}
+
+// Keyfunc selects the verification key for token. The token's algorithm must
+// be the one of the key, so a public key can never be used as an HMAC secret.
+func (k *TokenKeys) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = k.signingKID
+	}
+
+	key, ok := k.verifyKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.key, nil
+}
+
+// JWKS returns the public verification keys. Shared HMAC secrets are never
+// published, so the set is empty in HS256 mode.
+func (k *TokenKeys) JWKS() models.JSONWebKeySet {
+	set := models.JSONWebKeySet{Keys: make([]models.JSONWebKey, 0, len(k.verifyKeys))}
+	for kid, key := range k.verifyKeys {
+		switch public := key.key.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, models.JSONWebKey{
+				KeyType:   "RSA",
+				KeyID:     kid,
+				Use:       "sig",
+				Algorithm: AlgorithmRS256,
+				Modulus:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, models.JSONWebKey{
+				KeyType:   "OKP",
+				KeyID:     kid,
+				Use:       "sig",
+				Algorithm: AlgorithmEdDSA,
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+// loadKeyPair reads the key files of cfg. The public key is derived from the
+// private key when only the latter is given; when both are given, they have
+// to belong together.
+func loadKeyPair(cfg TokenKeyConfig) (crypto.Signer, crypto.PublicKey, error) {
+	var private crypto.Signer
+	if cfg.PrivateKeyFile != "" {
+		block, err := readPEM(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if private, err = parsePrivateKey(block); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if cfg.PublicKeyFile == "" {
+		if private == nil {
+			return nil, nil, fmt.Errorf("either private_key_file or public_key_file is required")
+		}
+		return private, private.Public(), nil
+	}
+
+	block, err := readPEM(cfg.PublicKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	public, err := parsePublicKey(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	if private != nil {
+		derived, ok := private.Public().(interface{ Equal(crypto.PublicKey) bool })
+		if !ok || !derived.Equal(public) {
+			return nil, nil, fmt.Errorf("public_key_file does not match private_key_file")
+		}
+	}
+	return private, public, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM encoded", path)
+	}
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", key)
+}
+
+func parsePublicKey(block *pem.Block) (crypto.PublicKey, error) {
+	if block.Type == "RSA PUBLIC KEY" {
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return key, nil
+}
+
+func methodForKey(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA keys must have at least 2048 bits")
+		}
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", public)
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testKeyFiles struct {
+	private string
+	public  string
+}
+
+// writeKeyFiles stores the PKCS #8 private key and PKIX public key of key as
+// PEM files in a temporary directory.
+func writeKeyFiles(t *testing.T, name string, key crypto.Signer) testKeyFiles {
+	t.Helper()
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	files := testKeyFiles{
+		private: filepath.Join(dir, name+".pem"),
+		public:  filepath.Join(dir, name+".pub.pem"),
+	}
+	writePEM(t, files.private, "PRIVATE KEY", privateDER)
+	writePEM(t, files.public, "PUBLIC KEY", publicDER)
+	return files
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+func newEd25519KeyFiles(t *testing.T, name string) testKeyFiles {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	return writeKeyFiles(t, name, key)
+}
+
+func newRSAKeyFiles(t *testing.T, name string) (testKeyFiles, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	return writeKeyFiles(t, name, key), key
+}
+
+func loadTestTokenKeys(t *testing.T, cfg TokenKeysConfig) *TokenKeys {
+	t.Helper()
+
+	keys, err := LoadTokenKeys(cfg)
+	if err != nil {
+		t.Fatalf("LoadTokenKeys() error = %v", err)
+	}
+	return keys
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "42",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func TestTokenKeysSignWithSigningKeyID(t *testing.T) {
+	old := newEd25519KeyFiles(t, "old")
+	current := newEd25519KeyFiles(t, "current")
+	keys := loadTestTokenKeys(t, TokenKeysConfig{
+		Algorithm:    AlgorithmEdDSA,
+		SigningKeyID: "current",
+		Keys: []TokenKeyConfig{
+			{ID: "old", PublicKeyFile: old.public},
+			{ID: "current", PrivateKeyFile: current.private},
+		},
+	})
+
+	signed, err := keys.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	token, err := jwt.Parse(signed, keys.Keyfunc)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if kid := token.Header["kid"]; kid != "current" {
+		t.Fatalf("kid = %v, want current", kid)
+	}
+	if alg := token.Method.Alg(); alg != AlgorithmEdDSA {
+		t.Fatalf("alg = %s, want %s", alg, AlgorithmEdDSA)
+	}
+}
+
+func TestTokenKeysAcceptOldKeyAfterRotation(t *testing.T) {
+	old := newEd25519KeyFiles(t, "old")
+	current := newEd25519KeyFiles(t, "current")
+
+	before := loadTestTokenKeys(t, TokenKeysConfig{
+		Algorithm:    AlgorithmEdDSA,
+		SigningKeyID: "old",
+		Keys:         []TokenKeyConfig{{ID: "old", PrivateKeyFile: old.private}},
+	})
+	signed, err := before.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	// The old key stays listed, with its public half only, until the tokens
+	// it signed have expired.
+	after := loadTestTokenKeys(t, TokenKeysConfig{
+		Algorithm:    AlgorithmEdDSA,
+		SigningKeyID: "current",
+		Keys: []TokenKeyConfig{
+			{ID: "old", PublicKeyFile: old.public},
+			{ID: "current", PrivateKeyFile: current.private},
+		},
+	})
+	if _, err = jwt.Parse(signed, after.Keyfunc); err != nil {
+		t.Fatalf("Parse() of a token signed before the rotation error = %v", err)
+	}
+}
+
+func TestTokenKeysRejectUnknownKeyID(t *testing.T) {
+	current := newEd25519KeyFiles(t, "current")
+	retired := newEd25519KeyFiles(t, "retired")
+	keys := loadTestTokenKeys(t, TokenKeysConfig{
+		Algorithm:    AlgorithmEdDSA,
+		SigningKeyID: "current",
+		Keys:         []TokenKeyConfig{{ID: "current", PrivateKeyFile: current.private}},
+	})
+	retiredKeys := loadTestTokenKeys(t, TokenKeysConfig{
+		Algorithm:    AlgorithmEdDSA,
+		SigningKeyID: "retired",
+		Keys:         []TokenKeyConfig{{ID: "retired", PrivateKeyFile: retired.private}},
+	})
+
+	signed, err := retiredKeys.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if _, err = jwt.Parse(signed, keys.Keyfunc); err == nil || !strings.Contains(err.Error(), "unknown signing key") {
+		t.Fatalf("Parse() error = %v, want an unknown signing key error", err)
+	}
+}
+
+// An attacker who knows a public key must not be able to sign an HS256 token
+// with it as the HMAC secret.
+func TestTokenKeysRejectHS256WithAsymmetricAlgorithm(t *testing.T) {
+	rsaFiles, _ := newRSAKeyFiles(t, "rsa")
+	edFiles := newEd25519KeyFiles(t, "ed")
+
+	tests := []struct {
+		name  string
+		cfg   TokenKeysConfig
+		files testKeyFiles
+	}{
+		{
+			name: "RS256",
+			cfg: TokenKeysConfig{
+				Algorithm:    AlgorithmRS256,
+				SigningKeyID: "rsa",
+				Keys:         []TokenKeyConfig{{ID: "rsa", PrivateKeyFile: rsaFiles.private}},
+			},
+			files: rsaFiles,
+		},
+		{
+			name: "EdDSA",
+			cfg: TokenKeysConfig{
+				Algorithm:    AlgorithmEdDSA,
+				SigningKeyID: "ed",
+				Keys:         []TokenKeyConfig{{ID: "ed", PrivateKeyFile: edFiles.private}},
+			},
+			files: edFiles,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := loadTestTokenKeys(t, tt.cfg)
+			publicPEM, err := os.ReadFile(tt.files.public)
+			if err != nil {
+				t.Fatalf("ReadFile() error = %v", err)
+			}
+
+			for _, kid := range []string{tt.cfg.SigningKeyID, ""} {
+				forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+				if kid != "" {
+					forged.Header["kid"] = kid
+				}
+				signed, err := forged.SignedString(publicPEM)
+				if err != nil {
+					t.Fatalf("SignedString() error = %v", err)
+				}
+				if _, err = jwt.Parse(signed, keys.Keyfunc); err == nil {
+					t.Fatalf("Parse() of an HS256 token with kid %q succeeded, want an error", kid)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenKeysJWKSPublishesPublicKeysOnly(t *testing.T) {
+	rsaFiles, rsaKey := newRSAKeyFiles(t, "rsa")
+	keys := loadTestTokenKeys(t, TokenKeysConfig{
+		Algorithm:    AlgorithmRS256,
+		SigningKeyID: "rsa",
+		Keys:         []TokenKeyConfig{{ID: "rsa", PrivateKeyFile: rsaFiles.private}},
+	})
+
+	set := keys.JWKS()
+	if len(set.Keys) != 1 {
+		t.Fatalf("JWKS() has %d keys, want 1", len(set.Keys))
+	}
+	key := set.Keys[0]
+	if key.KeyID != "rsa" || key.KeyType != "RSA" || key.Algorithm != AlgorithmRS256 {
+		t.Fatalf("JWKS() key = %+v, want the RS256 key rsa", key)
+	}
+	if want := base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()); key.Modulus != want {
+		t.Fatal("JWKS() modulus does not match the public key")
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var published struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	if err = json.Unmarshal(data, &published); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	for _, member := range []string{"d", "p", "q", "dp", "dq", "qi", "k"} {
+		if _, ok := published.Keys[0][member]; ok {
+			t.Fatalf("JWKS() publishes the private member %q", member)
+		}
+	}
+
+	hmacKeys := loadTestTokenKeys(t, TokenKeysConfig{Algorithm: AlgorithmHS256, Secret: "secret"})
+	if n := len(hmacKeys.JWKS().Keys); n != 0 {
+		t.Fatalf("JWKS() in HS256 mode has %d keys, want 0", n)
+	}
+}
+
+func TestLoadTokenKeysRejectsMismatchedKeyPair(t *testing.T) {
+	current := newEd25519KeyFiles(t, "current")
+	other := newEd25519KeyFiles(t, "other")
+
+	_, err := LoadTokenKeys(TokenKeysConfig{
+		Algorithm:    AlgorithmEdDSA,
+		SigningKeyID: "current",
+		Keys: []TokenKeyConfig{
+			{ID: "current", PrivateKeyFile: current.private, PublicKeyFile: other.public},
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("LoadTokenKeys() error = %v, want a key mismatch error", err)
+	}
+
+	loadTestTokenKeys(t, TokenKeysConfig{
+		Algorithm:    AlgorithmEdDSA,
+		SigningKeyID: "current",
+		Keys: []TokenKeyConfig{
+			{ID: "current", PrivateKeyFile: current.private, PublicKeyFile: current.public},
+		},
+	})
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/notifier"
+	"OrderKeeper/internal/repository/postgres"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// UserService lets users manage their own account. Password checks, throttling
+// and MFA are shared with the AuthorizationService.
+type UserService struct {
+	auth   *AuthorizationService
+	repo   postgres.Authorization
+	tokens postgres.Token
+	logger *zap.Logger
+}
+
+func NewUserService(auth *AuthorizationService, repo postgres.Authorization, tokens postgres.Token, logger *zap.Logger) *UserService {
+	return &UserService{
+		auth:   auth,
+		repo:   repo,
+		tokens: tokens,
+		logger: logger,
+	}
+}
+
+func (u *UserService) GetProfile(ctx context.Context, userID int) (models.User, error) {
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// UpdateProfile changes the username and email. Changing the email takes the
+// current password, checked and throttled like any re-authentication, since
+// whoever controls the address can reset the password. The previous address
+// is told about the change, and the new one has to be verified again.
+func (u *UserService) UpdateProfile(ctx context.Context, userID int, input models.UserProfileUpdate, currentPassword, clientIP string) (models.User, error) {
+	if input.Username == nil && input.Email == nil {
+		return models.User{}, fmt.Errorf("%w: no fields to update", models.ErrInvalidProfile)
+	}
+	if input.Username != nil && strings.TrimSpace(*input.Username) == "" {
+		return models.User{}, fmt.Errorf("%w: username must not be empty", models.ErrInvalidProfile)
+	}
+
+	previous, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	emailChange := input.Email != nil && !strings.EqualFold(*input.Email, previous.Email)
+	if emailChange {
+		if currentPassword == "" {
+			return models.User{}, fmt.Errorf("%w: current_password is required to change the email", models.ErrInvalidProfile)
+		}
+		if _, err = u.checkPassword(ctx, previous, currentPassword, clientIP); err != nil {
+			return models.User{}, err
+		}
+	}
+
+	user, err := u.repo.UpdateUserProfile(ctx, userID, input)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	u.logger.Info("user profile updated",
+		zap.Int("user_id", userID),
+		zap.Bool("username_changed", user.Username != previous.Username),
+		zap.Bool("email_changed", user.Email != previous.Email),
+	)
+
+	if !strings.EqualFold(user.Email, previous.Email) {
+		u.sendEmailChangedNotice(ctx, previous, user.Email)
+		// Goes through the resend limits, so repeated changes cannot be
+		// used to send unlimited verification emails. A throttled user
+		// asks for the email again later.
+		if err = u.auth.sendVerificationEmail(ctx, user, true); err != nil {
+			u.logger.Warn("failed to send verification email after email change",
+				zap.Int("user_id", userID),
+				zap.Error(err),
+			)
+		}
+	}
+	return user, nil
+}
+
+// sendEmailChangedNotice tells the previous address that the account's email
+// was changed, so a takeover does not go unnoticed. The change has been made
+// already, so a failure is only logged.
+func (u *UserService) sendEmailChangedNotice(ctx context.Context, previous models.User, newEmail string) {
+	err := u.auth.notifier.Send(ctx, notifier.Message{
+		To:      previous.Email,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hello %s,\n\nThe email address of your account was changed to %s. "+
+			"If you did not make this change, reset your password and contact support.",
+			previous.Username, newEmail),
+	})
+	if err != nil {
+		u.logger.Warn("failed to notify the previous email address of an email change",
+			zap.Int("user_id", previous.ID),
+			zap.Error(err),
+		)
+	}
+}
+
+// ChangePassword replaces the password after checking the current one. Every
+// existing session is signed out, and the returned tokens start a new one
+// for the client that made the change.
+func (u *UserService) ChangePassword(ctx context.Context, userID int, currentPassword, newPassword, clientIP string) (models.TokenPair, error) {
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	oldHash, err := u.checkPassword(ctx, user, currentPassword, clientIP)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	if currentPassword == newPassword {
+		return models.TokenPair{}, models.ErrPasswordUnchanged
+	}
+
+	newHash, err := u.auth.generatePasswordHash(newPassword)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	replaced, err := u.repo.UpdatePasswordHash(ctx, userID, oldHash, newHash)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	if !replaced {
+		// The password was reset or changed elsewhere since it was checked.
+		return models.TokenPair{}, models.ErrInvalidCredentials
+	}
+
+	if _, err = u.tokens.RevokeUserSessions(ctx, userID); err != nil {
+		u.logger.Error("password was changed but sessions could not be revoked",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return models.TokenPair{}, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	pair, err := u.auth.startSession(ctx, user)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	u.logger.Info("password changed",
+		zap.Int("user_id", userID),
+	)
+	return pair, nil
+}
+
+// RequestAccountDeletion checks the password, and the MFA code when MFA is
+// enabled, and returns the challenge that DeleteAccount needs. Nothing is
+// deleted yet.
+func (u *UserService) RequestAccountDeletion(ctx context.Context, userID int, password, mfaCode, clientIP string) (models.AccountDeletionChallenge, error) {
+	user, err := u.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.AccountDeletionChallenge{}, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if _, err = u.checkPassword(ctx, user, password, clientIP); err != nil {
+		return models.AccountDeletionChallenge{}, err
+	}
+
+	if user.MFAEnabled {
+		mfa, err := u.repo.GetUserMFA(ctx, userID)
+		if err != nil {
+			return models.AccountDeletionChallenge{}, err
+		}
+		if strings.TrimSpace(mfaCode) == "" {
+			return models.AccountDeletionChallenge{}, models.ErrInvalidMFACode
+		}
+		attempt, err := u.auth.throttle.reserve(ctx, u.auth.throttle.mfaScopes(userID, clientIP))
+		if err != nil {
+			return models.AccountDeletionChallenge{}, err
+		}
+		defer u.auth.throttle.settle(ctx, attempt)
+		if err = u.auth.verifyMFACode(ctx, userID, mfa, mfaCode); err != nil {
+			if errors.Is(err, models.ErrInvalidMFACode) {
+				u.auth.throttle.fail(attempt)
+			}
+			return models.AccountDeletionChallenge{}, err
+		}
+	}
+
+	token, expiresAt, err := u.auth.signPurposeToken(userID, tokenPurposeAccountDeletion, models.AccountDeletionTTL)
+	if err != nil {
+		return models.AccountDeletionChallenge{}, fmt.Errorf("failed to sign account deletion token: %w", err)
+	}
+
+	u.logger.Info("account deletion requested",
+		zap.Int("user_id", userID),
+	)
+	return models.AccountDeletionChallenge{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// DeleteAccount deletes the user's account, their orders and tokens with it.
+// The confirmation token must come from RequestAccountDeletion for the same
+// user; like access tokens, it is void once the user's sessions are revoked.
+func (u *UserService) DeleteAccount(ctx context.Context, userID int, confirmationToken string) error {
+	claims, err := u.auth.parsePurposeToken(confirmationToken, tokenPurposeAccountDeletion)
+	if err != nil || claims.UserID != userID {
+		u.logger.Warn("account deletion confirmation rejected",
+			zap.Int("user_id", userID),
+			zap.Error(err),
+		)
+		return models.ErrInvalidDeletionConfirmation
+	}
+
+	revoked, err := u.tokens.IsAccessTokenRevoked(ctx, claims)
+	if err != nil {
+		return fmt.Errorf("failed to check account deletion confirmation: %w", err)
+	}
+	if revoked {
+		return models.ErrInvalidDeletionConfirmation
+	}
+
+	// Revoking first also publishes the revocation to the token cache, so
+	// access tokens stop working even where the missing user is not noticed.
+	if _, err = u.tokens.RevokeUserSessions(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	if _, err = u.repo.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+
+	u.logger.Info("account deleted",
+		zap.Int("user_id", userID),
+	)
+	return nil
+}
+
+// checkPassword verifies a password the signed-in user re-enters. Wrong
+// passwords count as failed sign-ins, so a stolen access token cannot be
+// used to guess the password faster than the sign-in form allows. A correct
+// password does not reset the counters: a re-authentication is not a
+// sign-in, and resetting here would let wrong MFA codes be retried forever.
+func (u *UserService) checkPassword(ctx context.Context, user models.User, password, clientIP string) (string, error) {
+	attempt, err := u.auth.throttle.reserve(ctx, u.auth.throttle.passwordScopes(user.Username, clientIP))
+	if err != nil {
+		return "", err
+	}
+	defer u.auth.throttle.settle(ctx, attempt)
+
+	hash, err := u.auth.verifyPassword(ctx, user.ID, password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			u.auth.throttle.fail(attempt)
+			u.logger.Warn("password confirmation rejected",
+				zap.Int("user_id", user.ID),
+				zap.String("client_ip", clientIP),
+			)
+		}
+		return "", err
+	}
+	return hash, nil
+}
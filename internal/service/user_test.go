@@ -0,0 +1,176 @@
+package service
+
+import (
+	"OrderKeeper/internal/models"
+	"OrderKeeper/internal/notifier"
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+	"time"
+)
+
+func (f *fakeAuthRepo) GetUserByID(_ context.Context, userID int) (models.User, error) {
+	for _, user := range f.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return models.User{}, fmt.Errorf("%w: %d", models.ErrUserNotFound, userID)
+}
+
+func (f *fakeAuthRepo) DeleteUser(ctx context.Context, userID int) (models.User, error) {
+	user, err := f.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	delete(f.users, user.Username)
+	delete(f.hashes, userID)
+	return user, nil
+}
+
+func (f *fakeAuthRepo) UpdateUserProfile(ctx context.Context, userID int, input models.UserProfileUpdate) (models.User, error) {
+	user, err := f.GetUserByID(ctx, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	if input.Email != nil {
+		user.Email = *input.Email
+		user.EmailVerified = false
+	}
+	f.users[user.Username] = user
+	return user, nil
+}
+
+func (f *fakeAuthRepo) ResendEmailVerificationToken(_ context.Context, token *models.EmailVerificationToken) error {
+	f.verificationTokens++
+	return nil
+}
+
+type fakeNotifier struct {
+	sent []notifier.Message
+}
+
+func (f *fakeNotifier) Send(_ context.Context, msg notifier.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func (f *fakeTokenRepo) RevokeUserSessions(_ context.Context, _ int) (time.Time, error) {
+	f.sessionsRevoked++
+	return time.Now(), nil
+}
+
+func (f *fakeTokenRepo) IsAccessTokenRevoked(_ context.Context, _ models.AccessTokenClaims) (bool, error) {
+	return false, nil
+}
+
+func newTestUserService(t *testing.T, repo *fakeAuthRepo) (*UserService, *fakeTokenRepo, *fakeLoginAttemptRepo) {
+	t.Helper()
+
+	auth, tokens, attempts := newTestAuthService(t, repo)
+	return NewUserService(auth, repo, tokens, zap.NewNop()), tokens, attempts
+}
+
+func TestChangePassword(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	users, tokens, attempts := newTestUserService(t, repo)
+	ctx := context.Background()
+
+	_, err := users.ChangePassword(ctx, 1, "wrong-password", "new-secret", "10.0.0.1")
+	if !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Fatalf("wrong current password: expected ErrInvalidCredentials, got %v", err)
+	}
+	if got := attempts.attempts["user:"+testUsername].Failures; got != 1 {
+		t.Errorf("recorded failures = %d, want 1", got)
+	}
+
+	pair, err := users.ChangePassword(ctx, 1, testPassword, "new-secret", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pair.AccessToken == "" || pair.RefreshToken == "" {
+		t.Errorf("expected a new token pair, got %+v", pair)
+	}
+	if tokens.sessionsRevoked != 1 {
+		t.Errorf("sessions revoked %d times, want 1", tokens.sessionsRevoked)
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(repo.hashes[1]), []byte("new-secret")); err != nil {
+		t.Errorf("stored hash does not match the new password: %v", err)
+	}
+}
+
+func TestDeleteAccountRequiresConfirmation(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	users, tokens, _ := newTestUserService(t, repo)
+	ctx := context.Background()
+
+	if _, err := users.RequestAccountDeletion(ctx, 1, "wrong-password", "", "10.0.0.1"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Fatalf("wrong password: expected ErrInvalidCredentials, got %v", err)
+	}
+
+	challenge, err := users.RequestAccountDeletion(ctx, 1, testPassword, "", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := repo.users[testUsername]; !ok {
+		t.Fatalf("account was deleted before the deletion was confirmed")
+	}
+
+	// The confirmation only works for the user it was issued to, and is
+	// not an access token.
+	if err = users.DeleteAccount(ctx, 2, challenge.Token); !errors.Is(err, models.ErrInvalidDeletionConfirmation) {
+		t.Errorf("other user: expected ErrInvalidDeletionConfirmation, got %v", err)
+	}
+	if _, err = users.auth.ParseToken(ctx, challenge.Token); err == nil {
+		t.Errorf("confirmation token was accepted as an access token")
+	}
+
+	if err = users.DeleteAccount(ctx, 1, challenge.Token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := repo.users[testUsername]; ok {
+		t.Errorf("account still exists after confirmed deletion")
+	}
+	if tokens.sessionsRevoked != 1 {
+		t.Errorf("sessions revoked %d times, want 1", tokens.sessionsRevoked)
+	}
+}
+
+func TestUpdateProfileEmailRequiresPassword(t *testing.T) {
+	repo := newFakeAuthRepo(t)
+	users, _, attempts := newTestUserService(t, repo)
+	mails := &fakeNotifier{}
+	users.auth.notifier = mails
+	ctx := context.Background()
+	email := "new@example.com"
+
+	if _, err := users.UpdateProfile(ctx, 1, models.UserProfileUpdate{Email: &email}, "", "10.0.0.1"); !errors.Is(err, models.ErrInvalidProfile) {
+		t.Fatalf("no password: expected ErrInvalidProfile, got %v", err)
+	}
+	if _, err := users.UpdateProfile(ctx, 1, models.UserProfileUpdate{Email: &email}, "wrong-password", "10.0.0.1"); !errors.Is(err, models.ErrInvalidCredentials) {
+		t.Fatalf("wrong password: expected ErrInvalidCredentials, got %v", err)
+	}
+	if got := attempts.attempts["user:"+testUsername].Failures; got != 1 {
+		t.Errorf("recorded failures = %d, want 1", got)
+	}
+	if repo.users[testUsername].Email != "john@example.com" || len(mails.sent) != 0 {
+		t.Fatalf("email changed or mail sent without the password")
+	}
+
+	user, err := users.UpdateProfile(ctx, 1, models.UserProfileUpdate{Email: &email}, testPassword, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.Email != email {
+		t.Errorf("email = %q, want %q", user.Email, email)
+	}
+	if len(mails.sent) != 2 || mails.sent[0].To != "john@example.com" || mails.sent[1].To != email {
+		t.Fatalf("sent %+v, want a notice to the old address and a verification to the new one", mails.sent)
+	}
+	if repo.verificationTokens != 1 {
+		t.Errorf("verification tokens issued through the resend limits = %d, want 1", repo.verificationTokens)
+	}
+}
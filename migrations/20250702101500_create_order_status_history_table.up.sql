@@ -0,0 +1,12 @@
+CREATE TABLE order_status_history
+(
+    id          SERIAL PRIMARY KEY,
+    order_id    INTEGER      NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    from_status order_status,
+    to_status   order_status NOT NULL,
+    actor_id    INTEGER      REFERENCES users (id) ON DELETE SET NULL,
+    reason      TEXT,
+    changed_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_order_status_history_order_id ON order_status_history (order_id, changed_at);
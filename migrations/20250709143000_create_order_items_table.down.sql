@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS order_items;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS total,
+    DROP COLUMN IF EXISTS subtotal,
+    DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,17 @@
+ALTER TABLE orders
+    ADD COLUMN currency CHAR(3),
+    ADD COLUMN subtotal BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN total    BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE order_items
+(
+    id         SERIAL PRIMARY KEY,
+    order_id   INTEGER     NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+    sku        VARCHAR(64) NOT NULL,
+    quantity   INTEGER     NOT NULL CHECK (quantity > 0),
+    unit_price BIGINT      NOT NULL CHECK (unit_price >= 0),
+    currency   CHAR(3)     NOT NULL,
+    line_total BIGINT      NOT NULL CHECK (line_total >= 0)
+);
+
+CREATE INDEX idx_order_items_order_id ON order_items (order_id);
@@ -0,0 +1,15 @@
+CREATE TABLE products
+(
+    id          SERIAL PRIMARY KEY,
+    sku         VARCHAR(64)  NOT NULL UNIQUE,
+    name        VARCHAR(255) NOT NULL,
+    description TEXT         NOT NULL DEFAULT '',
+    price       BIGINT       NOT NULL CHECK (price >= 0),
+    currency    CHAR(3)      NOT NULL,
+    active      BOOLEAN      NOT NULL DEFAULT TRUE,
+    archived_at TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_products_active ON products (active);
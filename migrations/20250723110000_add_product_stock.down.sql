@@ -0,0 +1,8 @@
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS stock_reserved;
+
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_stock_reserved_check,
+    DROP CONSTRAINT IF EXISTS products_stock_on_hand_check,
+    DROP COLUMN IF EXISTS stock_reserved,
+    DROP COLUMN IF EXISTS stock_on_hand;
@@ -0,0 +1,8 @@
+ALTER TABLE products
+    ADD COLUMN stock_on_hand  INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN stock_reserved INTEGER NOT NULL DEFAULT 0,
+    ADD CONSTRAINT products_stock_on_hand_check CHECK (stock_on_hand >= 0),
+    ADD CONSTRAINT products_stock_reserved_check CHECK (stock_reserved >= 0 AND stock_reserved <= stock_on_hand);
+
+ALTER TABLE orders
+    ADD COLUMN stock_reserved BOOLEAN NOT NULL DEFAULT FALSE;
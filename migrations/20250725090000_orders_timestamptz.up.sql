@@ -0,0 +1,6 @@
+-- Order filters compare these columns with times passed in by the
+-- application, so they are stored as instants. Existing values were written
+-- in UTC.
+ALTER TABLE orders
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
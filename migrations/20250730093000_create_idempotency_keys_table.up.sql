@@ -0,0 +1,13 @@
+CREATE TABLE idempotency_keys
+(
+    user_id       INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    key           VARCHAR(255) NOT NULL,
+    fingerprint   CHAR(64)     NOT NULL,
+    status_code   INTEGER,
+    response_body BYTEA,
+    created_at    TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    completed_at  TIMESTAMPTZ,
+    PRIMARY KEY (user_id, key)
+);
+
+CREATE INDEX idx_idempotency_keys_created_at ON idempotency_keys (created_at);
@@ -0,0 +1,15 @@
+CREATE TABLE password_reset_tokens
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash CHAR(64)  NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
+
+-- Access tokens issued before this moment are no longer accepted.
+ALTER TABLE users
+    ADD COLUMN sessions_revoked_at TIMESTAMPTZ;
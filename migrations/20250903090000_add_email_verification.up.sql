@@ -0,0 +1,19 @@
+ALTER TABLE users
+    ADD COLUMN email_verified    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+-- Accounts created before verification existed keep working as before.
+UPDATE users
+SET email_verified = TRUE, email_verified_at = NOW();
+
+CREATE TABLE email_verification_tokens
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash CHAR(64)  NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_email_verification_tokens_user_id ON email_verification_tokens (user_id, created_at);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS mfa_recovery_codes;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS mfa_last_step,
+    DROP COLUMN IF EXISTS mfa_enabled_at,
+    DROP COLUMN IF EXISTS mfa_enabled,
+    DROP COLUMN IF EXISTS mfa_secret;
@@ -0,0 +1,18 @@
+-- mfa_secret holds the base32 TOTP secret from enrolment onwards; MFA is only
+-- enforced once mfa_enabled is set by confirming a first code.
+-- mfa_last_step is the last accepted TOTP time step, so a code works once.
+ALTER TABLE users
+    ADD COLUMN mfa_secret     VARCHAR(64),
+    ADD COLUMN mfa_enabled    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN mfa_enabled_at TIMESTAMPTZ,
+    ADD COLUMN mfa_last_step  BIGINT;
+
+CREATE TABLE mfa_recovery_codes
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INTEGER   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  CHAR(64)  NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, code_hash)
+);
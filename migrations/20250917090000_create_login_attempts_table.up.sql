@@ -0,0 +1,11 @@
+-- Failed sign-in attempts per throttling key (a username or a client IP).
+-- Only used when Redis is unavailable; a row is stale once expires_at passes.
+CREATE TABLE login_attempts
+(
+    key             VARCHAR(320) PRIMARY KEY,
+    failures        INTEGER   NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    expires_at      TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_login_attempts_expires_at ON login_attempts (expires_at);
@@ -0,0 +1,6 @@
+-- Renamed duplicates keep their new values; user_identity_conflicts is the
+-- only record of the originals, so it is dropped last and deliberately.
+DROP INDEX IF EXISTS users_email_lower_key;
+DROP INDEX IF EXISTS users_username_lower_key;
+
+DROP TABLE IF EXISTS user_identity_conflicts;
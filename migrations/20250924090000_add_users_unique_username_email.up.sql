@@ -0,0 +1,80 @@
+-- Usernames and emails become unique regardless of case. Existing duplicates
+-- are resolved first: the oldest account keeps the value, later accounts get
+-- a placeholder, and every change is recorded in user_identity_conflicts so
+-- that the affected users can be contacted.
+CREATE TABLE user_identity_conflicts
+(
+    id             SERIAL PRIMARY KEY,
+    user_id        INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    field          VARCHAR(20) NOT NULL,
+    original_value VARCHAR(255) NOT NULL,
+    new_value      VARCHAR(255) NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Duplicate usernames get the user id appended, within the 30 characters.
+CREATE TEMPORARY TABLE username_renames ON COMMIT DROP AS
+SELECT id, username, LEFT(username, 30 - LENGTH('_' || id)) || '_' || id AS new_value
+FROM (
+    SELECT id, username,
+           ROW_NUMBER() OVER (PARTITION BY LOWER(username) ORDER BY id) AS rank
+    FROM users
+) ranked
+WHERE rank > 1;
+
+-- Duplicate emails are replaced by an undeliverable placeholder and have to
+-- be verified again once the user sets a real address.
+CREATE TEMPORARY TABLE email_renames ON COMMIT DROP AS
+SELECT id, email, 'duplicate-' || id || '@invalid' AS new_value
+FROM (
+    SELECT id, email,
+           ROW_NUMBER() OVER (PARTITION BY LOWER(email) ORDER BY id) AS rank
+    FROM users
+) ranked
+WHERE rank > 1;
+
+-- A new value can only clash with one that already exists, e.g. a user
+-- already called bob_42. Such rows are not guessed around: the migration
+-- stops and names them, so they can be renamed by hand before retrying.
+DO
+$$
+DECLARE
+    conflicts TEXT;
+BEGIN
+    SELECT string_agg(format('%s of user %s would become %L, which user %s already has',
+                             field, renamed_id, new_value, existing_id), '; ')
+    INTO conflicts
+    FROM (
+        SELECT 'username' AS field, r.id AS renamed_id, r.new_value, u.id AS existing_id
+        FROM username_renames r
+        JOIN users u ON LOWER(u.username) = LOWER(r.new_value)
+        UNION ALL
+        SELECT 'email', r.id, r.new_value, u.id
+        FROM email_renames r
+        JOIN users u ON LOWER(u.email) = LOWER(r.new_value)
+    ) clashes;
+
+    IF conflicts IS NOT NULL THEN
+        RAISE EXCEPTION 'cannot resolve duplicate usernames and emails: %', conflicts
+            USING HINT = 'Rename the listed users by hand and run the migration again.';
+    END IF;
+END
+$$;
+
+INSERT INTO user_identity_conflicts (user_id, field, original_value, new_value)
+SELECT id, 'username', username, new_value FROM username_renames
+UNION ALL
+SELECT id, 'email', email, new_value FROM email_renames;
+
+UPDATE users
+SET username = username_renames.new_value
+FROM username_renames
+WHERE users.id = username_renames.id;
+
+UPDATE users
+SET email = email_renames.new_value, email_verified = FALSE, email_verified_at = NULL
+FROM email_renames
+WHERE users.id = email_renames.id;
+
+CREATE UNIQUE INDEX users_username_lower_key ON users (LOWER(username));
+CREATE UNIQUE INDEX users_email_lower_key ON users (LOWER(email));
@@ -0,0 +1,17 @@
+-- Personal API keys for machine clients. Only the SHA-256 hash of a key is
+-- stored; prefix is its first characters, kept so users can tell keys apart.
+CREATE TABLE api_keys
+(
+    id           SERIAL PRIMARY KEY,
+    user_id      INTEGER      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         VARCHAR(100) NOT NULL,
+    prefix       VARCHAR(16)  NOT NULL,
+    key_hash     CHAR(64)     NOT NULL UNIQUE,
+    scopes       TEXT[]       NOT NULL,
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id, created_at);
@@ -0,0 +1,5 @@
+-- A request holds its key only until locked_until. If the process dies before
+-- the response is stored, a retry takes the key over once the lease expires
+-- instead of getting "in progress" until the key itself expires.
+ALTER TABLE idempotency_keys
+    ADD COLUMN locked_until TIMESTAMPTZ NOT NULL DEFAULT NOW();
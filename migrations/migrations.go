@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migrations into the binary, so they can be
+// applied with `order-keeper migrate` wherever the binary runs.
+package migrations
+
+import "embed"
+
+// FS holds the <version>_<name>.up.sql and .down.sql files.
+//
+//go:embed *.sql
+var FS embed.FS
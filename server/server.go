@@ -2,15 +2,26 @@ package server
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"sync"
 	"time"
 )
 
 type Server struct {
+	mu         sync.Mutex
 	httpServer *http.Server
+	shutdown   bool
 }
 
+// Run serves until Shutdown is called, and then returns nil. Any other
+// error, such as the port being in use, is returned to the caller.
 func (s *Server) Run(port string, handler http.Handler) error {
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		return nil
+	}
 	s.httpServer = &http.Server{
 		Addr:           port,
 		Handler:        handler,
@@ -18,9 +29,25 @@ func (s *Server) Run(port string, handler http.Handler) error {
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 	}
+	httpServer := s.httpServer
+	s.mu.Unlock()
 
-	return s.httpServer.ListenAndServe()
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
+
+// Shutdown stops accepting connections and waits for active requests until
+// ctx is done. It may be called before Run, which then does not start.
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.httpServer.Shutdown(ctx)
+	s.mu.Lock()
+	s.shutdown = true
+	httpServer := s.httpServer
+	s.mu.Unlock()
+
+	if httpServer == nil {
+		return nil
+	}
+	return httpServer.Shutdown(ctx)
 }